@@ -8,7 +8,15 @@ from .utils import data_to_ellipse
 
 class Run(object):
   def __init__(self, load_driver_data: pandas.DataFrame, note: str, remove_data_from_beginning: float = 0.0):
-    self.workloads = list(filter(lambda c: c != "__all__", load_driver_data["workload"].unique()))
+    # Rows such as __all__, __host__, and __connect__ do not belong to a
+    # single workload.
+    self.workloads = list(filter(lambda c: not c.startswith("__"), load_driver_data["workload"].unique()))
+
+    # The latency per host is only logged if replicas are configured. Older
+    # runs do not have the host column.
+    self.hosts = []
+    if "host" in load_driver_data.columns:
+      self.hosts = list(load_driver_data.where(load_driver_data["workload"] == "__host__")["host"].dropna().unique())
     self.load_driver_data = load_driver_data
     self.note = note
     self.remove_data_from_beginning = remove_data_from_beginning
@@ -24,12 +32,16 @@ class Run(object):
     all_data = self.load_driver_data_for(workload_name, remove_data_from_beginning=self.remove_data_from_beginning)
     return np.std(all_data["rate"])
 
-  def load_driver_data_for(self, workload_name: str, remove_data_from_beginning: float = None) -> pandas.DataFrame:
-    # TODO: there are nulls in the data because the uniform_hist feature is not
-    # ready yet. Since we need to drop the NaN rows, and pandas treats None as
-    # the same as NaN, we need to replace it so it doesn't get accidentally
-    # dropped.
+  def load_driver_data_for(self, workload_name: str, remove_data_from_beginning: float = None, include_warmup: bool = False) -> pandas.DataFrame:
+    # There are nulls in the data, such as in the host column. Since we need to
+    # drop the NaN rows, and pandas treats None as the same as NaN, we need to
+    # replace it so it doesn't get accidentally dropped.
     data = self.load_driver_data.where(self.load_driver_data["workload"] == workload_name).replace({None: "Empty"}).dropna()
+
+    # Data collected during the warmup phase (-warmup or -warmupevents) is
+    # excluded by default. Older runs do not have the phase column.
+    if not include_warmup and "phase" in data.columns:
+      data = data.where(data["phase"] != "warmup").dropna()
     if remove_data_from_beginning is None:
       remove_data_from_beginning = self.remove_data_from_beginning
 
@@ -38,6 +50,10 @@ class Run(object):
 
     return data
 
+  def load_driver_data_for_host(self, host: str, remove_data_from_beginning: float = None, include_warmup: bool = False) -> pandas.DataFrame:
+    data = self.load_driver_data_for("__host__", remove_data_from_beginning=remove_data_from_beginning, include_warmup=include_warmup)
+    return data.where(data["host"] == host).dropna()
+
   def standard_figure(self, legend=False, label=True):
     fig = plt.figure(figsize=[14, 8])
     gs = fig.add_gridspec(2, 2)
@@ -216,8 +232,15 @@ class Run(object):
   def plot_overall_all_percentile_latency(self, ax: matplotlib.axes.Axes, style: dict = {}, **kwargs):
     data = self.load_driver_data_for("__all__", remove_data_from_beginning=self.remove_data_from_beginning)
 
-    for key in ["percentile50", "percentile75", "percentile90", "percentile99"]:
-      label = f"p{key.split('percentile')[1]}"
+    # The percentile columns depend on -percentiles, such as percentile99_9.
+    # percentile100 is the maximum latency.
+    for key in data.columns:
+      if not key.startswith("percentile") or key == "percentile25":
+        continue
+
+      label = f"p{key.split('percentile')[1].replace('_', '.')}"
+      if label == "p100":
+        label = "max"
 
       percentile_data = data[key] / 1000.0
 
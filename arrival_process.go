@@ -0,0 +1,333 @@
+package mybench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// An ArrivalProcess generates the time between successive events for the
+// DiscretizedLooper. Different arrival processes can be used to model
+// different kinds of traffic. For example, the uniform arrival process
+// generates perfectly evenly spaced events, while the bursty arrival processes
+// generate events in clusters, similar to real production traffic.
+//
+// All arrival processes must generate events with a long-run average rate
+// equal to the given event rate, so the desired rate of the benchmark is
+// maintained regardless of the arrival process.
+//
+// Each DiscretizedLooper creates its own ArrivalProcess, so implementations
+// can keep state and do not need to be thread-safe.
+type ArrivalProcess interface {
+	// Returns the duration until the next event, given the current desired
+	// event rate in events per second. The event rate is always positive.
+	InterArrivalDuration(eventRate float64) time.Duration
+}
+
+const (
+	LooperTypeUniform LooperType = iota
+	LooperTypePoisson
+	LooperTypeBursty
+	LooperTypeMMPP
+	LooperTypePareto
+)
+
+var looperTypeNames = map[LooperType]string{
+	LooperTypeUniform: "uniform",
+	LooperTypePoisson: "poisson",
+	LooperTypeBursty:  "bursty",
+	LooperTypeMMPP:    "mmpp",
+	LooperTypePareto:  "pareto",
+}
+
+func (t LooperType) String() string {
+	name, found := looperTypeNames[t]
+	if !found {
+		return fmt.Sprintf("LooperType(%d)", int(t))
+	}
+	return name
+}
+
+// Allows LooperType to be used as a flag.Value.
+func (t *LooperType) Set(s string) error {
+	for looperType, name := range looperTypeNames {
+		if name == s {
+			*t = looperType
+			return nil
+		}
+	}
+
+	names := make([]string, 0, len(looperTypeNames))
+	for looperType := LooperTypeUniform; looperType <= LooperTypePareto; looperType++ {
+		names = append(names, looperType.String())
+	}
+	return fmt.Errorf("unknown looper type %s, must be one of %s", s, strings.Join(names, ", "))
+}
+
+// Configures the arrival process of the events. This can be configured for the
+// whole benchmark via RateControlConfig and overridden for individual
+// workloads via WorkloadConfig.
+type ArrivalConfig struct {
+	// The type of arrival process used. Default to Uniform.
+	LooperType LooperType
+
+	// For LooperTypeBursty: events arrive as a Poisson process during the on
+	// periods and no events arrive during the off periods. The rate during the
+	// on periods is increased so the average rate is the desired event rate.
+	// Both default to 1s if they are 0. As 0 means unset, a negative
+	// BurstOffDuration, such as NoBurstOffPeriod, disables the off periods,
+	// which makes the process a Poisson process.
+	BurstOnDuration  time.Duration
+	BurstOffDuration time.Duration
+
+	// For LooperTypeMMPP (Markov-modulated Poisson process): the process
+	// alternates between a high and a low state, each with exponentially
+	// distributed durations with the given means. Within each state, events
+	// arrive as a Poisson process, with the rate in the high state being
+	// MMPPRateRatio times the rate in the low state. The rates are chosen so
+	// the average rate is the desired event rate. Defaults to a ratio of 10,
+	// with a mean high state duration of 1s and low state duration of 9s.
+	MMPPRateRatio         float64
+	MMPPHighStateDuration time.Duration
+	MMPPLowStateDuration  time.Duration
+
+	// For LooperTypePareto: the inter-arrival durations follow a heavy-tailed
+	// Pareto distribution with this shape parameter, which must be greater
+	// than 1. The closer it is to 1, the heavier the tail. Default: 1.5.
+	ParetoShape float64
+
+	// If set, this creates the arrival process and LooperType is ignored. This
+	// allows custom arrival processes to be plugged in.
+	NewCustomArrivalProcess func() ArrivalProcess
+}
+
+// Disables the off periods of LooperTypeBursty, see ArrivalConfig.
+const NoBurstOffPeriod time.Duration = -1
+
+func (c *ArrivalConfig) ValidateAndSetDefaults() error {
+	if c.BurstOnDuration == 0 {
+		c.BurstOnDuration = 1 * time.Second
+	}
+
+	if c.BurstOffDuration == 0 {
+		c.BurstOffDuration = 1 * time.Second
+	}
+
+	if c.MMPPRateRatio == 0 {
+		c.MMPPRateRatio = 10
+	}
+
+	if c.MMPPHighStateDuration == 0 {
+		c.MMPPHighStateDuration = 1 * time.Second
+	}
+
+	if c.MMPPLowStateDuration == 0 {
+		c.MMPPLowStateDuration = 9 * time.Second
+	}
+
+	if c.ParetoShape == 0 {
+		c.ParetoShape = 1.5
+	}
+
+	if c.NewCustomArrivalProcess != nil {
+		return nil
+	}
+
+	if _, found := looperTypeNames[c.LooperType]; !found {
+		return fmt.Errorf("unknown looper type %v", c.LooperType)
+	}
+
+	if c.BurstOnDuration < 0 {
+		return errors.New("burst on duration must be positive")
+	}
+
+	if c.MMPPRateRatio < 1 {
+		return errors.New("MMPP rate ratio must be at least 1")
+	}
+
+	if c.MMPPHighStateDuration < 0 || c.MMPPLowStateDuration < 0 {
+		return errors.New("MMPP state durations must be positive")
+	}
+
+	if c.ParetoShape <= 1 {
+		return errors.New("Pareto shape must be greater than 1 for the mean event rate to be defined")
+	}
+
+	return nil
+}
+
+// Creates a new arrival process based on the config. The config must be
+// validated first.
+func (c ArrivalConfig) NewArrivalProcess() ArrivalProcess {
+	if c.NewCustomArrivalProcess != nil {
+		return c.NewCustomArrivalProcess()
+	}
+
+	// Every arrival process gets its own random number generator, as the
+	// global rand functions use a global mutex, which is slow with thousands of
+	// goroutines. The seed comes from the global generator so the processes on
+	// different workers are not correlated.
+	r := rand.New(rand.NewSource(rand.Int63()))
+
+	switch c.LooperType {
+	case LooperTypeUniform:
+		return UniformArrivalProcess{}
+	case LooperTypePoisson:
+		return &PoissonArrivalProcess{rand: r}
+	case LooperTypeBursty:
+		return &BurstyArrivalProcess{
+			OnDuration:  c.BurstOnDuration,
+			OffDuration: max(c.BurstOffDuration, 0),
+			rand:        r,
+		}
+	case LooperTypeMMPP:
+		return &MMPPArrivalProcess{
+			RateRatio:         c.MMPPRateRatio,
+			HighStateDuration: c.MMPPHighStateDuration,
+			LowStateDuration:  c.MMPPLowStateDuration,
+			rand:              r,
+		}
+	case LooperTypePareto:
+		return &ParetoArrivalProcess{
+			Shape: c.ParetoShape,
+			rand:  r,
+		}
+	}
+
+	panic("not implemented")
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * 1000000000)
+}
+
+// Events are evenly spaced.
+type UniformArrivalProcess struct{}
+
+func (UniformArrivalProcess) InterArrivalDuration(eventRate float64) time.Duration {
+	return secondsToDuration(1.0 / eventRate)
+}
+
+// Events arrive independently of each other, which means the inter-arrival
+// durations are exponentially distributed.
+type PoissonArrivalProcess struct {
+	rand *rand.Rand
+}
+
+func (p *PoissonArrivalProcess) InterArrivalDuration(eventRate float64) time.Duration {
+	return secondsToDuration(p.rand.ExpFloat64() / eventRate)
+}
+
+// Alternates between on periods, where events arrive as a Poisson process, and
+// off periods, where no events arrive. See ArrivalConfig for details.
+type BurstyArrivalProcess struct {
+	OnDuration  time.Duration
+	OffDuration time.Duration
+
+	rand *rand.Rand
+
+	// Time elapsed in the current on period.
+	onElapsed time.Duration
+}
+
+func (p *BurstyArrivalProcess) InterArrivalDuration(eventRate float64) time.Duration {
+	onRate := eventRate * float64(p.OnDuration+p.OffDuration) / float64(p.OnDuration)
+
+	var total time.Duration
+	for {
+		gap := secondsToDuration(p.rand.ExpFloat64() / onRate)
+		if p.onElapsed+gap <= p.OnDuration {
+			p.onElapsed += gap
+			return total + gap
+		}
+
+		// The next event falls outside of the current on period. Skip the rest of
+		// the on period and the off period. Since the Poisson process is
+		// memoryless, the next event is sampled again from the start of the next
+		// on period.
+		total += p.OnDuration - p.onElapsed + p.OffDuration
+		p.onElapsed = 0
+	}
+}
+
+// A Poisson process where the rate is modulated by a two-state Markov chain.
+// See ArrivalConfig for details.
+type MMPPArrivalProcess struct {
+	RateRatio         float64
+	HighStateDuration time.Duration
+	LowStateDuration  time.Duration
+
+	rand *rand.Rand
+
+	started        bool
+	inHighState    bool
+	remainingDwell time.Duration
+}
+
+func (p *MMPPArrivalProcess) InterArrivalDuration(eventRate float64) time.Duration {
+	// Solve for the low state rate such that the time-weighted average of the
+	// two rates is the desired event rate.
+	high := p.HighStateDuration.Seconds()
+	low := p.LowStateDuration.Seconds()
+	lowRate := eventRate * (high + low) / (p.RateRatio*high + low)
+
+	// The initial state is drawn from the stationary distribution of the
+	// states, so runs do not always start with a burst. As the durations of the
+	// states are exponentially distributed, the remaining duration of the
+	// initial state has the same distribution as a whole one.
+	if !p.started {
+		p.started = true
+		p.inHighState = p.rand.Float64() < high/(high+low)
+		p.remainingDwell = p.sampleDwell()
+	}
+
+	var total time.Duration
+	for {
+		rate := lowRate
+		if p.inHighState {
+			rate = lowRate * p.RateRatio
+		}
+
+		gap := secondsToDuration(p.rand.ExpFloat64() / rate)
+		if gap < p.remainingDwell {
+			p.remainingDwell -= gap
+			return total + gap
+		}
+
+		// The state changes before the next event. Since the Poisson process is
+		// memoryless, the next event is sampled again from the start of the next
+		// state with the new rate.
+		total += p.remainingDwell
+		p.inHighState = !p.inHighState
+		p.remainingDwell = p.sampleDwell()
+	}
+}
+
+// Returns a random duration of the current state.
+func (p *MMPPArrivalProcess) sampleDwell() time.Duration {
+	meanDwell := p.LowStateDuration
+	if p.inHighState {
+		meanDwell = p.HighStateDuration
+	}
+	return secondsToDuration(p.rand.ExpFloat64() * meanDwell.Seconds())
+}
+
+// The inter-arrival durations follow a Pareto distribution, which generates
+// mostly short gaps with occasional very long gaps. See ArrivalConfig for
+// details.
+type ParetoArrivalProcess struct {
+	Shape float64
+
+	rand *rand.Rand
+}
+
+func (p *ParetoArrivalProcess) InterArrivalDuration(eventRate float64) time.Duration {
+	// The mean of the Pareto distribution is shape * scale / (shape - 1), which
+	// must be equal to 1 / eventRate.
+	scale := (p.Shape - 1) / (p.Shape * eventRate)
+	u := 1 - p.rand.Float64() // (0, 1]
+	return secondsToDuration(scale / math.Pow(u, 1/p.Shape))
+}
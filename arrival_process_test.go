@@ -0,0 +1,171 @@
+package mybench
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newArrivalProcessForTest(t *testing.T, config ArrivalConfig) ArrivalProcess {
+	require.Nil(t, config.ValidateAndSetDefaults())
+	return config.NewArrivalProcess()
+}
+
+func sampleInterArrivalDurations(process ArrivalProcess, eventRate float64, n int) []time.Duration {
+	durations := make([]time.Duration, n)
+	for i := range durations {
+		durations[i] = process.InterArrivalDuration(eventRate)
+	}
+	return durations
+}
+
+func meanEventRate(durations []time.Duration) float64 {
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return float64(len(durations)) / total.Seconds()
+}
+
+// The squared coefficient of variation of the inter-arrival durations, which is
+// 0 for uniform arrivals, 1 for Poisson arrivals, and larger than 1 for bursty
+// arrivals.
+func squaredCoefficientOfVariation(durations []time.Duration) float64 {
+	mean := 0.0
+	for _, d := range durations {
+		mean += d.Seconds()
+	}
+	mean /= float64(len(durations))
+
+	variance := 0.0
+	for _, d := range durations {
+		variance += math.Pow(d.Seconds()-mean, 2)
+	}
+	variance /= float64(len(durations))
+
+	return variance / (mean * mean)
+}
+
+func TestArrivalProcessesMaintainEventRate(t *testing.T) {
+	const eventRate = 100.0
+
+	configs := []ArrivalConfig{
+		{LooperType: LooperTypeUniform},
+		{LooperType: LooperTypePoisson},
+		{LooperType: LooperTypeBursty, BurstOnDuration: 200 * time.Millisecond, BurstOffDuration: 800 * time.Millisecond},
+		{LooperType: LooperTypeMMPP, MMPPHighStateDuration: 10 * time.Millisecond, MMPPLowStateDuration: 90 * time.Millisecond},
+		{LooperType: LooperTypePareto, ParetoShape: 2.5},
+	}
+
+	for _, config := range configs {
+		process := newArrivalProcessForTest(t, config)
+		durations := sampleInterArrivalDurations(process, eventRate, 500000)
+		require.InEpsilon(t, eventRate, meanEventRate(durations), 0.05, config.LooperType.String())
+	}
+}
+
+func TestArrivalProcessesBurstiness(t *testing.T) {
+	const eventRate = 100.0
+	const n = 200000
+
+	uniform := sampleInterArrivalDurations(newArrivalProcessForTest(t, ArrivalConfig{LooperType: LooperTypeUniform}), eventRate, n)
+	require.InDelta(t, 0.0, squaredCoefficientOfVariation(uniform), 1e-9)
+
+	poisson := sampleInterArrivalDurations(newArrivalProcessForTest(t, ArrivalConfig{LooperType: LooperTypePoisson}), eventRate, n)
+	require.InDelta(t, 1.0, squaredCoefficientOfVariation(poisson), 0.05)
+
+	bursty := sampleInterArrivalDurations(newArrivalProcessForTest(t, ArrivalConfig{LooperType: LooperTypeBursty}), eventRate, n)
+	require.Greater(t, squaredCoefficientOfVariation(bursty), 1.5)
+
+	mmpp := sampleInterArrivalDurations(newArrivalProcessForTest(t, ArrivalConfig{LooperType: LooperTypeMMPP}), eventRate, n)
+	require.Greater(t, squaredCoefficientOfVariation(mmpp), 1.5)
+}
+
+func TestBurstyArrivalProcessHasNoEventsInOffPeriods(t *testing.T) {
+	process := newArrivalProcessForTest(t, ArrivalConfig{
+		LooperType:       LooperTypeBursty,
+		BurstOnDuration:  100 * time.Millisecond,
+		BurstOffDuration: 900 * time.Millisecond,
+	})
+
+	var elapsed time.Duration
+	for i := 0; i < 10000; i++ {
+		elapsed += process.InterArrivalDuration(1000)
+		require.LessOrEqual(t, elapsed%time.Second, 100*time.Millisecond)
+	}
+}
+
+func TestBurstyArrivalProcessWithoutOffPeriods(t *testing.T) {
+	config := ArrivalConfig{LooperType: LooperTypeBursty, BurstOffDuration: NoBurstOffPeriod}
+	process := newArrivalProcessForTest(t, config)
+	require.Equal(t, NoBurstOffPeriod, config.BurstOffDuration)
+	require.Equal(t, time.Duration(0), process.(*BurstyArrivalProcess).OffDuration)
+
+	// Without off periods, the arrivals are not burstier than Poisson arrivals.
+	durations := sampleInterArrivalDurations(process, 100, 200000)
+	require.InEpsilon(t, 100, meanEventRate(durations), 0.05)
+	require.InDelta(t, 1, squaredCoefficientOfVariation(durations), 0.1)
+
+	// An unset off duration still defaults to 1s.
+	config = ArrivalConfig{LooperType: LooperTypeBursty}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, time.Second, config.BurstOffDuration)
+}
+
+func TestMMPPArrivalProcessInitialState(t *testing.T) {
+	// With the default durations, the process is in the high state 10% of the
+	// time, so only about 10% of the processes start in it.
+	const n = 2000
+	highStateStarts := 0
+	for i := 0; i < n; i++ {
+		process := &MMPPArrivalProcess{
+			RateRatio:         10,
+			HighStateDuration: time.Second,
+			LowStateDuration:  9 * time.Second,
+			rand:              rand.New(rand.NewSource(int64(i))),
+		}
+		process.InterArrivalDuration(1000000)
+		if process.inHighState {
+			highStateStarts++
+		}
+	}
+
+	require.InDelta(t, 0.1, float64(highStateStarts)/n, 0.03)
+}
+
+func TestCustomArrivalProcess(t *testing.T) {
+	config := ArrivalConfig{
+		LooperType: LooperTypePoisson,
+		NewCustomArrivalProcess: func() ArrivalProcess {
+			return UniformArrivalProcess{}
+		},
+	}
+
+	process := newArrivalProcessForTest(t, config)
+	require.Equal(t, 10*time.Millisecond, process.InterArrivalDuration(100))
+}
+
+func TestArrivalConfigInvalid(t *testing.T) {
+	invalidConfigs := []ArrivalConfig{
+		{LooperType: LooperType(100)},
+		{LooperType: LooperTypePareto, ParetoShape: 1},
+		{LooperType: LooperTypeMMPP, MMPPRateRatio: 0.5},
+		{LooperType: LooperTypeBursty, BurstOnDuration: -time.Second},
+	}
+
+	for _, config := range invalidConfigs {
+		require.NotNil(t, config.ValidateAndSetDefaults(), config.LooperType.String())
+	}
+}
+
+func TestLooperTypeFlagValue(t *testing.T) {
+	var looperType LooperType
+	require.Nil(t, looperType.Set("mmpp"))
+	require.Equal(t, LooperTypeMMPP, looperType)
+	require.Equal(t, "mmpp", looperType.String())
+
+	require.ErrorContains(t, looperType.Set("zipf"), "uniform, poisson, bursty, mmpp, pareto")
+}
@@ -0,0 +1,154 @@
+package mybench
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/atomic"
+)
+
+// Configures how the benchmark workers reconnect after the connection to the
+// database is lost, such as when the database restarts or fails over.
+type ReconnectConfig struct {
+	// If false, the connection is not reopened and all events of the worker
+	// fail after the connection is lost.
+	Enabled bool
+
+	// The time to wait between failed reconnection attempts, which is doubled
+	// after every attempt up to MaxBackoff. Similar to the retries, the backoff
+	// is randomized between 50% and 100% of its value to avoid all workers
+	// reconnecting at the same time. Default: 100ms and 5s.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (c *ReconnectConfig) ValidateAndSetDefaults() error {
+	if c.Backoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("-reconnectbackoff and -reconnectmaxbackoff cannot be negative")
+	}
+
+	if c.Backoff == 0 {
+		c.Backoff = 100 * time.Millisecond
+	}
+
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = 5 * time.Second
+	}
+
+	if c.MaxBackoff < c.Backoff {
+		c.MaxBackoff = c.Backoff
+	}
+
+	return nil
+}
+
+// A period of time during which a workload could not reach the database. It
+// starts with the first event that fails due to a lost connection and ends
+// when the next event succeeds.
+type DowntimeWindow struct {
+	Workload string
+
+	// The time the first event failed with a lost connection.
+	StartTime time.Time
+
+	// The time the first successful event returned. Zero if the database is
+	// still unavailable when the benchmark stops.
+	EndTime time.Time
+
+	// The number of events that failed during the window, including the first
+	// one.
+	FailedEvents int64
+}
+
+func (w DowntimeWindow) Duration() time.Duration {
+	if w.EndTime.IsZero() {
+		return 0
+	}
+
+	return w.EndTime.Sub(w.StartTime)
+}
+
+// Tracks the downtime windows of a workload, which are recorded by all of its
+// workers. Since successful events are recorded far more often than failures,
+// recording a success does not take the lock unless the workload is in a
+// downtime window.
+type availabilityTracker struct {
+	workload string
+	logger   logrus.FieldLogger
+
+	down *atomic.Bool
+
+	mut       *sync.Mutex
+	current   DowntimeWindow
+	completed []DowntimeWindow
+}
+
+func newAvailabilityTracker(workload string) *availabilityTracker {
+	return &availabilityTracker{
+		workload: workload,
+		logger:   logrus.WithField("workload", workload),
+		down:     atomic.NewBool(false),
+		mut:      &sync.Mutex{},
+	}
+}
+
+func (a *availabilityTracker) recordFailure(class ErrorClass) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if a.down.Load() {
+		a.current.FailedEvents++
+		return
+	}
+
+	if class != ErrorClassLostConnection {
+		return
+	}
+
+	a.current = DowntimeWindow{
+		Workload:     a.workload,
+		StartTime:    time.Now(),
+		FailedEvents: 1,
+	}
+	a.down.Store(true)
+	a.logger.Warn("lost connection to the database, recording downtime until the next successful event")
+}
+
+func (a *availabilityTracker) recordSuccess() {
+	if !a.down.Load() {
+		return
+	}
+
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	if !a.down.Load() {
+		return
+	}
+
+	a.current.EndTime = time.Now()
+	a.completed = append(a.completed, a.current)
+	a.down.Store(false)
+	a.logger.WithFields(logrus.Fields{
+		"downtime":     a.current.Duration(),
+		"failedevents": a.current.FailedEvents,
+	}).Warn("database available again")
+}
+
+// Returns the downtime windows that ended since the last call. If
+// includeOngoing is true, the current window is returned as well, with a zero
+// EndTime, which is used when the benchmark stops.
+func (a *availabilityTracker) takeDowntimeWindows(includeOngoing bool) []DowntimeWindow {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	windows := a.completed
+	a.completed = nil
+	if includeOngoing && a.down.Load() {
+		windows = append(windows, a.current)
+	}
+
+	return windows
+}
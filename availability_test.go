@@ -0,0 +1,57 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvailabilityTracker(t *testing.T) {
+	tracker := newAvailabilityTracker("test")
+
+	// Only lost connections start a downtime window.
+	tracker.recordSuccess()
+	tracker.recordFailure(ErrorClassDeadlock)
+	require.Empty(t, tracker.takeDowntimeWindows(true))
+
+	beforeFailure := time.Now()
+	tracker.recordFailure(ErrorClassLostConnection)
+	tracker.recordFailure(ErrorClassLostConnection)
+	tracker.recordFailure(ErrorClassOther)
+
+	// The window is still ongoing.
+	require.Empty(t, tracker.takeDowntimeWindows(false))
+	ongoing := tracker.takeDowntimeWindows(true)
+	require.Len(t, ongoing, 1)
+	require.True(t, ongoing[0].EndTime.IsZero())
+	require.Equal(t, time.Duration(0), ongoing[0].Duration())
+
+	time.Sleep(10 * time.Millisecond)
+	tracker.recordSuccess()
+	tracker.recordSuccess()
+
+	windows := tracker.takeDowntimeWindows(false)
+	require.Len(t, windows, 1)
+	require.Equal(t, "test", windows[0].Workload)
+	require.Equal(t, int64(3), windows[0].FailedEvents)
+	require.False(t, windows[0].StartTime.Before(beforeFailure))
+	require.GreaterOrEqual(t, windows[0].Duration(), 10*time.Millisecond)
+
+	// The windows are only returned once.
+	require.Empty(t, tracker.takeDowntimeWindows(true))
+}
+
+func TestReconnectConfigDefaults(t *testing.T) {
+	config := ReconnectConfig{Enabled: true}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, 100*time.Millisecond, config.Backoff)
+	require.Equal(t, 5*time.Second, config.MaxBackoff)
+
+	config = ReconnectConfig{Enabled: true, Backoff: 10 * time.Second}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, 10*time.Second, config.MaxBackoff)
+
+	config = ReconnectConfig{Backoff: -time.Second}
+	require.NotNil(t, config.ValidateAndSetDefaults())
+}
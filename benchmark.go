@@ -2,13 +2,50 @@ package mybench
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.uber.org/atomic"
 )
 
+// Tracks the total event rate of the benchmark, which can be changed while the
+// benchmark is running. This is shared between the Benchmark and all of its
+// Workloads, which scale the total event rate by their WorkloadScale.
+type eventRateController struct {
+	startTime         time.Time
+	rateControlConfig RateControlConfig
+
+	// The event rate set while the benchmark is running, which replaces the
+	// configured EventRate and LoadProfile. NaN if it has not been set.
+	eventRateOverride *atomic.Float64
+}
+
+func newEventRateController(startTime time.Time, rateControlConfig RateControlConfig) *eventRateController {
+	return &eventRateController{
+		startTime:         startTime,
+		rateControlConfig: rateControlConfig,
+		eventRateOverride: atomic.NewFloat64(math.NaN()),
+	}
+}
+
+// Returns the total desired event rate of the benchmark at the given time.
+func (c *eventRateController) EventRateAt(now time.Time) float64 {
+	eventRate := c.eventRateOverride.Load()
+	if !math.IsNaN(eventRate) {
+		return eventRate
+	}
+
+	return c.rateControlConfig.EventRateAt(now.Sub(c.startTime))
+}
+
+func (c *eventRateController) SetEventRate(eventRate float64) {
+	c.eventRateOverride.Store(eventRate)
+}
+
 type Benchmark struct {
 	BenchmarkConfig
 
@@ -20,10 +57,18 @@ type Benchmark struct {
 	startTime time.Time
 
 	workloads      map[string]AbstractWorkload
+	eventRate      *eventRateController
+	segment        *atomic.String
 	workloadCtx    context.Context
 	workloadCancel context.CancelFunc
 	workloadWg     *sync.WaitGroup
 
+	// Closed when a workload aborts the benchmark due to an error with
+	// ErrorPolicyAbort. abortErr is set before abortCh is closed.
+	abortCh   chan struct{}
+	abortOnce sync.Once
+	abortErr  error
+
 	// Auxiliary services are below
 	dataLogger       *DataLogger
 	dataLoggerCtx    context.Context
@@ -39,9 +84,11 @@ func NewBenchmark(benchmarkName string, benchmarkConfig BenchmarkConfig) (*Bench
 		Name:            benchmarkName,
 		LogInterval:     1 * time.Second,
 		workloads:       make(map[string]AbstractWorkload),
+		segment:         atomic.NewString(""),
 		logger:          logrus.WithField("tag", "benchmark").WithField("benchmark", benchmarkName),
 		workloadWg:      &sync.WaitGroup{},
 		dataLoggerWg:    &sync.WaitGroup{},
+		abortCh:         make(chan struct{}),
 	}
 
 	b.LogRingSize = int((10*time.Minute)/b.LogInterval) + 1
@@ -54,9 +101,15 @@ func NewBenchmark(benchmarkName string, benchmarkConfig BenchmarkConfig) (*Bench
 		Interval:       b.LogInterval,
 		RingSize:       b.LogRingSize,
 		OutputFilename: benchmarkConfig.LogFile,
+		Sinks:          benchmarkConfig.logSinks(),
+		OutputDir:      benchmarkConfig.logOutputDir(),
 		TableName:      benchmarkConfig.LogTable,
 		Note:           benchmarkConfig.Note,
 		Benchmark:      b,
+		ResolvedConfig: benchmarkConfig.ResolvedConfig(),
+		WarmupDuration: benchmarkConfig.WarmupDuration,
+		WarmupEvents:   benchmarkConfig.WarmupEvents,
+		Percentiles:    benchmarkConfig.Percentiles,
 	})
 
 	b.httpServer = NewHttpServer(b, benchmarkConfig.Note, benchmarkConfig.HttpPort)
@@ -80,6 +133,7 @@ func (b *Benchmark) Start() {
 	}
 
 	b.startTime = time.Now()
+	b.eventRate = newEventRateController(b.startTime, b.BenchmarkConfig.RateControlConfig)
 
 	b.workloadWg.Add(len(b.workloads))
 	workerInitializationWg := &sync.WaitGroup{}
@@ -91,6 +145,20 @@ func (b *Benchmark) Start() {
 
 		perWorkloadRateControlConfig.Concurrency = int(math.Ceil(float64(b.BenchmarkConfig.RateControlConfig.Concurrency) * workloadScale))
 		perWorkloadRateControlConfig.EventRate = b.BenchmarkConfig.RateControlConfig.EventRate * workloadScale
+		perWorkloadRateControlConfig.LoadProfile = b.BenchmarkConfig.RateControlConfig.LoadProfile.Scale(workloadScale)
+		if arrivalConfig := workload.Config().Arrival; arrivalConfig != nil {
+			perWorkloadRateControlConfig.ArrivalConfig = *arrivalConfig
+		}
+
+		retryConfig := b.BenchmarkConfig.RetryConfig
+		if workloadRetryConfig := workload.Config().Retry; workloadRetryConfig != nil {
+			retryConfig = *workloadRetryConfig
+		}
+
+		eventTimeout := b.BenchmarkConfig.EventTimeout
+		if workload.Config().EventTimeout != 0 {
+			eventTimeout = workload.Config().EventTimeout
+		}
 
 		// We need to set the RateControlConfig on the workload because the
 		// DataLogger needs to know the concurrency and event rate of each workload.
@@ -99,7 +167,7 @@ func (b *Benchmark) Start() {
 		// goroutine and DataLogger.Run is called in another goroutine. It is thus
 		// possible that DataLogger.Run will try to fetch the RateControlConfig on
 		// the Workload before it is set.
-		workload.FinishInitialization(b.BenchmarkConfig.DatabaseConfig, perWorkloadRateControlConfig)
+		workload.FinishInitialization(b.BenchmarkConfig.DatabaseConfig, perWorkloadRateControlConfig, b.eventRate, b.BenchmarkConfig.ErrorPolicy, retryConfig, eventTimeout, b.abort)
 
 		workerInitializationWg.Add(perWorkloadRateControlConfig.Concurrency)
 		go func(workload AbstractWorkload) {
@@ -128,12 +196,162 @@ func (b *Benchmark) StopAndWait() {
 	// Need to stop the benchmark first before cancelling the data logger so the
 	// data logger can collect all the data.
 	b.workloadCancel()
-	b.workloadWg.Wait()
+	b.waitForWorkloads()
 
 	b.dataLoggerCancel()
 	b.dataLoggerWg.Wait()
 }
 
+// Waits for the workers to finish their current events. If they do not finish
+// within the drain timeout, such as when a query is stuck, their connections
+// are killed. If they are still stuck after another drain timeout, such as when
+// the database is unreachable, they are abandoned, so the benchmark can always
+// be stopped.
+func (b *Benchmark) waitForWorkloads() {
+	done := make(chan struct{})
+	go func() {
+		b.workloadWg.Wait()
+		close(done)
+	}()
+
+	drainTimeout := b.BenchmarkConfig.DrainTimeout
+	if drainTimeout == 0 {
+		<-done
+		return
+	}
+
+	select {
+	case <-done:
+		return
+	case <-time.After(drainTimeout):
+	}
+
+	b.logger.WithField("draintimeout", drainTimeout).Warn("workers did not stop within the drain timeout, killing their connections")
+	for _, workload := range b.workloads {
+		workload.KillConnections()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(drainTimeout):
+		b.logger.Error("workers did not stop after killing their connections, stopping the benchmark without them")
+	}
+}
+
+// Returns a channel that is closed when the benchmark is aborted due to an
+// error returned by an Event with ErrorPolicyAbort. The benchmark must still
+// be stopped with StopAndWait.
+func (b *Benchmark) Aborted() <-chan struct{} {
+	return b.abortCh
+}
+
+// Returns the error that aborted the benchmark, or nil if the benchmark is not
+// aborted.
+func (b *Benchmark) Err() error {
+	select {
+	case <-b.abortCh:
+		return b.abortErr
+	default:
+		return nil
+	}
+}
+
+func (b *Benchmark) abort(err error) {
+	b.abortOnce.Do(func() {
+		b.abortErr = err
+		close(b.abortCh)
+	})
+}
+
+// Changes the total event rate of the benchmark while it is running. The
+// change is picked up by every BenchmarkWorker at its next outer loop
+// iteration. This replaces the configured EventRate and LoadProfile for the
+// rest of the benchmark. Since the number of workers is fixed when the
+// benchmark starts, the workers may not be able to keep up if the event rate
+// is increased significantly.
+func (b *Benchmark) SetEventRate(eventRate float64) error {
+	if b.startTime.IsZero() {
+		return errors.New("benchmark not started")
+	}
+
+	if b.BenchmarkConfig.RateControlConfig.ClosedLoop {
+		return errors.New("the event rate cannot be changed in the closed-loop mode")
+	}
+
+	if eventRate < 0 || math.IsNaN(eventRate) || math.IsInf(eventRate, 0) {
+		return fmt.Errorf("invalid event rate %v", eventRate)
+	}
+
+	b.eventRate.SetEventRate(eventRate)
+	b.logger.WithField("eventrate", eventRate).Info("event rate changed")
+	for _, workload := range b.workloads {
+		b.warnIfWorkersCannotKeepUp(workload)
+	}
+	return nil
+}
+
+// Changes the WorkloadScale of a workload while the benchmark is running.
+// Similar to SetEventRate, the change is picked up by every BenchmarkWorker of
+// the workload at its next outer loop iteration.
+func (b *Benchmark) SetWorkloadScale(workloadName string, workloadScale float64) error {
+	if b.startTime.IsZero() {
+		return errors.New("benchmark not started")
+	}
+
+	if b.BenchmarkConfig.RateControlConfig.ClosedLoop {
+		return errors.New("the event rate cannot be changed in the closed-loop mode")
+	}
+
+	workload, found := b.workloads[workloadName]
+	if !found {
+		return fmt.Errorf("workload %s not found", workloadName)
+	}
+
+	if workloadScale < 0 || workloadScale > 1 || math.IsNaN(workloadScale) {
+		return fmt.Errorf("WorkloadScale must be between 0 and 1, got %v", workloadScale)
+	}
+
+	workload.SetWorkloadScale(workloadScale)
+	b.logger.WithFields(logrus.Fields{
+		"workload":      workloadName,
+		"workloadscale": workloadScale,
+	}).Info("workload scale changed")
+	b.warnIfWorkersCannotKeepUp(workload)
+	return nil
+}
+
+// Labels the intervals logged from now on with the given segment, which is
+// recorded in the segment column of the log table. This allows different
+// parts of a benchmark, such as the steps of a saturation search, to be
+// analyzed separately. An empty label clears the segment.
+func (b *Benchmark) SetSegment(segment string) {
+	b.segment.Store(segment)
+}
+
+// Returns the current total desired event rate of the benchmark.
+func (b *Benchmark) EventRate() float64 {
+	return b.eventRate.EventRateAt(time.Now())
+}
+
+func (b *Benchmark) warnIfWorkersCannotKeepUp(workload AbstractWorkload) {
+	rateControlConfig := workload.RateControlConfig()
+	perWorkerRate := workload.DesiredEventRate(time.Now()) / float64(rateControlConfig.Concurrency)
+	if perWorkerRate > rateControlConfig.MaxEventRatePerWorker {
+		b.logger.WithFields(logrus.Fields{
+			"workload":      workload.Config().Name,
+			"perworkerrate": perWorkerRate,
+			"workermaxrate": rateControlConfig.MaxEventRatePerWorker,
+		}).Warn("event rate per worker exceeds the maximum event rate per worker and the workers may not keep up, as the concurrency cannot be changed while the benchmark is running")
+	}
+}
+
 func (b *Benchmark) DataSnapshots() []*DataSnapshot {
 	return b.dataLogger.DataSnapshots()
 }
+
+// Returns the summary of the run once the benchmark is stopped with
+// StopAndWait, or nil if there is no data in the measurement phase. See
+// RunSummary.
+func (b *Benchmark) Summary() *RunSummary {
+	return b.dataLogger.Summary()
+}
@@ -6,6 +6,8 @@ import (
 	"math"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,45 +18,200 @@ type BenchmarkConfig struct {
 	Bench    bool
 	Load     bool
 	Duration time.Duration
-	LogFile  string
 	LogTable string
 	Note     string
 
+	// The files the data is logged to, see SQLiteSink, CSVSink, and
+	// JSONLinesSink. At least one of them or a LogSink must be specified.
+	LogFile      string
+	LogCSVFile   string
+	LogJSONLFile string
+
+	// Custom sinks the data is logged to in addition to the log files.
+	LogSinks []DataSink
+
+	// The benchmark starts with a warmup phase, which lasts for either the
+	// WarmupDuration or until WarmupEvents events are executed. Only one of
+	// them can be specified. The data collected during the warmup is marked in
+	// the phase column of the log table and excluded from summaries.
+	WarmupDuration time.Duration
+	WarmupEvents   int64
+
+	// The path to a YAML or JSON config file with flag values. See
+	// BenchmarkConfig.ParseFlags.
+	ConfigFile string
+
 	DatabaseConfig DatabaseConfig
 
 	RateControlConfig RateControlConfig
 
+	SaturationSearchConfig SaturationSearchConfig
+
+	// Decides what happens when an Event returns an error. All errors are
+	// counted in the log table regardless of the policy.
+	ErrorPolicy ErrorPolicy
+
+	// Retries events that fail with retryable errors before they are handled
+	// according to the ErrorPolicy.
+	RetryConfig RetryConfig
+
+	// The maximum time an event can take before its queries are killed with
+	// KILL QUERY and it is counted as a query timeout. 0 disables the timeout.
+	// Can be overridden per workload via WorkloadConfig.EventTimeout.
+	EventTimeout time.Duration
+
+	// The maximum time to wait for the workers to finish their events once the
+	// benchmark is stopped, after which their connections are killed. 0 waits
+	// indefinitely.
+	DrainTimeout time.Duration
+
+	// The latency percentiles logged for each interval and shown in the
+	// monitoring UI. Default: DefaultPercentiles.
+	Percentiles Percentiles
+
 	HttpPort int
 }
 
 func NewBenchmarkConfig() *BenchmarkConfig {
 	config := &BenchmarkConfig{}
 
+	flag.StringVar(&config.ConfigFile, "config", "", "path to a YAML or JSON config file mapping flag names to values (flags on the command line take precedence)")
 	flag.BoolVar(&config.Load, "load", false, "load the data before the benchmark")
 	flag.BoolVar(&config.Bench, "bench", false, "run the benchmark")
 	flag.DurationVar(&config.Duration, "duration", 0, "duration of the benchmark")
-	flag.StringVar(&config.LogFile, "log", "data.sqlite", "the path to the log file")
-	flag.StringVar(&config.LogTable, "logtable", "", "the table name in the sqlite file to record to (default: based on the start time in RFC3399)")
+	flag.DurationVar(&config.WarmupDuration, "warmup", 0, "duration of the warmup phase at the start of the benchmark, which is excluded from summaries (default: no warmup)")
+	flag.Int64Var(&config.WarmupEvents, "warmupevents", 0, "end the warmup phase after this number of events instead of after a duration (default: no warmup)")
+	flag.StringVar(&config.LogFile, "log", "data.sqlite", "the path to the sqlite log file, which can be set to an empty string if -logcsv or -logjsonl is specified")
+	flag.StringVar(&config.LogCSVFile, "logcsv", "", "the path to a CSV file to also log the data to, which is appended to if it exists")
+	flag.StringVar(&config.LogJSONLFile, "logjsonl", "", "the path to a JSON Lines file to also log the data to, which is appended to if it exists")
+	flag.StringVar(&config.LogTable, "logtable", "", "the table name in the sqlite file to record to, which also identifies the run in the -logcsv and -logjsonl files (default: based on the start time in RFC3399)")
 	flag.StringVar(&config.Note, "note", "", "a note to include in the meta table entry for this run")
+	flag.Var(&config.Percentiles, "percentiles", "comma separated latency percentiles to log and plot, such as 50,95,99,99.9,99.99,max (default: 25,50,75,90,99)")
 
 	flag.StringVar(&config.DatabaseConfig.Host, "host", "", "database host name")
 	flag.IntVar(&config.DatabaseConfig.Port, "port", 3306, "database port (default: 3306)")
 	flag.StringVar(&config.DatabaseConfig.User, "user", "root", "database user (default: root)")
 	flag.StringVar(&config.DatabaseConfig.Pass, "pass", "", "database password (default: empty)")
 	flag.StringVar(&config.DatabaseConfig.Database, "db", "mybench", "database name (default: mybench)")
+	flag.StringVar(&config.DatabaseConfig.Socket, "socket", "", "path to the Unix socket of the database, used instead of -host and -port")
+	flag.BoolVar(&config.DatabaseConfig.TLS.Enabled, "tls", false, "connect to the database with TLS, failing if the server does not support TLS")
+	flag.StringVar(&config.DatabaseConfig.TLS.CAFile, "tlsca", "", "path to the CA certificate to verify the server certificate (default: system CA certificates)")
+	flag.StringVar(&config.DatabaseConfig.TLS.CertFile, "tlscert", "", "path to the client certificate for TLS")
+	flag.StringVar(&config.DatabaseConfig.TLS.KeyFile, "tlskey", "", "path to the client key for TLS")
+	flag.BoolVar(&config.DatabaseConfig.TLS.SkipVerify, "tlsskipverify", false, "do not verify the server certificate for TLS")
+	flag.StringVar(&config.DatabaseConfig.TLS.ServerName, "tlsservername", "", "the name to verify the server certificate against for TLS (default: -host, required with -socket unless -tlsskipverify is set)")
+	flag.Var(&config.DatabaseConfig.Replicas, "replicas", "comma separated replicas of the database as host[:port][=weight], which workloads can read from via WorkerContext.Reader (default: -port and a weight of 1)")
+	flag.Var(&config.DatabaseConfig.ReplicaPolicy, "replicapolicy", "how reads are spread across the replicas: roundrobin or weighted (default: roundrobin)")
+	flag.BoolVar(&config.DatabaseConfig.Reconnect.Enabled, "reconnect", true, "reconnect to the database after the connection is lost, such as during a failover")
+	flag.DurationVar(&config.DatabaseConfig.Reconnect.Backoff, "reconnectbackoff", 100*time.Millisecond, "time to wait after a failed reconnection attempt, doubled after every attempt (default: 100ms)")
+	flag.DurationVar(&config.DatabaseConfig.Reconnect.MaxBackoff, "reconnectmaxbackoff", 5*time.Second, "maximum time to wait between reconnection attempts (default: 5s)")
+	flag.IntVar(&config.DatabaseConfig.Churn.EventsPerConnection, "churn", 0, "open new connections after this number of events in each worker to measure the connection setup latency, 1 opens new connections for every event (default: 0, disabled)")
+	flag.StringVar(&config.DatabaseConfig.Churn.SetupQuery, "churnsetupsql", "", "query to execute after opening new connections with -churn, which is not included in the connection setup latency")
 	flag.IntVar(&config.DatabaseConfig.ConnectionMultiplier, "connectionmultiplier", 1, "number of database connections per parallel worker (default: 1)")
 	flag.BoolVar(&config.DatabaseConfig.ClientMultiStatements, "clientmultistatements", false, "Enable support for CLIENT_MULTI_STATEMENTS on the connection")
+	flag.Var(&config.DatabaseConfig.ClientCapabilities, "clientcaps", "comma separated client capabilities to request: found_rows, ignore_space, multi_statements, multi_results, ps_multi_results, or local_files")
+	flag.StringVar(&config.DatabaseConfig.Charset, "charset", "", "charset of the connections (default: client default)")
+	flag.StringVar(&config.DatabaseConfig.Collation, "collation", "", "collation of the connections (default: default of the charset)")
+	flag.Var(&config.DatabaseConfig.Compression, "compression", "protocol compression of the connections: none, zlib, or zstd (default: none)")
+	flag.Var(&config.DatabaseConfig.ConnectionAttributes, "connattrs", "comma separated connection attributes as key=value, or a JSON object for values that contain commas")
+	flag.Var(&config.DatabaseConfig.InitStatements, "initsql", "An SQL statement to execute on every connection after it is opened, such as SET SESSION transaction_isolation = 'READ-COMMITTED' (repeat for multiple statements)")
+	flag.BoolVar(&config.DatabaseConfig.TextProtocol, "textprotocol", false, "execute the queries of Connection.ExecuteCached via the text protocol with the arguments interpolated on the client instead of via cached prepared statements")
+	flag.IntVar(&config.DatabaseConfig.StmtCacheSize, "stmtcachesize", DefaultStmtCacheSize, "the maximum number of statements ExecuteCached keeps prepared per connection, after which the least recently used one is closed")
 
 	flag.Float64Var(&config.RateControlConfig.EventRate, "eventrate", 1000, "target event rate of the benchmark in requests per second (default: 1000)")
 	flag.IntVar(&config.RateControlConfig.Concurrency, "concurrency", 0, "number of parallel workers to use during the benchmark (default: auto)")
 	flag.Float64Var(&config.RateControlConfig.MaxEventRatePerWorker, "workermaxrate", 100, "maximum event rate per worker (default: 100)")
 	flag.Float64Var(&config.RateControlConfig.OuterLoopRate, "outerlooprate", 50, "desired rate of outer loop that batches events -- advanced option (default: 50)")
+	flag.Var(&config.RateControlConfig.LoadProfile, "loadprofile", "vary the event rate over time with comma separated segments such as ramp:500:5000:10m,hold:20m,step:8000 (overrides -eventrate, see mybench.LoadProfile)")
+	flag.BoolVar(&config.RateControlConfig.ClosedLoop, "closedloop", false, "run each worker in a closed loop, calling the next event as soon as the previous one finishes, to measure the maximum throughput (requires -concurrency, ignores -eventrate)")
+	flag.DurationVar(&config.RateControlConfig.ThinkTime, "thinktime", 0, "time each worker waits between events in the closed-loop mode (default: 0)")
+	flag.BoolVar(&config.RateControlConfig.RandomThinkTime, "randomthinktime", false, "use an exponentially distributed think time with -thinktime as the mean in the closed-loop mode")
+	flag.Var(&config.RateControlConfig.LooperType, "looper", "arrival process of the events: uniform, poisson, bursty, mmpp, or pareto (default: uniform)")
+	flag.DurationVar(&config.RateControlConfig.BurstOnDuration, "burston", 1*time.Second, "duration of the on periods of the bursty looper (default: 1s)")
+	flag.DurationVar(&config.RateControlConfig.BurstOffDuration, "burstoff", 1*time.Second, "duration of the off periods of the bursty looper, a negative duration disables the off periods (default: 1s)")
+	flag.Float64Var(&config.RateControlConfig.MMPPRateRatio, "mmppratio", 10, "ratio of the event rate in the high state to the low state of the mmpp looper (default: 10)")
+	flag.DurationVar(&config.RateControlConfig.MMPPHighStateDuration, "mmpphigh", 1*time.Second, "mean duration of the high state of the mmpp looper (default: 1s)")
+	flag.DurationVar(&config.RateControlConfig.MMPPLowStateDuration, "mmpplow", 9*time.Second, "mean duration of the low state of the mmpp looper (default: 9s)")
+	flag.Float64Var(&config.RateControlConfig.ParetoShape, "paretoshape", 1.5, "shape of the inter-arrival distribution of the pareto looper, must be > 1, lower is heavier-tailed (default: 1.5)")
+
+	flag.Var(&config.SaturationSearchConfig.Mode, "searchmode", "search for the highest event rate that meets the SLO with the step or binary search and stop the benchmark once done (default: disabled)")
+	flag.Float64Var(&config.SaturationSearchConfig.StartRate, "searchstart", 0, "first event rate of the saturation search (default: -eventrate)")
+	flag.Float64Var(&config.SaturationSearchConfig.MaxRate, "searchmax", 0, "highest event rate of the saturation search")
+	flag.Float64Var(&config.SaturationSearchConfig.StepRate, "searchstep", 0, "event rate increase per step of the step search (default: a tenth of the search range)")
+	flag.Float64Var(&config.SaturationSearchConfig.Precision, "searchprecision", 0.02, "the binary search stops once the remaining range is smaller than this fraction of -searchmax (default: 0.02)")
+	flag.DurationVar(&config.SaturationSearchConfig.SettleDuration, "searchsettle", 30*time.Second, "time to let the database settle at each event rate of the saturation search before measuring (default: 30s)")
+	flag.DurationVar(&config.SaturationSearchConfig.HoldDuration, "searchhold", 60*time.Second, "time to measure each event rate of the saturation search for (default: 60s)")
+	flag.Float64Var(&config.SaturationSearchConfig.SLO.Percentile, "slopercentile", 99, "latency percentile of the SLO of the saturation search, which must be one of -percentiles (default: 99)")
+	flag.DurationVar(&config.SaturationSearchConfig.SLO.Latency, "slolatency", 0, "maximum latency at -slopercentile of the SLO of the saturation search")
+	flag.Float64Var(&config.SaturationSearchConfig.SLO.MinRateRatio, "slominrateratio", 0.98, "minimum ratio of the achieved to the desired event rate of the SLO of the saturation search (default: 0.98)")
+
+	flag.Var(&config.ErrorPolicy, "onerror", "what to do when an event returns an error: continue, stopworker, or abort (default: continue)")
+	flag.IntVar(&config.RetryConfig.MaxAttempts, "retries", 0, "maximum number of attempts of an event that fails with a retryable error, including the first attempt (default: 0, no retries)")
+	flag.DurationVar(&config.RetryConfig.Backoff, "retrybackoff", 0, "time to wait before the first retry, doubled after every retry (default: 0)")
+	flag.DurationVar(&config.RetryConfig.MaxBackoff, "retrymaxbackoff", 0, "maximum time to wait between retries (default: -retrybackoff)")
+	flag.Var(&config.RetryConfig.RetryableErrorCodes, "retrycodes", "comma separated MySQL error codes to retry (default: 1213,1205)")
+	flag.DurationVar(&config.EventTimeout, "eventtimeout", 0, "kill the queries of events that take longer than this and count them as query timeouts (default: 0, no timeout)")
+	flag.DurationVar(&config.DrainTimeout, "draintimeout", 30*time.Second, "time to wait for running events when stopping the benchmark before killing their connections, 0 waits indefinitely (default: 30s)")
+	flag.Var(&config.RetryConfig.LatencyMode, "retrylatency", "latency recorded for retried events: endtoend, including all attempts and backoffs, or firstattempt (default: endtoend)")
 
 	flag.IntVar(&config.HttpPort, "httpport", 8005, "port of the monitoring UI")
 
 	return config
 }
 
+// Parses the command line flags and then applies the values in the config file
+// specified via -config, if any. Flags explicitly set on the command line take
+// precedence over the config file. Since the config file is applied to the
+// flags, it can also set any custom flags defined by the benchmark. Thus, this
+// should be called instead of flag.Parse, after all custom flags are defined.
+func (c *BenchmarkConfig) ParseFlags() error {
+	flag.Parse()
+
+	if c.ConfigFile == "" {
+		return nil
+	}
+
+	return applyConfigFile(flag.CommandLine, c.ConfigFile)
+}
+
+// Returns the values of all command line flags after the config file is
+// applied, which can be written to a config file to replay the run. Returns
+// nil if the flags are not parsed, such as when the BenchmarkConfig is not
+// created via NewBenchmarkConfig.
+func (c *BenchmarkConfig) ResolvedConfig() map[string]string {
+	if !flag.Parsed() {
+		return nil
+	}
+
+	return resolvedFlagValues(flag.CommandLine)
+}
+
+// Returns the sinks of the -logcsv and -logjsonl files and the LogSinks. The
+// sink of the -log file is created by the DataLogger.
+func (c *BenchmarkConfig) logSinks() []DataSink {
+	var sinks []DataSink
+	if c.LogCSVFile != "" {
+		sinks = append(sinks, NewCSVSink(c.LogCSVFile))
+	}
+
+	if c.LogJSONLFile != "" {
+		sinks = append(sinks, NewJSONLinesSink(c.LogJSONLFile))
+	}
+
+	return append(sinks, c.LogSinks...)
+}
+
+// The resolved config and the summary are written next to the first log file.
+func (c *BenchmarkConfig) logOutputDir() string {
+	for _, filename := range []string{c.LogFile, c.LogCSVFile, c.LogJSONLFile} {
+		if filename != "" {
+			return filepath.Dir(filename)
+		}
+	}
+
+	return ""
+}
+
 func (c *BenchmarkConfig) Config() BenchmarkConfig {
 	return *c
 }
@@ -64,12 +221,51 @@ func (c *BenchmarkConfig) ValidateAndSetDefaults() error {
 		return errors.New("must only specify one of -bench or -load")
 	}
 
-	if c.DatabaseConfig.Host == "" {
-		return errors.New("must specify -host")
+	if c.DatabaseConfig.Host == "" && c.DatabaseConfig.Socket == "" {
+		return errors.New("must specify -host or -socket")
+	}
+
+	err := c.DatabaseConfig.TLS.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.DatabaseConfig.TLS.Enabled {
+		_, err = c.DatabaseConfig.tlsServerName()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.DatabaseConfig.Reconnect.ValidateAndSetDefaults()
+	if err != nil {
+		return err
+	}
+
+	err = c.DatabaseConfig.validateAndSetReplicaDefaults()
+	if err != nil {
+		return err
+	}
+
+	err = c.DatabaseConfig.Churn.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.LogFile == "" && c.LogCSVFile == "" && c.LogJSONLFile == "" && len(c.LogSinks) == 0 {
+		return errors.New("must specify at least one of -log, -logcsv, or -logjsonl")
+	}
+
+	if c.WarmupDuration < 0 || c.WarmupEvents < 0 {
+		return errors.New("-warmup and -warmupevents cannot be negative")
+	}
+
+	if c.WarmupDuration > 0 && c.WarmupEvents > 0 {
+		return errors.New("must only specify one of -warmup or -warmupevents")
 	}
 
-	if c.LogFile == "" {
-		return errors.New("must specify log filename")
+	if c.Duration > 0 && c.WarmupDuration >= c.Duration {
+		return errors.New("-warmup must be shorter than -duration")
 	}
 
 	if c.DatabaseConfig.ConnectionMultiplier != 1 && c.RateControlConfig.Concurrency == 0 {
@@ -89,17 +285,97 @@ func (c *BenchmarkConfig) ValidateAndSetDefaults() error {
 		c.RateControlConfig.OuterLoopRate = 50
 	}
 
+	err = c.RateControlConfig.ArrivalConfig.ValidateAndSetDefaults()
+	if err != nil {
+		return err
+	}
+
+	err = c.RetryConfig.ValidateAndSetDefaults()
+	if err != nil {
+		return err
+	}
+
+	if c.EventTimeout < 0 || c.DrainTimeout < 0 {
+		return errors.New("-eventtimeout and -draintimeout cannot be negative")
+	}
+
+	err = c.Percentiles.ValidateAndSetDefaults()
+	if err != nil {
+		return err
+	}
+
+	if c.SaturationSearchConfig.Enabled() {
+		err = c.SaturationSearchConfig.ValidateAndSetDefaults(c.RateControlConfig.EventRate, c.Percentiles)
+		if err != nil {
+			return err
+		}
+
+		// The search changes the event rate and stops the benchmark by itself.
+		if c.RateControlConfig.ClosedLoop || c.RateControlConfig.LoadProfile.Enabled() {
+			return errors.New("cannot specify -closedloop or -loadprofile with -searchmode")
+		}
+
+		if c.Duration > 0 {
+			return errors.New("cannot specify -duration with -searchmode, as the benchmark stops once the search is complete")
+		}
+
+		c.RateControlConfig.EventRate = c.SaturationSearchConfig.StartRate
+	}
+
+	if c.RateControlConfig.ClosedLoop {
+		// In the closed-loop mode, the number of workers determines the load
+		// instead of the event rate, so it cannot be calculated.
+		if c.RateControlConfig.Concurrency <= 0 {
+			return errors.New("must specify -concurrency with -closedloop")
+		}
+
+		if c.RateControlConfig.LoadProfile.Enabled() {
+			return errors.New("cannot specify -loadprofile with -closedloop")
+		}
+
+		if c.RateControlConfig.ThinkTime < 0 {
+			return errors.New("-thinktime cannot be negative")
+		}
+	} else {
+		err = c.validateAndSetOpenLoopConcurrency()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Calculates the Concurrency needed to drive the EventRate, if it is not
+// specified.
+func (c *BenchmarkConfig) validateAndSetOpenLoopConcurrency() error {
+	// With a load profile, the workers must be able to drive the peak rate of
+	// the profile. Similarly, the workers must be able to drive the highest rate
+	// of the saturation search.
+	peakEventRate := c.RateControlConfig.PeakEventRate()
+	if c.SaturationSearchConfig.Enabled() {
+		peakEventRate = c.SaturationSearchConfig.MaxRate
+	}
+
+	if peakEventRate <= 0 {
+		if c.RateControlConfig.LoadProfile.Enabled() {
+			return errors.New("the load profile must have a non-zero rate")
+		}
+
+		return errors.New("-eventrate must be positive")
+	}
+
 	// If Concurrency is not specified, calculate it from EventRate and MaxEventRatePerWorker
 	if c.RateControlConfig.Concurrency == 0 {
 		if c.DatabaseConfig.ConnectionMultiplier > 1 {
 			return errors.New("if ConnectionMultiplier is specified, Concurrency must be specified")
 		}
 
-		c.RateControlConfig.Concurrency = int(math.Ceil(c.RateControlConfig.EventRate / float64(c.RateControlConfig.MaxEventRatePerWorker)))
+		c.RateControlConfig.Concurrency = int(math.Ceil(peakEventRate / float64(c.RateControlConfig.MaxEventRatePerWorker)))
 	}
 
-	if c.RateControlConfig.Concurrency > int(c.RateControlConfig.EventRate) {
-		c.RateControlConfig.Concurrency = int(c.RateControlConfig.EventRate)
+	if c.RateControlConfig.Concurrency > int(peakEventRate) {
+		c.RateControlConfig.Concurrency = int(peakEventRate)
 		logrus.Warnf("Concurrency is too high for the given EventRate. Reducing Concurrency to %d", c.RateControlConfig.Concurrency)
 	}
 
@@ -173,23 +449,55 @@ func Run(benchmarkInterface BenchmarkInterface) error {
 	// is configured).
 	quitCh := make(chan struct{})
 
+	// The benchmark can be stopped by multiple sources, such as a signal
+	// arriving while the benchmark is being stopped due to its duration.
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			benchmark.StopAndWait()
+			logrus.Info("benchmark stopped")
+
+			if summary := benchmark.Summary(); summary != nil {
+				err := summary.WriteTable(os.Stdout)
+				if err != nil {
+					logrus.WithError(err).Error("failed to write the summary")
+				}
+			}
+			close(quitCh)
+		})
+	}
+
 	go func() {
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 
 		s := <-c
 		logrus.WithField("signal", s.String()).Warn("received termination signal")
-		benchmark.StopAndWait()
-		logrus.Info("benchmark stopped")
-		close(quitCh)
+		stop()
+	}()
+
+	go func() {
+		<-benchmark.Aborted()
+		logrus.WithError(benchmark.Err()).Error("benchmark aborted")
+		stop()
 	}()
 
-	if config.Duration > time.Duration(0) {
+	if config.SaturationSearchConfig.Enabled() {
+		logrus.Infof("running %v saturation search", config.SaturationSearchConfig.Mode)
+		go func() {
+			result, err := benchmark.RunSaturationSearch(config.SaturationSearchConfig)
+			if err != nil {
+				logrus.WithError(err).Error("saturation search did not complete")
+			} else {
+				logSaturationSearchResult(result)
+			}
+			stop()
+		}()
+	} else if config.Duration > time.Duration(0) {
 		logrus.Infof("running benchmark for %v", config.Duration)
 		go func() {
 			time.Sleep(config.Duration)
-			benchmark.StopAndWait()
-			close(quitCh)
+			stop()
 		}()
 	} else {
 		logrus.Info("running benchmark indefinitely")
@@ -197,5 +505,5 @@ func Run(benchmarkInterface BenchmarkInterface) error {
 
 	<-quitCh
 
-	return nil
+	return benchmark.Err()
 }
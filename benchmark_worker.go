@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync"
 	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // This is the object type that holds the thread-local context data for each
@@ -16,7 +18,9 @@ import (
 // on each benchmark for their workloads. A common use case is to store a
 // statement object in an user-defined struct.
 type WorkerContext[T any] struct {
-	// A worker-specific connection object to the database.
+	// A worker-specific connection object to the database. If replicas are
+	// configured, this is the connection to the primary. Use Writer and Reader
+	// to split reads and writes across the primary and the replicas.
 	Conn *Connection
 
 	// A worker-specific Rand object. This is needed because the global rand.*
@@ -34,28 +38,115 @@ type WorkerContext[T any] struct {
 	// called, it is done so inside a trace region called `Event`. Nested regions
 	// can be created by the user.
 	TraceCtx context.Context
+
+	targets *workerTargets
+}
+
+// Returns the connection to the primary, which should be used for writes. The
+// latency of the event is attributed to the primary.
+func (c WorkerContext[T]) Writer() *Connection {
+	return c.targets.writer()
+}
+
+// Returns the connection to a replica chosen with the configured
+// ReplicaPolicy, or to the primary if there are no replicas. The latency of the
+// event is attributed to the replica. If an event calls Reader and Writer
+// multiple times, the latency is attributed to the last connection returned.
+func (c WorkerContext[T]) Reader() *Connection {
+	return c.targets.reader()
 }
 
 // A single goroutine worker that loops and benchmarks MySQL
 type BenchmarkWorker[ContextDataT any] struct {
 	workloadIface WorkloadInterface[ContextDataT]
-	looper        *DiscretizedLooper
+	looper        Looper
 	onlineHist    *OnlineHistogram
 	context       WorkerContext[ContextDataT]
+
+	// The response time of the events, measured from their intended start
+	// time. See EventStat.ResponseTime.
+	responseHist *OnlineHistogram
+
+	// The latency per target of the connections, indexed the same way as
+	// workerTargets.conns. Only used if replicas are configured.
+	targetHists []*OnlineHistogram
+
+	// The time taken to open the connections in the connection churn mode.
+	// Only used if the connection churn mode is enabled.
+	connectHist *OnlineHistogram
+
+	// The lag of the looper behind the desired schedule. Nil in the
+	// closed-loop mode.
+	looperLag *OnlineLooperLag
+
+	// The range and precision of the histograms of the latency and the
+	// response time. See WorkloadConfig.Histogram.
+	histogramConfig HistogramConfig
+
+	errorPolicy     ErrorPolicy
+	retryConfig     RetryConfig
+	reconnectConfig ReconnectConfig
+	churnConfig     ChurnConfig
+	availability    *availabilityTracker
+
+	// Kills the queries of the events that exceed the event timeout, and the
+	// connections of the worker if it does not stop in time. The eventTimer is
+	// nil if there is no event timeout.
+	killer     *queryKiller
+	eventTimer *eventTimer
+
+	logger logrus.FieldLogger
+
+	// The time taken by the first attempt of the last event, which is recorded
+	// instead of the end-to-end latency with RetryLatencyFirstAttempt.
+	firstAttemptLatency time.Duration
+
+	// Only the first error of each class is logged, as the errors can be very
+	// frequent. All errors are counted in the log table.
+	loggedErrorClasses map[ErrorClass]bool
+	loggedChurnError   bool
+
+	// The number of events since the connections were last opened.
+	eventsOnConnection int
 }
 
-func NewBenchmarkWorker[ContextDataT any](workloadIface WorkloadInterface[ContextDataT], databaseConfig DatabaseConfig, rateControlConfig RateControlConfig) (*BenchmarkWorker[ContextDataT], error) {
+// The workloadEventRate function returns the desired event rate of the whole
+// workload at a given time, which can vary during the benchmark. Each worker
+// drives an equal share of it. If eventTimeout is not 0, the queries of the
+// events that take longer are killed.
+func NewBenchmarkWorker[ContextDataT any](workloadIface WorkloadInterface[ContextDataT], databaseConfig DatabaseConfig, rateControlConfig RateControlConfig, errorPolicy ErrorPolicy, retryConfig RetryConfig, eventTimeout time.Duration, availability *availabilityTracker, workloadEventRate func(time.Time) float64, histogramConfig HistogramConfig) (*BenchmarkWorker[ContextDataT], error) {
 	conn, err := databaseConfig.Connection()
 	if err != nil {
 		return nil, err
 	}
 
+	rand := NewRand()
+	targets, err := newWorkerTargets(databaseConfig, conn, rand)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	worker := &BenchmarkWorker[ContextDataT]{
 		workloadIface: workloadIface,
 		context: WorkerContext[ContextDataT]{
-			Conn: conn,
-			Rand: NewRand(),
+			Conn:    conn,
+			Rand:    rand,
+			targets: targets,
 		},
+		errorPolicy:        errorPolicy,
+		retryConfig:        retryConfig,
+		reconnectConfig:    databaseConfig.Reconnect,
+		churnConfig:        databaseConfig.Churn,
+		availability:       availability,
+		logger:             logrus.WithField("workload", workloadIface.Config().Name),
+		loggedErrorClasses: make(map[ErrorClass]bool),
+		killer:             newQueryKiller(targets),
+		histogramConfig:    histogramConfig,
+	}
+
+	if eventTimeout > 0 {
+		worker.eventTimer = newEventTimer(eventTimeout, worker.killer, worker.logger)
 	}
 
 	worker.context.Data, err = workloadIface.NewContextData(conn)
@@ -63,38 +154,221 @@ func NewBenchmarkWorker[ContextDataT any](workloadIface WorkloadInterface[Contex
 		return nil, err
 	}
 
-	looper := &DiscretizedLooper{
-		EventRate:       rateControlConfig.EventRate / float64(rateControlConfig.Concurrency),
-		OuterLoopRate:   rateControlConfig.OuterLoopRate,
-		DebugIdentifier: workloadIface.Config().Name,
-		Event: func(traceCtx context.Context) error {
-			worker.context.TraceCtx = traceCtx
-			defer func() { worker.context.TraceCtx = nil }()
-			return worker.workloadIface.Event(worker.context)
-		},
-		TraceEvent:     worker.traceEvent,
-		TraceOuterLoop: worker.traceOuterLoop,
+	event := func(traceCtx context.Context) error {
+		worker.context.TraceCtx = traceCtx
+		worker.context.targets.current = 0
+		defer func() { worker.context.TraceCtx = nil }()
+
+		if worker.eventTimer == nil {
+			return worker.runEvent(traceCtx)
+		}
+
+		worker.eventTimer.start()
+		return worker.eventTimer.stop(worker.runEvent(traceCtx))
 	}
 
-	// Set it at the end, because the worker.looper is an interface and we cannot
-	// customize it like above anymore.
-	worker.looper = looper
+	if rateControlConfig.ClosedLoop {
+		worker.looper = &ClosedLooper{
+			ThinkTime:        rateControlConfig.ThinkTime,
+			RandomThinkTime:  rateControlConfig.RandomThinkTime,
+			DebugIdentifier:  workloadIface.Config().Name,
+			Event:            event,
+			TraceEvent:       worker.traceEvent,
+			HandleEventError: worker.handleEventError,
+		}
+	} else {
+		worker.looperLag = NewOnlineLooperLag()
+		worker.looper = &DiscretizedLooper{
+			EventRate:       rateControlConfig.EventRate / float64(rateControlConfig.Concurrency),
+			OuterLoopRate:   rateControlConfig.OuterLoopRate,
+			LooperType:      rateControlConfig.LooperType,
+			ArrivalProcess:  rateControlConfig.NewArrivalProcess(),
+			DebugIdentifier: workloadIface.Config().Name,
+			EventRateFunc: func(now time.Time) float64 {
+				return workloadEventRate(now) / float64(rateControlConfig.Concurrency)
+			},
+			Event:              event,
+			TraceEvent:         worker.traceEvent,
+			TraceOuterLoop:     worker.traceOuterLoop,
+			HandleEventError:   worker.handleEventError,
+			TraceSkippedEvents: worker.traceSkippedEvents,
+		}
+	}
 
 	return worker, nil
 }
 
+// Calls the Event of the workload, retrying it if configured.
+func (b *BenchmarkWorker[ContextDataT]) runEvent(traceCtx context.Context) error {
+	if !b.retryConfig.Enabled() {
+		return b.workloadIface.Event(b.context)
+	}
+
+	retries, firstAttemptLatency, err := b.retryConfig.run(traceCtx, func() error {
+		return b.workloadIface.Event(b.context)
+	}, b.context.Rand.Float64)
+
+	b.firstAttemptLatency = firstAttemptLatency
+	if retries > 0 {
+		b.onlineHist.RecordRetries(retries)
+	}
+
+	return err
+}
+
 func (b *BenchmarkWorker[ContextDataT]) Run(ctx context.Context, workerInitializationWg *sync.WaitGroup, startTime time.Time) error {
 	// TODO: kind of weird that the conn is opened in NewBenchmarkWorker but closed here. This should maybe be fixed
 	defer b.context.Conn.Close()
-	b.onlineHist = NewOnlineHistogram(startTime)
+	defer b.context.targets.close()
+	defer b.killer.close()
+	b.onlineHist = NewOnlineHistogramWithConfig(startTime, b.histogramConfig)
+	b.responseHist = NewOnlineHistogramWithConfig(startTime, b.histogramConfig)
+	if len(b.context.targets.conns) > 1 {
+		b.targetHists = make([]*OnlineHistogram, len(b.context.targets.conns))
+		for i := range b.targetHists {
+			b.targetHists[i] = NewOnlineHistogramWithConfig(startTime, b.histogramConfig)
+		}
+	}
+	if b.churnConfig.Enabled() {
+		b.connectHist = NewOnlineHistogramWithConfig(startTime, b.histogramConfig)
+	}
 	workerInitializationWg.Done()
 	return b.looper.Run(ctx)
 }
 
 func (b *BenchmarkWorker[ContextDataT]) traceEvent(stat EventStat) {
-	b.onlineHist.RecordValue(stat.TimeTaken.Microseconds())
+	b.availability.recordSuccess()
+
+	latency := stat.TimeTaken
+	if b.retryConfig.Enabled() && b.retryConfig.LatencyMode == RetryLatencyFirstAttempt {
+		latency = b.firstAttemptLatency
+	}
+
+	b.onlineHist.RecordDuration(latency)
+	if b.targetHists != nil {
+		b.targetHists[b.context.targets.current].RecordDuration(latency)
+	}
+
+	// The delay before the event started is added to the recorded latency, so
+	// the response time follows the RetryLatencyMode as well.
+	b.responseHist.RecordDuration(latency + stat.ResponseTime - stat.TimeTaken)
+
+	b.churnConnections()
+}
+
+// Counts the error and decides if the worker should keep going based on the
+// ErrorPolicy. If the connection is lost, the worker reconnects before
+// continuing. In the open-loop mode, the events that should have started while
+// reconnecting are skipped and counted as lost connections by
+// traceSkippedEvents, as they would have failed as well.
+func (b *BenchmarkWorker[ContextDataT]) handleEventError(ctx context.Context, err error) error {
+	class := ClassifyError(err)
+	b.recordError(class)
+
+	if b.errorPolicy != ErrorPolicyContinue {
+		return err
+	}
+
+	if !b.loggedErrorClasses[class] {
+		b.loggedErrorClasses[class] = true
+		b.logger.WithError(err).WithField("class", class.String()).Warn("event failed, further errors of this class are only counted in the log table")
+	}
+
+	if class == ErrorClassLostConnection && b.reconnectConfig.Enabled {
+		b.reconnect(ctx)
+		b.eventsOnConnection = 0
+		if looper, ok := b.looper.(*DiscretizedLooper); ok {
+			looper.SkipEventsUntil(time.Now())
+		}
+		return nil
+	}
+
+	b.churnConnections()
+	return nil
+}
+
+func (b *BenchmarkWorker[ContextDataT]) traceSkippedEvents(n int64) {
+	for i := int64(0); i < n; i++ {
+		b.recordError(ErrorClassLostConnection)
+	}
+}
+
+func (b *BenchmarkWorker[ContextDataT]) recordError(class ErrorClass) {
+	b.onlineHist.RecordError(class)
+	if b.targetHists != nil {
+		b.targetHists[b.context.targets.current].RecordError(class)
+	}
+	b.availability.recordFailure(class)
+}
+
+// Opens the connections again once the configured number of events were
+// executed on them in the connection churn mode. This is called after the
+// latency of the event is recorded, so the time taken to open the connections
+// is only recorded in the connectHist. If opening the connections fails, the
+// error is counted in the connectHist and the next event fails and reconnects.
+func (b *BenchmarkWorker[ContextDataT]) churnConnections() {
+	if !b.churnConfig.Enabled() {
+		return
+	}
+
+	b.eventsOnConnection++
+	if b.eventsOnConnection < b.churnConfig.EventsPerConnection {
+		return
+	}
+	b.eventsOnConnection = 0
+
+	// Only opening the new connections is recorded, which excludes closing the
+	// old connections and the SetupQuery.
+	connectTime, err := b.context.targets.reconnect()
+	if err == nil {
+		b.connectHist.RecordValue(connectTime.Microseconds())
+		b.killer.update(b.context.targets)
+	}
+	if err == nil && b.churnConfig.SetupQuery != "" {
+		_, err = b.context.Conn.Execute(b.churnConfig.SetupQuery)
+	}
+
+	if err == nil {
+		b.context.Data, err = b.workloadIface.NewContextData(b.context.Conn)
+	}
+
+	if err != nil {
+		b.connectHist.RecordError(ClassifyError(err))
+		if !b.loggedChurnError {
+			b.loggedChurnError = true
+			b.logger.WithError(err).Warn("failed to open new connections, further errors are only counted in the log table")
+		}
+	}
+}
+
+// Reopens the connections with a backoff until it succeeds or the benchmark is
+// stopped. The context data is created again, as it may hold objects tied to
+// the old connection, such as prepared statements.
+func (b *BenchmarkWorker[ContextDataT]) reconnect(ctx context.Context) {
+	backoff := b.reconnectConfig.Backoff
+	for {
+		_, err := b.context.targets.reconnect()
+		if err == nil {
+			b.killer.update(b.context.targets)
+			b.context.Data, err = b.workloadIface.NewContextData(b.context.Conn)
+			if err == nil {
+				b.logger.Debug("reconnected to the database")
+				return
+			}
+		}
+
+		b.logger.WithError(err).Debug("failed to reconnect to the database")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(jitterBackoff(backoff, b.context.Rand.Float64)):
+		}
+
+		backoff = min(2*backoff, b.reconnectConfig.MaxBackoff)
+	}
 }
 
 func (b *BenchmarkWorker[ContextDataT]) traceOuterLoop(stat OuterLoopStat) {
-	// TODO: Consider detecting loop overruns and back pressure
+	b.looperLag.Record(stat)
 }
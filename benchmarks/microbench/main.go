@@ -4,11 +4,10 @@ import (
 	"flag"
 
 	"github.com/Shopify/mybench"
-	"github.com/go-mysql-org/go-mysql/client"
 )
 
 type MicroBenchContextData struct {
-	Statement *client.Stmt
+	Statement *mybench.Stmt
 }
 
 func NewMicroBenchTable(idGen *mybench.AutoIncrementGenerator, indexCardinality int) mybench.Table {
@@ -69,9 +68,12 @@ func main() {
 	flag.Float64Var(&benchmarkInterface.PointSelectRateScale, "point-select", 0.0, "event rate scaling for the point select workload")
 	flag.Float64Var(&benchmarkInterface.BatchPointSelectRateScale, "batch-point-select", 0.0, "event rate scaling for the batch point select workload")
 
-	flag.Parse()
+	err := benchmarkInterface.ParseFlags()
+	if err != nil {
+		panic(err)
+	}
 
-	err := mybench.Run(benchmarkInterface)
+	err = mybench.Run(benchmarkInterface)
 	if err != nil {
 		panic(err)
 	}
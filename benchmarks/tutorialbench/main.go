@@ -11,9 +11,12 @@ func main() {
 		BenchmarkConfig: mybench.NewBenchmarkConfig(),
 	}
 	flag.Int64Var(&benchmarkInterface.InitialNumRows, "numrows", 10_000_000, "the number of rows to load into the database")
-	flag.Parse()
+	err := benchmarkInterface.ParseFlags()
+	if err != nil {
+		panic(err)
+	}
 
-	err := mybench.Run(benchmarkInterface)
+	err = mybench.Run(benchmarkInterface)
 	if err != nil {
 		panic(err)
 	}
@@ -4,11 +4,10 @@ import (
 	"fmt"
 
 	"github.com/Shopify/mybench"
-	"github.com/go-mysql-org/go-mysql/client"
 )
 
 type ReadSingleChirpContext struct {
-	stmt *client.Stmt
+	stmt *mybench.Stmt
 }
 
 type ReadSingleChirp struct {
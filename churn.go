@@ -0,0 +1,40 @@
+package mybench
+
+import (
+	"errors"
+)
+
+// Configures the connection churn mode, in which the benchmark workers close
+// their connections and open new ones periodically. This models applications
+// that connect per request, such as through a proxy, where the cost of setting
+// up connections matters. The time taken to open the connections, which
+// includes the TCP and TLS handshakes, the authentication, and the -initsql
+// statements, is recorded separately from the latency of the events. Closing
+// the old connections and the SetupQuery are not recorded.
+type ChurnConfig struct {
+	// The number of events after which the connections of a worker are opened
+	// again. If 1, the worker opens new connections after every event. If 0,
+	// the connection churn mode is disabled.
+	EventsPerConnection int
+
+	// An optional query executed after the connections are opened, such as
+	// the health check query of a connection pool. Its latency is neither
+	// included in the connection setup latency nor in the event latency.
+	SetupQuery string
+}
+
+func (c ChurnConfig) Enabled() bool {
+	return c.EventsPerConnection > 0
+}
+
+func (c ChurnConfig) Validate() error {
+	if c.EventsPerConnection < 0 {
+		return errors.New("-churn cannot be negative")
+	}
+
+	if c.SetupQuery != "" && !c.Enabled() {
+		return errors.New("must specify -churn to use -churnsetupsql")
+	}
+
+	return nil
+}
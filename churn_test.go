@@ -0,0 +1,101 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A workload that counts how often its context data is created, which happens
+// every time the connections are opened again.
+type churnWorkloadForTest struct {
+	WorkloadConfig
+	newContextDataCalls int
+}
+
+func (w *churnWorkloadForTest) Event(WorkerContext[NoContextData]) error {
+	return nil
+}
+
+func (w *churnWorkloadForTest) NewContextData(*Connection) (NoContextData, error) {
+	w.newContextDataCalls++
+	return NoContextData{}, nil
+}
+
+func TestChurnConnections(t *testing.T) {
+	workload := &churnWorkloadForTest{WorkloadConfig: WorkloadConfig{Name: "churn"}}
+	databaseConfig := DatabaseConfig{NoConnection: true, Churn: ChurnConfig{EventsPerConnection: 3}}
+	rateControlConfig := RateControlConfig{EventRate: 100, Concurrency: 1, OuterLoopRate: 50}
+
+	worker, err := NewBenchmarkWorker[NoContextData](workload, databaseConfig, rateControlConfig, ErrorPolicyContinue, RetryConfig{}, 0, newAvailabilityTracker("churn"), func(time.Time) float64 { return 100 }, DefaultHistogramConfig)
+	require.Nil(t, err)
+	require.Equal(t, 1, workload.newContextDataCalls)
+
+	// Only set up the histograms, as the looper is not needed.
+	startTime := time.Now()
+	worker.onlineHist = NewOnlineHistogram(startTime)
+	worker.responseHist = NewOnlineHistogram(startTime)
+	worker.connectHist = NewOnlineHistogram(startTime)
+
+	for i := 0; i < 7; i++ {
+		worker.traceEvent(EventStat{TimeTaken: time.Millisecond})
+	}
+
+	require.Equal(t, 3, workload.newContextDataCalls)
+	require.Equal(t, int64(7), worker.onlineHist.Swap(func(*ExtendedHdrHistogram) {}).hist.TotalCount())
+
+	// Opening connections without a database takes less than the 1us minimum of
+	// the histogram.
+	connectHist := worker.connectHist.Swap(func(*ExtendedHdrHistogram) {})
+	require.Equal(t, int64(2), connectHist.hist.TotalCount()+connectHist.underflowCount)
+}
+
+func TestChurnConnectLatencyExcludesSetupQuery(t *testing.T) {
+	s := newTestMySQLServer(t)
+
+	workload := &churnWorkloadForTest{WorkloadConfig: WorkloadConfig{Name: "churn"}}
+	databaseConfig := s.databaseConfig()
+	databaseConfig.Churn = ChurnConfig{EventsPerConnection: 1, SetupQuery: "DO SLEEP(0.2)"}
+	rateControlConfig := RateControlConfig{EventRate: 100, Concurrency: 1, OuterLoopRate: 50}
+
+	worker, err := NewBenchmarkWorker[NoContextData](workload, databaseConfig, rateControlConfig, ErrorPolicyContinue, RetryConfig{}, 0, newAvailabilityTracker("churn"), func(time.Time) float64 { return 100 }, DefaultHistogramConfig)
+	require.Nil(t, err)
+	defer worker.context.Conn.Close()
+
+	startTime := time.Now()
+	worker.onlineHist = NewOnlineHistogram(startTime)
+	worker.responseHist = NewOnlineHistogram(startTime)
+	worker.connectHist = NewOnlineHistogram(startTime)
+
+	worker.traceEvent(EventStat{TimeTaken: time.Millisecond})
+	require.Equal(t, []string{"DO SLEEP(0.2)"}, s.connections()[1].Queries())
+
+	connectHist := worker.connectHist.Swap(func(*ExtendedHdrHistogram) {})
+	require.Equal(t, int64(1), connectHist.hist.TotalCount())
+	require.Less(t, connectHist.hist.Max(), int64(200000))
+}
+
+func TestChurnDisabled(t *testing.T) {
+	workload := &churnWorkloadForTest{WorkloadConfig: WorkloadConfig{Name: "churn"}}
+	rateControlConfig := RateControlConfig{EventRate: 100, Concurrency: 1, OuterLoopRate: 50}
+
+	worker, err := NewBenchmarkWorker[NoContextData](workload, DatabaseConfig{NoConnection: true}, rateControlConfig, ErrorPolicyContinue, RetryConfig{}, 0, newAvailabilityTracker("churn"), func(time.Time) float64 { return 100 }, DefaultHistogramConfig)
+	require.Nil(t, err)
+
+	worker.onlineHist = NewOnlineHistogram(time.Now())
+	worker.responseHist = NewOnlineHistogram(time.Now())
+	for i := 0; i < 5; i++ {
+		worker.traceEvent(EventStat{TimeTaken: time.Millisecond})
+	}
+
+	require.Nil(t, worker.connectHist)
+	require.Equal(t, 1, workload.newContextDataCalls)
+}
+
+func TestChurnConfigValidate(t *testing.T) {
+	require.Nil(t, ChurnConfig{}.Validate())
+	require.Nil(t, ChurnConfig{EventsPerConnection: 1, SetupQuery: "SELECT 1"}.Validate())
+	require.NotNil(t, ChurnConfig{EventsPerConnection: -1}.Validate())
+	require.ErrorContains(t, ChurnConfig{SetupQuery: "SELECT 1"}.Validate(), "-churn")
+}
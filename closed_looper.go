@@ -0,0 +1,108 @@
+package mybench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/trace"
+	"time"
+)
+
+// A Looper repeatedly calls an Event function until the context is cancelled.
+type Looper interface {
+	Run(ctx context.Context) error
+}
+
+// A closed-loop looper, which calls the next Event as soon as the previous
+// Event finishes, optionally after waiting for a think time. Unlike the
+// DiscretizedLooper, the event rate is not controlled and is instead determined
+// by the latency of the events. This is used to measure the maximum throughput
+// achievable with a given concurrency.
+type ClosedLooper struct {
+	// The time to wait between the end of an event and the start of the next
+	// one. If 0, the next event is called immediately.
+	ThinkTime time.Duration
+
+	// If true, the think time is exponentially distributed with ThinkTime as
+	// the mean, instead of being fixed.
+	RandomThinkTime bool
+
+	DebugIdentifier string
+
+	// The context passed is a trace context from runtime/trace package
+	Event      func(context.Context) error
+	TraceEvent func(EventStat)
+
+	// See DiscretizedLooper.HandleEventError.
+	HandleEventError func(context.Context, error) error
+}
+
+func (l *ClosedLooper) Run(ctx context.Context) error {
+	// Create the task name outside the loop as doing it in the loop will cause
+	// allocations and therefore slow down performance of the loop.
+	traceTaskName := fmt.Sprintf("ClosedLoopIteration%s", l.DebugIdentifier)
+	eventRegionName := fmt.Sprintf("Event%s", l.DebugIdentifier)
+
+	r := rand.New(rand.NewSource(rand.Int63()))
+
+	var thinkTimer *time.Timer
+	if l.ThinkTime > 0 {
+		// The timer is reused to avoid allocations in the loop. It is created
+		// stopped and is only started after every event.
+		thinkTimer = time.NewTimer(l.ThinkTime)
+		thinkTimer.Stop()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		err := func() error {
+			traceTaskCtx, traceTask := trace.NewTask(ctx, traceTaskName)
+			defer traceTask.End()
+
+			eventStart := time.Now()
+			region := trace.StartRegion(traceTaskCtx, eventRegionName)
+			err := l.Event(traceTaskCtx)
+			region.End()
+			if err != nil {
+				return handleEventError(ctx, l.HandleEventError, err)
+			}
+
+			if l.TraceEvent != nil {
+				timeTaken := time.Since(eventStart)
+				l.TraceEvent(EventStat{
+					TimeTaken:    timeTaken,
+					ResponseTime: timeTaken,
+				})
+			}
+
+			return nil
+		}()
+
+		if err != nil {
+			return err
+		}
+
+		if thinkTimer == nil {
+			continue
+		}
+
+		thinkTime := l.ThinkTime
+		if l.RandomThinkTime {
+			thinkTime = time.Duration(r.ExpFloat64() * float64(l.ThinkTime))
+		}
+
+		// Wait for the think time, unless the benchmark is stopped in the
+		// meantime, as the think time could be long.
+		thinkTimer.Reset(thinkTime)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-thinkTimer.C:
+		}
+	}
+}
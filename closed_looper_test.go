@@ -0,0 +1,95 @@
+package mybench
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runClosedLooperForTest(t *testing.T, looper *ClosedLooper, duration time.Duration) int {
+	numEvents := 0
+	looper.Event = func(context.Context) error {
+		numEvents++
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+
+	require.Nil(t, looper.Run(ctx))
+	return numEvents
+}
+
+func TestClosedLooperWithoutThinkTime(t *testing.T) {
+	looper := &ClosedLooper{}
+	numEvents := runClosedLooperForTest(t, looper, 100*time.Millisecond)
+
+	// Without a think time, the events are called back to back, far faster than
+	// any reasonable open-loop rate.
+	require.Greater(t, numEvents, 10000)
+}
+
+func TestClosedLooperWithThinkTime(t *testing.T) {
+	looper := &ClosedLooper{ThinkTime: 10 * time.Millisecond}
+	numEvents := runClosedLooperForTest(t, looper, 500*time.Millisecond)
+
+	// Sleeping may take longer than requested, but never shorter.
+	require.LessOrEqual(t, numEvents, 51)
+	require.Greater(t, numEvents, 20)
+}
+
+func TestClosedLooperWithRandomThinkTime(t *testing.T) {
+	looper := &ClosedLooper{ThinkTime: 10 * time.Millisecond, RandomThinkTime: true}
+	numEvents := runClosedLooperForTest(t, looper, 500*time.Millisecond)
+
+	require.Greater(t, numEvents, 10)
+	require.Less(t, numEvents, 200)
+}
+
+func TestClosedLooperStopsDuringThinkTime(t *testing.T) {
+	looper := &ClosedLooper{ThinkTime: time.Hour}
+
+	start := time.Now()
+	numEvents := runClosedLooperForTest(t, looper, 50*time.Millisecond)
+	require.Equal(t, 1, numEvents)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestClosedLooperReturnsEventError(t *testing.T) {
+	looper := &ClosedLooper{
+		Event: func(context.Context) error {
+			return context.DeadlineExceeded
+		},
+	}
+
+	require.ErrorIs(t, looper.Run(context.Background()), context.DeadlineExceeded)
+}
+
+func TestClosedLoopConfigValidation(t *testing.T) {
+	newConfig := func() BenchmarkConfig {
+		return BenchmarkConfig{
+			Bench:          true,
+			LogFile:        "data.sqlite",
+			DatabaseConfig: DatabaseConfig{Host: "localhost", ConnectionMultiplier: 1},
+			RateControlConfig: RateControlConfig{
+				ClosedLoop: true,
+			},
+		}
+	}
+
+	config := newConfig()
+	require.ErrorContains(t, config.ValidateAndSetDefaults(), "-concurrency")
+
+	config = newConfig()
+	config.RateControlConfig.Concurrency = 64
+	require.Nil(t, config.ValidateAndSetDefaults())
+	// The concurrency must not be capped by the (ignored) event rate.
+	require.Equal(t, 64, config.RateControlConfig.Concurrency)
+
+	config = newConfig()
+	config.RateControlConfig.Concurrency = 64
+	require.Nil(t, config.RateControlConfig.LoadProfile.Set("step:100"))
+	require.ErrorContains(t, config.ValidateAndSetDefaults(), "-loadprofile")
+}
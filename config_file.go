@@ -0,0 +1,129 @@
+package mybench
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Flags that are never applied from or written to a config file. The config
+// flag itself is excluded to avoid recursively loading config files, and the
+// password is excluded so it does not end up in the log files next to the
+// benchmark results. Specify -pass on the command line when replaying a run.
+var configFileExcludedFlags = map[string]bool{
+	"config": true,
+	"pass":   true,
+}
+
+// Applies the values in a YAML (or JSON, which is a subset of YAML) config
+// file to the flags defined in the flag set. The config file is a flat mapping
+// of flag names (without the leading -) to values:
+//
+//	host: mysql-1
+//	eventrate: 3000
+//	duration: 10m
+//	numrows: 1000000
+//
+// Since the config file maps onto flags, it can configure everything that
+// can be configured on the command line, including custom flags defined by the
+// benchmark application. If a value is a list, flag.Value.Set is called once
+// for every item in the list.
+//
+// Flags that are explicitly set on the command line take precedence over the
+// values in the config file, so this must be called after the flag set is
+// parsed.
+func applyConfigFile(flagSet *flag.FlagSet, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var root yaml.Node
+	err = yaml.Unmarshal(data, &root)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", filename, err)
+	}
+
+	// An empty file has no content node.
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	mapping := root.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("config file %s must contain a mapping of flag names to values", filename)
+	}
+
+	explicitlySet := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		explicitlySet[f.Name] = true
+	})
+
+	for i := 0; i < len(mapping.Content); i += 2 {
+		name := mapping.Content[i].Value
+		valueNode := mapping.Content[i+1]
+
+		if configFileExcludedFlags[name] {
+			return fmt.Errorf("config file %s: %s cannot be specified in a config file", filename, name)
+		}
+
+		if flagSet.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown flag %s", filename, name)
+		}
+
+		if explicitlySet[name] {
+			continue
+		}
+
+		values, err := configFileValues(valueNode)
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", filename, name, err)
+		}
+
+		for _, value := range values {
+			err = flagSet.Set(name, value)
+			if err != nil {
+				return fmt.Errorf("config file %s: %s: %w", filename, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func configFileValues(node *yaml.Node) ([]string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []string{node.Value}, nil
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, errors.New("list items must be scalar values")
+			}
+			values = append(values, item.Value)
+		}
+		return values, nil
+	}
+
+	return nil, errors.New("value must be a scalar or a list of scalars")
+}
+
+// Returns the values of all flags defined in the flag set, after the command
+// line and the config file are both applied. The output can be written to a
+// config file and passed back via -config to replay a run exactly.
+func resolvedFlagValues(flagSet *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if configFileExcludedFlags[f.Name] {
+			return
+		}
+
+		values[f.Name] = f.Value.String()
+	})
+
+	return values
+}
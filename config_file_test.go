@@ -0,0 +1,117 @@
+package mybench
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+type testListFlag []string
+
+func (l *testListFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *testListFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func writeConfigFileForTest(t *testing.T, name, content string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	require.Nil(t, os.WriteFile(filename, []byte(content), 0644))
+	return filename
+}
+
+func TestApplyConfigFileYAMLWithCommandLineOverride(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	host := flagSet.String("host", "", "")
+	eventRate := flagSet.Float64("eventrate", 1000, "")
+	duration := flagSet.Duration("duration", 0, "")
+	numRows := flagSet.Int64("numrows", 10, "")
+	list := &testListFlag{}
+	flagSet.Var(list, "list", "")
+
+	require.Nil(t, flagSet.Parse([]string{"-eventrate", "2000"}))
+
+	filename := writeConfigFileForTest(t, "config.yaml", `
+host: mysql-1
+eventrate: 3000
+duration: 10m
+numrows: 1000000
+list:
+  - a
+  - b
+`)
+
+	require.Nil(t, applyConfigFile(flagSet, filename))
+	require.Equal(t, "mysql-1", *host)
+	require.Equal(t, 2000.0, *eventRate) // command line takes precedence
+	require.Equal(t, 10*time.Minute, *duration)
+	require.Equal(t, int64(1000000), *numRows)
+	require.Equal(t, testListFlag{"a", "b"}, *list)
+}
+
+func TestApplyConfigFileJSON(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	host := flagSet.String("host", "", "")
+	bench := flagSet.Bool("bench", false, "")
+	require.Nil(t, flagSet.Parse([]string{}))
+
+	filename := writeConfigFileForTest(t, "config.json", `{"host": "mysql-2", "bench": true}`)
+
+	require.Nil(t, applyConfigFile(flagSet, filename))
+	require.Equal(t, "mysql-2", *host)
+	require.True(t, *bench)
+}
+
+func TestApplyConfigFileErrors(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	flagSet.Int("port", 3306, "")
+	flagSet.String("pass", "", "")
+	require.Nil(t, flagSet.Parse([]string{}))
+
+	filename := writeConfigFileForTest(t, "unknown.yaml", "unknownflag: 1\n")
+	require.ErrorContains(t, applyConfigFile(flagSet, filename), "unknown flag unknownflag")
+
+	filename = writeConfigFileForTest(t, "invalid.yaml", "port: abc\n")
+	require.ErrorContains(t, applyConfigFile(flagSet, filename), "port")
+
+	filename = writeConfigFileForTest(t, "pass.yaml", "pass: hunter2\n")
+	require.ErrorContains(t, applyConfigFile(flagSet, filename), "pass cannot be specified")
+
+	filename = writeConfigFileForTest(t, "list.yaml", "- port\n")
+	require.ErrorContains(t, applyConfigFile(flagSet, filename), "mapping")
+}
+
+func TestResolvedFlagValuesCanBeReplayed(t *testing.T) {
+	newFlagSet := func() (*flag.FlagSet, *string, *float64) {
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		host := flagSet.String("host", "", "")
+		eventRate := flagSet.Float64("eventrate", 1000, "")
+		flagSet.String("pass", "", "")
+		return flagSet, host, eventRate
+	}
+
+	flagSet, _, _ := newFlagSet()
+	require.Nil(t, flagSet.Parse([]string{"-host", "mysql-1", "-eventrate", "1e6", "-pass", "hunter2"}))
+
+	values := resolvedFlagValues(flagSet)
+	require.Equal(t, map[string]string{"host": "mysql-1", "eventrate": "1e+06"}, values)
+
+	data, err := yaml.Marshal(values)
+	require.Nil(t, err)
+	filename := writeConfigFileForTest(t, "replay.yaml", string(data))
+
+	replayFlagSet, host, eventRate := newFlagSet()
+	require.Nil(t, replayFlagSet.Parse([]string{}))
+	require.Nil(t, applyConfigFile(replayFlagSet, filename))
+	require.Equal(t, "mysql-1", *host)
+	require.Equal(t, 1e6, *eventRate)
+}
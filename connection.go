@@ -1,22 +1,124 @@
 package mybench
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"os"
 
 	"github.com/go-mysql-org/go-mysql/client"
 	"github.com/go-mysql-org/go-mysql/mysql"
 )
 
+// Configures TLS for the connections to the database, which allows the cost of
+// encrypted connections to be measured.
+//
+// There is no preferred mode that falls back to plaintext if the server does
+// not support TLS. The client decides to use TLS before it reads the
+// capabilities of the server, so falling back would take a second connection
+// attempt after matching the error message of the client. A benchmark that
+// silently measures plaintext connections when encrypted ones were requested
+// would also be misleading, so the connections fail instead.
+type TLSConfig struct {
+	// If true, all connections use TLS and fail if the server does not support
+	// TLS.
+	Enabled bool
+
+	// The name the server certificate is verified against. Default: the host
+	// of the database. Must be set to verify the server certificate when
+	// connecting via a Unix socket, which has no host name.
+	ServerName string
+
+	// The path to the PEM encoded CA certificate used to verify the server
+	// certificate. If not set, the system CA certificates are used.
+	CAFile string
+
+	// The paths to the PEM encoded client certificate and key, if the server
+	// requires a client certificate.
+	CertFile string
+	KeyFile  string
+
+	// If true, the server certificate is not verified. The connection is still
+	// encrypted.
+	SkipVerify bool
+}
+
+func (c TLSConfig) Validate() error {
+	if !c.Enabled && (c.CAFile != "" || c.CertFile != "" || c.KeyFile != "" || c.SkipVerify || c.ServerName != "") {
+		return errors.New("must specify -tls to use -tlsca, -tlscert, -tlskey, -tlsservername, or -tlsskipverify")
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("must specify both -tlscert and -tlskey")
+	}
+
+	return nil
+}
+
+// Creates the tls.Config for connecting to the given server name.
+func (c TLSConfig) tlsConfig(serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: c.SkipVerify,
+	}
+
+	if c.CAFile != "" {
+		caPem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", c.CAFile)
+		}
+	}
+
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
 // The database config object that can be turned into a single connection
 // (without connection pooling).
 type DatabaseConfig struct {
-	// TODO: Unix socket
 	Host     string
 	Port     int
 	User     string
 	Pass     string
 	Database string
 
+	// The path to the Unix socket of the database. If set, it is used instead
+	// of the Host and Port, which avoids the TCP overhead when the benchmark
+	// runs on the same host as the database.
+	Socket string
+
+	TLS TLSConfig
+
+	// The replicas of the database, which can be used for reads via
+	// WorkerContext.Reader. The replicas use the same credentials, database,
+	// and TLS config as the primary.
+	Replicas Replicas
+
+	// Decides which replica WorkerContext.Reader returns.
+	ReplicaPolicy ReplicaPolicy
+
+	// Configures how the benchmark workers reconnect after the connection is
+	// lost.
+	Reconnect ReconnectConfig
+
+	// Configures the benchmark workers to open new connections periodically to
+	// measure the cost of setting up connections.
+	Churn ChurnConfig
+
 	// If this is set, a connection will not be established. This is useful for
 	// non-database-related tests such as selfbench.
 	// TODO: this is kind of a hack...
@@ -28,13 +130,49 @@ type DatabaseConfig struct {
 	// number of open connections to the database to assess any performance impact
 	// specific to the overall number of open database connections.
 	//
-	// Note: this feature does not work on all benchmarks at this moment. It only
-	// works with benchmarks that uses the mybench.Connection.GetRoundRobinConnection
-	// function to get their connections and execute queries.
+	// This applies to Connection.Execute, Connection.ExecuteSelectStreaming,
+	// Connection.ExecuteCached, and the statements returned by
+	// Connection.Prepare, which are prepared on every underlying connection. An
+	// underlying connection is used until its transaction ends, so transactions
+	// are not split across connections. Methods of the embedded client.Conn not
+	// listed here always use the first underlying connection.
 	ConnectionMultiplier int
 
 	// Enable CLIENT_MULTI_STATEMENTS options on the client
 	ClientMultiStatements bool
+
+	// Additional client capabilities requested for every connection, such as
+	// CLIENT_FOUND_ROWS.
+	ClientCapabilities ClientCapabilities
+
+	// The charset and collation of the connections. If not set, the defaults
+	// of the client and the server are used.
+	Charset   string
+	Collation string
+
+	// The protocol compression of the connections. Default: none.
+	Compression Compression
+
+	// Connection attributes sent to the server in addition to the ones set by
+	// the client.
+	ConnectionAttributes ConnectionAttributes
+
+	// SQL statements executed on every underlying connection after it is
+	// opened, including the multiplied connections and connections reopened
+	// after the connection is lost. This is useful to set session variables,
+	// such as SET SESSION transaction_isolation = 'READ-COMMITTED'.
+	InitStatements SQLStatements
+
+	// If true, Connection.ExecuteCached interpolates the arguments into the
+	// query and executes it via the text protocol instead of executing a cached
+	// prepared statement via the binary protocol. time.Time arguments are
+	// interpolated in UTC, regardless of the time_zone of the session.
+	TextProtocol bool
+
+	// The maximum number of statements Connection.ExecuteCached keeps prepared
+	// on each underlying connection, after which the least recently used one
+	// is closed. Default: DefaultStmtCacheSize.
+	StmtCacheSize int
 }
 
 // A thin wrapper around https://pkg.go.dev/github.com/go-mysql-org/go-mysql/client#Conn
@@ -46,12 +184,70 @@ type Connection struct {
 	*client.Conn
 	connList  []*client.Conn
 	connIndex int
+
+	// The statements prepared by ExecuteCached for each underlying connection,
+	// keyed by the query.
+	stmtCache []*stmtCache
+
+	config DatabaseConfig
+}
+
+// Returns the address of the database, which is either the path to the Unix
+// socket or host:port. The go-mysql client detects the former by the slash in
+// the path.
+func (cfg DatabaseConfig) addr() string {
+	if cfg.Socket != "" {
+		return cfg.Socket
+	}
+
+	return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
+}
+
+// Returns the options common to all connections, such as TLS and the
+// requested client capabilities.
+func (cfg DatabaseConfig) connectionOptions() ([]client.Option, error) {
+	options := cfg.sessionOptions()
+	if cfg.TLS.Enabled {
+		serverName, err := cfg.tlsServerName()
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig, err := cfg.TLS.tlsConfig(serverName)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, func(c *client.Conn) error {
+			c.SetTLSConfig(tlsConfig)
+			return nil
+		})
+	}
+
+	return options, nil
+}
+
+// Returns the name the server certificate is verified against.
+func (cfg DatabaseConfig) tlsServerName() (string, error) {
+	if cfg.TLS.ServerName != "" {
+		return cfg.TLS.ServerName, nil
+	}
+
+	if cfg.Socket != "" && !cfg.TLS.SkipVerify {
+		return "", errors.New("must specify -tlsservername to verify the server certificate when connecting via -socket, or disable the verification with -tlsskipverify")
+	}
+
+	return cfg.Host, nil
 }
 
 // Creates a new database if it doesn't exist
 func (cfg DatabaseConfig) CreateDatabaseIfNeeded() error {
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	conn, err := client.Connect(addr, cfg.User, cfg.Pass, "")
+	options, err := cfg.connectionOptions()
+	if err != nil {
+		return err
+	}
+
+	conn, err := client.Connect(cfg.addr(), cfg.User, cfg.Pass, "", options...)
 	if err == nil {
 		_, err = conn.Execute(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", cfg.Database))
 		conn.Close()
@@ -63,42 +259,154 @@ func (cfg DatabaseConfig) CreateDatabaseIfNeeded() error {
 // Returns a connection object based on the database configuration
 func (cfg DatabaseConfig) Connection() (*Connection, error) {
 	if cfg.NoConnection {
-		return &Connection{}, nil
+		return &Connection{config: cfg}, nil
 	}
 	if cfg.ConnectionMultiplier == 0 {
 		cfg.ConnectionMultiplier = 1
 	}
 
-	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.Port)
-	connList := make([]*client.Conn, cfg.ConnectionMultiplier)
-	var options []client.Option
-	if cfg.ClientMultiStatements {
-		options = append(options, func(c *client.Conn) error {
-			c.SetCapability(mysql.CLIENT_MULTI_STATEMENTS)
-			return nil
-		})
+	connList, err := cfg.connect()
+	if err != nil {
+		return nil, err
 	}
+
+	return &Connection{
+		Conn:      connList[0],
+		connList:  connList,
+		connIndex: 0,
+		config:    cfg,
+	}, nil
+}
+
+// Opens the underlying connections of a Connection object.
+func (cfg DatabaseConfig) connect() ([]*client.Conn, error) {
+	options, err := cfg.connectionOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	connList := make([]*client.Conn, cfg.ConnectionMultiplier)
 	for i := 0; i < cfg.ConnectionMultiplier; i++ {
-		conn, err := client.Connect(addr, cfg.User, cfg.Pass, cfg.Database, options...)
+		conn, err := client.Connect(cfg.addr(), cfg.User, cfg.Pass, cfg.Database, options...)
+		if err == nil {
+			err = cfg.initializeSession(conn)
+			if err != nil {
+				conn.Close()
+			}
+		}
+
 		if err != nil {
+			for _, conn := range connList[:i] {
+				conn.Close()
+			}
 			return nil, err
 		}
 		connList[i] = conn
 	}
 
-	return &Connection{
-		Conn:      connList[0],
-		connList:  connList,
-		connIndex: 0,
-	}, nil
+	return connList, nil
+}
+
+// Closes all underlying connections and opens them again, such as after the
+// connection to the database is lost because the database restarted or
+// failed over. If opening the connections fails, the old connections remain
+// closed and Reconnect can be called again.
+func (c *Connection) Reconnect() error {
+	c.closeForReconnect()
+	return c.reopen()
+}
+
+// Closes the underlying connections before they are opened again by reopen.
+// The errors are ignored, as the connections may already be lost.
+func (c *Connection) closeForReconnect() {
+	if c.config.NoConnection {
+		return
+	}
+
+	for _, conn := range c.connList {
+		conn.Close()
+	}
+	c.clearStmtCache()
 }
 
+// Opens the underlying connections again after closeForReconnect.
+func (c *Connection) reopen() error {
+	if c.config.NoConnection {
+		return nil
+	}
+
+	connList, err := c.config.connect()
+	if err != nil {
+		return err
+	}
+
+	c.Conn = connList[0]
+	c.connList = connList
+	c.connIndex = 0
+	return nil
+}
+
+// Returns the next underlying connection in the round-robin sequence, unless
+// the current one is in a transaction, in which case it is returned again.
 func (c *Connection) GetRoundRobinConnection() *client.Conn {
-	c.connIndex = (c.connIndex + 1) % len(c.connList)
-	return c.connList[c.connIndex]
+	return c.connList[c.nextConnIndex()]
+}
+
+func (c *Connection) nextConnIndex() int {
+	if !c.connList[c.connIndex].IsInTransaction() {
+		c.connIndex = (c.connIndex + 1) % len(c.connList)
+	}
+
+	return c.connIndex
+}
+
+// Executes the query on the next underlying connection. See
+// DatabaseConfig.ConnectionMultiplier.
+func (c *Connection) Execute(command string, args ...interface{}) (*mysql.Result, error) {
+	return c.GetRoundRobinConnection().Execute(command, args...)
+}
+
+func (c *Connection) ExecuteSelectStreaming(command string, result *mysql.Result, perRowCallback client.SelectPerRowCallback, perResultCallback client.SelectPerResultCallback) error {
+	return c.GetRoundRobinConnection().ExecuteSelectStreaming(command, result, perRowCallback, perResultCallback)
+}
+
+// Begins a transaction on the next underlying connection, which is then used
+// by all queries until Commit or Rollback is called.
+func (c *Connection) Begin() error {
+	return c.GetRoundRobinConnection().Begin()
+}
+
+func (c *Connection) Commit() error {
+	return c.connList[c.connIndex].Commit()
+}
+
+func (c *Connection) Rollback() error {
+	return c.connList[c.connIndex].Rollback()
+}
+
+// Prepares the statement on every underlying connection.
+func (c *Connection) Prepare(query string) (*Stmt, error) {
+	stmt := &Stmt{
+		conn:  c,
+		stmts: make([]*client.Stmt, 0, len(c.connList)),
+	}
+
+	for _, conn := range c.connList {
+		s, err := conn.Prepare(query)
+		if err != nil {
+			stmt.Close()
+			return nil, err
+		}
+
+		stmt.stmts = append(stmt.stmts, s)
+	}
+
+	return stmt, nil
 }
 
 func (c *Connection) Close() error {
+	c.clearStmtCache()
+
 	var err error
 	for _, conn := range c.connList {
 		err = conn.Close()
@@ -108,3 +416,38 @@ func (c *Connection) Close() error {
 	}
 	return nil
 }
+
+// A prepared statement with a handle on each underlying connection of a
+// Connection, so executing it follows the same round-robin sequence as
+// Connection.Execute. The statement is tied to the underlying connections and
+// must be prepared again after Connection.Reconnect.
+type Stmt struct {
+	conn  *Connection
+	stmts []*client.Stmt
+}
+
+func (s *Stmt) Execute(args ...interface{}) (*mysql.Result, error) {
+	return s.stmts[s.conn.nextConnIndex()].Execute(args...)
+}
+
+func (s *Stmt) ExecuteSelectStreaming(result *mysql.Result, perRowCallback client.SelectPerRowCallback, perResultCallback client.SelectPerResultCallback, args ...interface{}) error {
+	return s.stmts[s.conn.nextConnIndex()].ExecuteSelectStreaming(result, perRowCallback, perResultCallback, args...)
+}
+
+func (s *Stmt) ParamNum() int {
+	return s.stmts[0].ParamNum()
+}
+
+func (s *Stmt) ColumnNum() int {
+	return s.stmts[0].ColumnNum()
+}
+
+func (s *Stmt) Close() error {
+	var err error
+	for _, stmt := range s.stmts {
+		if closeErr := stmt.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,264 @@
+package mybench
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// A list of SQL statements, which can be used as a flag.Value. Each call to
+// Set appends a single statement, so the flag is repeated once per statement
+// and the statements may contain semicolons, such as in string literals.
+//
+// String encodes the statements as a JSON list, which Set accepts as well, so
+// the list can be replayed from the resolved config.
+type SQLStatements []string
+
+func (s SQLStatements) String() string {
+	if len(s) == 0 {
+		return ""
+	}
+
+	encoded, err := json.Marshal([]string(s))
+	if err != nil {
+		panic(err)
+	}
+
+	return string(encoded)
+}
+
+func (s *SQLStatements) Set(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	// An SQL statement cannot start with [, so this is a list from String.
+	if strings.HasPrefix(value, "[") {
+		var statements []string
+		err := json.Unmarshal([]byte(value), &statements)
+		if err != nil {
+			return fmt.Errorf("failed to parse the list of SQL statements %s: %w", value, err)
+		}
+
+		*s = append(*s, statements...)
+		return nil
+	}
+
+	*s = append(*s, value)
+	return nil
+}
+
+// The connection attributes sent to the server, which are visible in
+// performance_schema.session_connect_attrs. It can be used as a flag.Value in
+// the form of key=value,key2=value2, or as a JSON object for values that
+// contain commas.
+//
+// String encodes the attributes as a JSON object, which Set accepts as well, so
+// the attributes can be replayed from the resolved config.
+type ConnectionAttributes map[string]string
+
+func (a ConnectionAttributes) String() string {
+	if len(a) == 0 {
+		return ""
+	}
+
+	// The keys are sorted by json.Marshal.
+	encoded, err := json.Marshal(map[string]string(a))
+	if err != nil {
+		panic(err)
+	}
+
+	return string(encoded)
+}
+
+func (a *ConnectionAttributes) Set(value string) error {
+	if *a == nil {
+		*a = make(ConnectionAttributes)
+	}
+
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		var attributes map[string]string
+		err := json.Unmarshal([]byte(value), &attributes)
+		if err != nil {
+			return fmt.Errorf("failed to parse the connection attributes %s: %w", value, err)
+		}
+
+		for key, attributeValue := range attributes {
+			if key == "" {
+				return fmt.Errorf("invalid connection attribute with an empty key in %s", value)
+			}
+			(*a)[key] = attributeValue
+		}
+
+		return nil
+	}
+
+	for _, attribute := range strings.Split(value, ",") {
+		attribute = strings.TrimSpace(attribute)
+		if attribute == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(attribute, "=")
+		if !found || key == "" {
+			return fmt.Errorf("invalid connection attribute %s, must be key=value", attribute)
+		}
+
+		(*a)[key] = value
+	}
+
+	return nil
+}
+
+// The protocol compression of the connections.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionZlib
+	CompressionZstd
+)
+
+var compressionNames = map[Compression]string{
+	CompressionNone: "none",
+	CompressionZlib: "zlib",
+	CompressionZstd: "zstd",
+}
+
+func (c Compression) String() string {
+	return compressionNames[c]
+}
+
+// Allows Compression to be used as a flag.Value.
+func (c *Compression) Set(s string) error {
+	for compression, name := range compressionNames {
+		if name == s {
+			*c = compression
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown compression %s, must be none, zlib, or zstd", s)
+}
+
+func (c Compression) capability() uint32 {
+	switch c {
+	case CompressionZlib:
+		return mysql.CLIENT_COMPRESS
+	case CompressionZstd:
+		return mysql.CLIENT_ZSTD_COMPRESSION_ALGORITHM
+	}
+
+	return 0
+}
+
+// The client capabilities that can be requested in addition to the ones the
+// client always sets.
+var clientCapabilityNames = map[string]uint32{
+	"found_rows":       mysql.CLIENT_FOUND_ROWS,
+	"ignore_space":     mysql.CLIENT_IGNORE_SPACE,
+	"multi_statements": mysql.CLIENT_MULTI_STATEMENTS,
+	"multi_results":    mysql.CLIENT_MULTI_RESULTS,
+	"ps_multi_results": mysql.CLIENT_PS_MULTI_RESULTS,
+	"local_files":      mysql.CLIENT_LOCAL_FILES,
+}
+
+// A set of client capabilities, which can be used as a flag.Value in the form
+// of a comma separated list of names such as found_rows,multi_results. See
+// clientCapabilityNames for the supported names.
+type ClientCapabilities uint32
+
+func (c ClientCapabilities) String() string {
+	names := []string{}
+	for name, capability := range clientCapabilityNames {
+		if uint32(c)&capability != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+func (c *ClientCapabilities) Set(s string) error {
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		capability, found := clientCapabilityNames[name]
+		if !found {
+			return fmt.Errorf("unknown client capability %s", name)
+		}
+
+		*c |= ClientCapabilities(capability)
+	}
+
+	return nil
+}
+
+// Returns the options that configure the connection before the handshake. TLS
+// is configured separately, see DatabaseConfig.connectionOptions.
+func (cfg DatabaseConfig) sessionOptions() []client.Option {
+	capabilities := uint32(cfg.ClientCapabilities) | cfg.Compression.capability()
+	if cfg.ClientMultiStatements {
+		capabilities |= mysql.CLIENT_MULTI_STATEMENTS
+	}
+
+	var options []client.Option
+	if capabilities != 0 {
+		options = append(options, func(c *client.Conn) error {
+			c.SetCapability(capabilities)
+			return nil
+		})
+	}
+
+	if cfg.Collation != "" {
+		options = append(options, func(c *client.Conn) error {
+			return c.SetCollation(cfg.Collation)
+		})
+	}
+
+	if len(cfg.ConnectionAttributes) > 0 {
+		options = append(options, func(c *client.Conn) error {
+			c.SetAttributes(cfg.ConnectionAttributes)
+			return nil
+		})
+	}
+
+	return options
+}
+
+// Sets the charset and executes the init statements on a newly opened
+// connection.
+func (cfg DatabaseConfig) initializeSession(conn *client.Conn) error {
+	if cfg.Charset != "" && cfg.Collation != "" {
+		// SetCharset sends a plain SET NAMES, which would reset the collation
+		// negotiated in the handshake to the default of the charset.
+		_, err := conn.Execute(fmt.Sprintf("SET NAMES %s COLLATE %s", cfg.Charset, cfg.Collation))
+		if err != nil {
+			return fmt.Errorf("failed to set charset %s with collation %s: %w", cfg.Charset, cfg.Collation, err)
+		}
+	} else if cfg.Charset != "" {
+		err := conn.SetCharset(cfg.Charset)
+		if err != nil {
+			return fmt.Errorf("failed to set charset %s: %w", cfg.Charset, err)
+		}
+	}
+
+	for _, statement := range cfg.InitStatements {
+		_, err := conn.Execute(statement)
+		if err != nil {
+			return fmt.Errorf("init statement %q failed: %w", statement, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package mybench
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseConfigAddr(t *testing.T) {
+	cfg := DatabaseConfig{Host: "db.local", Port: 3307}
+	require.Equal(t, "db.local:3307", cfg.addr())
+
+	cfg.Socket = "/var/run/mysqld/mysqld.sock"
+	require.Equal(t, "/var/run/mysqld/mysqld.sock", cfg.addr())
+}
+
+func TestConnectionRoundRobin(t *testing.T) {
+	connList := []*client.Conn{{}, {}, {}}
+	conn := &Connection{Conn: connList[0], connList: connList}
+
+	var indices []int
+	for i := 0; i < 6; i++ {
+		require.Same(t, connList[(i+1)%3], conn.GetRoundRobinConnection())
+		indices = append(indices, conn.connIndex)
+	}
+	require.Equal(t, []int{1, 2, 0, 1, 2, 0}, indices)
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	require.Nil(t, TLSConfig{}.Validate())
+	require.Nil(t, TLSConfig{Enabled: true, SkipVerify: true}.Validate())
+	require.ErrorContains(t, TLSConfig{CAFile: "ca.pem"}.Validate(), "-tls")
+	require.ErrorContains(t, TLSConfig{Enabled: true, CertFile: "client-cert.pem"}.Validate(), "-tlskey")
+	require.ErrorContains(t, TLSConfig{ServerName: "db.local"}.Validate(), "-tls")
+}
+
+func TestDatabaseConfigTLSServerName(t *testing.T) {
+	cfg := DatabaseConfig{Host: "db.local", TLS: TLSConfig{Enabled: true}}
+	serverName, err := cfg.tlsServerName()
+	require.Nil(t, err)
+	require.Equal(t, "db.local", serverName)
+
+	cfg.TLS.ServerName = "mysql.example.com"
+	serverName, err = cfg.tlsServerName()
+	require.Nil(t, err)
+	require.Equal(t, "mysql.example.com", serverName)
+
+	// A Unix socket has no host name to verify the certificate against.
+	cfg = DatabaseConfig{Socket: "/var/run/mysqld/mysqld.sock", TLS: TLSConfig{Enabled: true}}
+	_, err = cfg.tlsServerName()
+	require.ErrorContains(t, err, "-tlsservername")
+	_, err = cfg.connectionOptions()
+	require.ErrorContains(t, err, "-tlsservername")
+
+	cfg.TLS.ServerName = "mysql.example.com"
+	_, err = cfg.tlsServerName()
+	require.Nil(t, err)
+
+	cfg.TLS = TLSConfig{Enabled: true, SkipVerify: true}
+	_, err = cfg.tlsServerName()
+	require.Nil(t, err)
+}
+
+// Writes a self-signed certificate and its key to the directory.
+func writeSelfSignedCertForTest(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mybench"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0600))
+	require.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestTLSConfigFromFiles(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCertForTest(t, t.TempDir())
+
+	config, err := TLSConfig{Enabled: true, CAFile: certFile, CertFile: certFile, KeyFile: keyFile}.tlsConfig("db.local")
+	require.Nil(t, err)
+	require.Equal(t, "db.local", config.ServerName)
+	require.NotNil(t, config.RootCAs)
+	require.Len(t, config.Certificates, 1)
+	require.False(t, config.InsecureSkipVerify)
+
+	config, err = TLSConfig{Enabled: true, SkipVerify: true}.tlsConfig("db.local")
+	require.Nil(t, err)
+	require.Nil(t, config.RootCAs)
+	require.True(t, config.InsecureSkipVerify)
+
+	_, err = TLSConfig{Enabled: true, CAFile: keyFile}.tlsConfig("db.local")
+	require.ErrorContains(t, err, "failed to parse CA certificate")
+
+	_, err = TLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")}.tlsConfig("db.local")
+	require.NotNil(t, err)
+}
+
+func TestConnectionOptionFlags(t *testing.T) {
+	var statements SQLStatements
+	require.Equal(t, "", statements.String())
+	require.Nil(t, statements.Set("SET SESSION sql_mode = 'STRICT_ALL_TABLES'"))
+	require.Nil(t, statements.Set(" SET @separator = ';' "))
+	require.Equal(t, SQLStatements{"SET SESSION sql_mode = 'STRICT_ALL_TABLES'", "SET @separator = ';'"}, statements)
+
+	// The string can be parsed again, which is needed to replay the resolved
+	// config.
+	var replayed SQLStatements
+	require.Nil(t, replayed.Set(statements.String()))
+	require.Equal(t, statements, replayed)
+	require.NotNil(t, replayed.Set("[not json"))
+
+	var attributes ConnectionAttributes
+	require.Nil(t, attributes.Set("program_name=mybench,run=baseline"))
+	require.Equal(t, ConnectionAttributes{"program_name": "mybench", "run": "baseline"}, attributes)
+	require.Equal(t, `{"program_name":"mybench","run":"baseline"}`, attributes.String())
+	require.NotNil(t, attributes.Set("program_name"))
+
+	// Values with commas and equal signs can be set as JSON, and the string
+	// can be parsed again to replay the resolved config.
+	require.Nil(t, attributes.Set(`{"note": "a=1,b=2"}`))
+	require.Equal(t, "a=1,b=2", attributes["note"])
+	var replayedAttributes ConnectionAttributes
+	require.Nil(t, replayedAttributes.Set(attributes.String()))
+	require.Equal(t, attributes, replayedAttributes)
+	require.NotNil(t, replayedAttributes.Set("{not json"))
+	require.NotNil(t, replayedAttributes.Set(`{"": "empty"}`))
+
+	var noAttributes ConnectionAttributes
+	require.Equal(t, "", noAttributes.String())
+
+	var capabilities ClientCapabilities
+	require.Nil(t, capabilities.Set("multi_results, found_rows"))
+	require.Equal(t, ClientCapabilities(mysql.CLIENT_FOUND_ROWS|mysql.CLIENT_MULTI_RESULTS), capabilities)
+	require.Equal(t, "found_rows,multi_results", capabilities.String())
+	require.NotNil(t, capabilities.Set("ssl"))
+
+	var compression Compression
+	require.Nil(t, compression.Set("zstd"))
+	require.Equal(t, CompressionZstd, compression)
+	require.NotNil(t, compression.Set("lz4"))
+}
+
+func TestSessionOptions(t *testing.T) {
+	cfg := DatabaseConfig{
+		ClientMultiStatements: true,
+		ClientCapabilities:    ClientCapabilities(mysql.CLIENT_FOUND_ROWS),
+		Compression:           CompressionZlib,
+		Collation:             "utf8mb4_bin",
+	}
+
+	conn := &client.Conn{}
+	for _, option := range cfg.sessionOptions() {
+		require.Nil(t, option(conn))
+	}
+
+	require.True(t, conn.HasCapability(mysql.CLIENT_MULTI_STATEMENTS))
+	require.True(t, conn.HasCapability(mysql.CLIENT_FOUND_ROWS))
+	require.True(t, conn.HasCapability(mysql.CLIENT_COMPRESS))
+	require.False(t, conn.HasCapability(mysql.CLIENT_MULTI_RESULTS))
+	require.Equal(t, "utf8mb4_bin", conn.GetCollation())
+
+	require.Empty(t, DatabaseConfig{}.sessionOptions())
+}
+
+func TestConnectionCharsetAndCollation(t *testing.T) {
+	s := newTestMySQLServer(t)
+
+	collationConnection := func(cfg DatabaseConfig) string {
+		conn, err := cfg.Connection()
+		require.Nil(t, err)
+		defer conn.Close()
+
+		result, err := conn.Execute("SELECT @@collation_connection")
+		require.Nil(t, err)
+		collation, err := result.GetString(0, 0)
+		require.Nil(t, err)
+		return collation
+	}
+
+	cfg := s.databaseConfig()
+	cfg.Collation = "utf8mb4_bin"
+	require.Equal(t, "utf8mb4_bin", collationConnection(cfg))
+
+	// The collation is kept when the charset is set as well.
+	cfg.Charset = "utf8mb4"
+	require.Equal(t, "utf8mb4_bin", collationConnection(cfg))
+
+	cfg.Collation = ""
+	cfg.Charset = "latin1"
+	require.Equal(t, "latin1_swedish_ci", collationConnection(cfg))
+}
+
+func TestConnectionBeginPinsConnection(t *testing.T) {
+	s := newTestMySQLServer(t)
+	cfg := s.databaseConfig()
+	cfg.ConnectionMultiplier = 3
+
+	conn, err := cfg.Connection()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// The transaction stays on one connection until it is committed or rolled
+	// back, after which the round robin continues.
+	require.Nil(t, conn.Begin())
+	pinned := conn.connIndex
+	for i := 0; i < 3; i++ {
+		_, err = conn.Execute("SELECT 1")
+		require.Nil(t, err)
+		require.Equal(t, pinned, conn.connIndex)
+	}
+	require.Nil(t, conn.Commit())
+
+	_, err = conn.Execute("SELECT 1")
+	require.Nil(t, err)
+	require.Equal(t, (pinned+1)%3, conn.connIndex)
+
+	require.Nil(t, conn.Begin())
+	pinned = conn.connIndex
+	_, err = conn.Execute("SELECT 1")
+	require.Nil(t, err)
+	require.Nil(t, conn.Rollback())
+	require.Equal(t, pinned, conn.connIndex)
+
+	_, err = conn.Execute("SELECT 1")
+	require.Nil(t, err)
+	require.Equal(t, (pinned+1)%3, conn.connIndex)
+
+	// All queries of each transaction reached the same server connection.
+	var queries [][]string
+	for _, serverConn := range s.connections() {
+		queries = append(queries, serverConn.Queries())
+	}
+	require.ElementsMatch(t, [][]string{
+		{"BEGIN", "SELECT 1", "SELECT 1", "SELECT 1", "COMMIT", "SELECT 1"},
+		{"SELECT 1"},
+		{"BEGIN", "SELECT 1", "ROLLBACK"},
+	}, queries)
+}
+
+func TestConnectionPrepareOnEveryConnection(t *testing.T) {
+	s := newTestMySQLServer(t)
+	cfg := s.databaseConfig()
+	cfg.ConnectionMultiplier = 3
+
+	conn, err := cfg.Connection()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	stmt, err := conn.Prepare("SELECT * FROM t WHERE id = ?")
+	require.Nil(t, err)
+	require.Len(t, stmt.stmts, 3)
+	require.Equal(t, 1, stmt.ParamNum())
+
+	serverConns := s.connections()
+	require.Len(t, serverConns, 3)
+	for _, serverConn := range serverConns {
+		require.Equal(t, []string{"SELECT * FROM t WHERE id = ?"}, serverConn.Prepared())
+	}
+
+	// The executions follow the round robin over the connections.
+	for i := 0; i < 3; i++ {
+		_, err = stmt.Execute(i)
+		require.Nil(t, err)
+	}
+	for _, serverConn := range serverConns {
+		require.Len(t, serverConn.Queries(), 1)
+	}
+
+	require.Nil(t, stmt.Close())
+	for _, serverConn := range serverConns {
+		require.Eventually(t, func() bool { return serverConn.ClosedStmts() == 1 }, time.Second, 10*time.Millisecond)
+	}
+}
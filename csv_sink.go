@@ -0,0 +1,96 @@
+package mybench
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Logs the rows of the data to a CSV file, with the columns of the log table
+// preceded by the table_name column that identifies the run. If the file
+// already exists, the rows are appended to it, so a sequence of runs can be
+// logged to the same file, as long as the runs have the same columns. NULL
+// values are written as empty fields.
+type CSVSink struct {
+	Filename string
+
+	tableName string
+	file      *os.File
+	writer    *csv.Writer
+}
+
+func NewCSVSink(filename string) *CSVSink {
+	return &CSVSink{Filename: filename}
+}
+
+func (s *CSVSink) Open(run RunInfo) error {
+	s.tableName = run.TableName
+	header := append([]string{"table_name"}, LogColumns(run.Percentiles)...)
+
+	var err error
+	s.file, err = os.OpenFile(s.Filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	existingHeader, err := bufio.NewReader(s.file).ReadString('\n')
+	if err != nil && err != io.EOF {
+		s.file.Close()
+		return err
+	}
+
+	s.writer = csv.NewWriter(s.file)
+	if existingHeader == "" {
+		return s.write(header)
+	}
+
+	if strings.TrimRight(existingHeader, "\r\n") != strings.Join(header, ",") {
+		s.file.Close()
+		return fmt.Errorf("%s has different columns than this run, such as due to different -percentiles, log to a different file instead", s.Filename)
+	}
+
+	return nil
+}
+
+func (s *CSVSink) Log(dataSnapshot *DataSnapshot) error {
+	for _, row := range dataSnapshot.Rows() {
+		record := make([]string, 0, len(row)+1)
+		record = append(record, s.tableName)
+		for _, value := range row {
+			record = append(record, formatCSVValue(value))
+		}
+
+		s.writer.Write(record)
+	}
+
+	// Flushed every interval, so the file can be followed during the run.
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *CSVSink) Close(summary *RunSummary) error {
+	return s.file.Close()
+}
+
+func (s *CSVSink) write(record []string) error {
+	s.writer.Write(record)
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func formatCSVValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
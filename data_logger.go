@@ -2,103 +2,86 @@ package mybench
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"runtime/trace"
+	"sort"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
-const createMetaTableStatement = `
-CREATE TABLE IF NOT EXISTS meta (
-	table_name TEXT PRIMARY KEY,
-	benchmark_name TEXT,
-	mybench_version TEXT,
-	note TEXT,
-	start_time TEXT,
-	end_time TEXT
-)
-`
-
 var VersionString = "1.0"
 
-var insertMetaStatement = "INSERT INTO meta (table_name, note, benchmark_name, mybench_version, start_time) VALUES (?, ?, ?, '" + VersionString + "', ?)"
-
-const updateMetaEndTimeStatement = `
-UPDATE meta SET end_time = ? WHERE table_name = ?
-`
-
-const createTableStatement = `
-CREATE TABLE %s (
-	id INTEGER PRIMARY KEY AUTOINCREMENT, -- Using auto increment allows the id to be strictly monotonic
-	workload TEXT,
-	seconds_since_start REAL,
-	interval_start TEXT,
-	interval_end TEXT,
-	desired_rate REAL,
-	count INTEGER,
-	delta REAL,
-	rate REAL,
-	min INTEGER,
-	mean INTEGER,
-	max INTEGER,
-	underflow_count INTEGER,
-	overflow_count INTEGER,
-	percentile25 INTEGER,
-	percentile50 INTEGER,
-	percentile75 INTEGER,
-	percentile90 INTEGER,
-	percentile99 INTEGER,
-	uniform_hist TEXT
-);
-CREATE INDEX %s_workload ON %s(workload);
-`
-
-// TODO: insert uniform_hist  as well
-const insertQuery = `
-INSERT INTO %s (
-	workload,
-	seconds_since_start,
-	interval_start,
-	interval_end,
-	desired_rate,
-	count,
-	delta,
-	rate,
-	min,
-	mean,
-	max,
-	underflow_count,
-	overflow_count,
-	percentile25,
-	percentile50,
-	percentile75,
-	percentile90,
-	percentile99
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-`
-
 // Merges the IntervalData with other data.
 // Represents all the stats collected for a single workload.
 type WorkloadDataSnapshot struct {
 	// Throughput and latency data
 	IntervalData
 
-	// The desired throughput
+	// The desired throughput. This is not meaningful in the closed-loop mode
+	// and is logged as NULL.
 	DesiredRate float64
+
+	// True if the workload runs in the closed-loop mode, where there is no
+	// desired throughput.
+	ClosedLoop bool
+
+	// True for the data that does not belong to a workload, such as the data
+	// per host, which has no desired throughput either.
+	noDesiredRate bool
+
+	// The response time of the events, which is measured from their intended
+	// start time instead of their actual start time, so it includes the delay
+	// if the benchmark falls behind. See EventStat.ResponseTime. Only the count
+	// and the latency data are set. Nil for the data that does not belong to
+	// the workloads, such as the data per host.
+	ResponseTime *IntervalData `json:",omitempty"`
+
+	// The lag of the loopers of the workers behind the desired schedule. Nil
+	// in the closed-loop mode and for the data that does not belong to the
+	// workloads.
+	LooperLag *LooperLagData `json:",omitempty"`
+
+	// The encoded HDR histograms of the interval, which are only logged to the
+	// log table. Empty if they could not be encoded.
+	encodedHist         string
+	encodedResponseHist string
 }
 
-func (s WorkloadDataSnapshot) queryArgs(workload string, secondsSinceStart float64) []interface{} {
-	return []interface{}{
+// Returns the values of the row of the snapshot, see DataSnapshot.Rows. The
+// host is NULL if it is empty.
+func (s WorkloadDataSnapshot) row(workload, host string, dataSnapshot *DataSnapshot) []interface{} {
+	var desiredRate interface{} = s.DesiredRate
+	if s.ClosedLoop || s.noDesiredRate {
+		desiredRate = nil
+	}
+
+	var hostArg interface{}
+	if host != "" {
+		hostArg = host
+	}
+
+	var encodedHist interface{} // NULL if the histogram could not be encoded
+	if s.encodedHist != "" {
+		encodedHist = s.encodedHist
+	}
+
+	args := make([]interface{}, 0, 32+2*len(s.Percentiles))
+	args = append(args,
 		workload,
-		secondsSinceStart,
+		hostArg,
+		dataSnapshot.Time,
 		s.StartTime.Format(time.RFC3339),
 		s.EndTime.Format(time.RFC3339),
-		s.DesiredRate,
+		dataSnapshot.segmentArg(),
+		string(dataSnapshot.Phase),
+		desiredRate,
 		s.Count,
 		s.Delta,
 		s.Rate,
@@ -107,18 +90,61 @@ func (s WorkloadDataSnapshot) queryArgs(workload string, secondsSinceStart float
 		s.Max,
 		s.UnderflowCount,
 		s.OverflowCount,
-		s.Percentile25,
-		s.Percentile50,
-		s.Percentile75,
-		s.Percentile90,
-		s.Percentile99,
+		s.Errors.Total(),
+		s.Errors.Deadlock,
+		s.Errors.LockWaitTimeout,
+		s.Errors.LostConnection,
+		s.Errors.QueryTimeout,
+		s.Errors.Other,
+		s.Retries,
+	)
+
+	for _, percentile := range s.Percentiles {
+		args = append(args, percentile.Value)
+	}
+	args = append(args, encodedHist)
+
+	if s.ResponseTime == nil {
+		for i := 0; i < len(s.Percentiles)+3; i++ {
+			args = append(args, nil)
+		}
+	} else {
+		var encodedResponseHist interface{}
+		if s.encodedResponseHist != "" {
+			encodedResponseHist = s.encodedResponseHist
+		}
+
+		args = append(args, s.ResponseTime.Mean, s.ResponseTime.Max)
+		for _, percentile := range s.ResponseTime.Percentiles {
+			args = append(args, percentile.Value)
+		}
+		args = append(args, encodedResponseHist)
 	}
+
+	if s.LooperLag == nil {
+		return append(args, nil, nil, nil, nil)
+	}
+
+	return append(args,
+		s.LooperLag.WakeupLatenessMean,
+		s.LooperLag.WakeupLatenessMax,
+		s.LooperLag.Backlog,
+		s.LooperLag.CatchingUpShare,
+	)
 }
 
 type DataSnapshot struct {
 	// Time since start of the test.
 	Time float64
 
+	// The segment label set via Benchmark.SetSegment at the start of the
+	// interval. Empty if no segment is set.
+	Segment string
+
+	// Whether the interval is part of the warmup. Intervals in the warmup
+	// phase should be excluded from summaries of the benchmark.
+	Phase Phase
+
 	// The throughput and latency data for all monitored benchmarks merged
 	// together.
 	AllWorkloadData WorkloadDataSnapshot
@@ -126,29 +152,143 @@ type DataSnapshot struct {
 	// The throughput and latency data for individual monitored benchmarks,
 	// indexed by the workload name.
 	PerWorkloadData map[string]WorkloadDataSnapshot
+
+	// The throughput and latency data of all workloads merged together per
+	// target host of the connections, indexed by host:port. Only set if replicas
+	// are configured. Events are attributed to the host of the last connection
+	// returned by WorkerContext.Reader or WorkerContext.Writer, or to the
+	// primary if neither is called. The DesiredRate is not set.
+	PerHostData map[string]WorkloadDataSnapshot `json:",omitempty"`
+
+	// The time taken to open connections in the connection churn mode, merged
+	// for all workloads. The count is the number of times the connections were
+	// opened and the errors are the failures to open them. Nil if the
+	// connection churn mode is disabled. The DesiredRate is not set.
+	ConnectData *WorkloadDataSnapshot `json:",omitempty"`
+}
+
+// Returns the rows of the data, whose values are named by LogColumns and are
+// nil where they are NULL in the log table. The rows are, in order: all
+// workloads merged together as the workload __all__, each workload sorted by
+// name, the connection setup latency as __connect__, and each host sorted by
+// host as __host__.
+func (s *DataSnapshot) Rows() [][]interface{} {
+	rows := make([][]interface{}, 0, len(s.PerWorkloadData)+len(s.PerHostData)+2)
+	rows = append(rows, s.AllWorkloadData.row("__all__", "", s))
+
+	for _, workloadName := range sortedKeys(s.PerWorkloadData) {
+		rows = append(rows, s.PerWorkloadData[workloadName].row(workloadName, "", s))
+	}
+
+	if s.ConnectData != nil {
+		rows = append(rows, s.ConnectData.row("__connect__", "", s))
+	}
+
+	for _, host := range sortedKeys(s.PerHostData) {
+		rows = append(rows, s.PerHostData[host].row("__host__", host, s))
+	}
+
+	return rows
+}
+
+func sortedKeys(m map[string]WorkloadDataSnapshot) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// The segment is logged as NULL if it is not set.
+func (s *DataSnapshot) segmentArg() interface{} {
+	if s.Segment == "" {
+		return nil
+	}
+
+	return s.Segment
 }
 
 type DataLogger struct {
-	Interval       time.Duration
-	RingSize       int
+	Interval  time.Duration
+	RingSize  int
+	TableName string
+	Note      string
+	Benchmark *Benchmark
+
+	// The SQLite file the data is logged to via the SQLiteSink. Can be empty if
+	// other Sinks are set.
 	OutputFilename string
-	TableName      string
-	Note           string
-	Benchmark      *Benchmark
+
+	// The sinks the data is logged to in addition to the SQLiteSink, such as
+	// the CSVSink and the JSONLinesSink.
+	Sinks []DataSink
+
+	// The directory the resolved config and the summary of the run are written
+	// to. Default: the directory of the OutputFilename.
+	OutputDir string
+
+	// The resolved configuration of the run. This is stored in the meta table
+	// and written to a config file in the OutputDir, which can be passed to
+	// -config to replay the run.
+	ResolvedConfig map[string]string
+
+	// The warmup is complete once either the duration elapses or the number of
+	// events are executed. See BenchmarkConfig.
+	WarmupDuration time.Duration
+	WarmupEvents   int64
+
+	// The latency percentiles logged for each interval. Default:
+	// DefaultPercentiles.
+	Percentiles Percentiles
 
 	logger    logrus.FieldLogger
 	startTime time.Time
-	db        *sql.DB
 	dataRing  *Ring[*DataSnapshot]
+
+	// The segment at the start of the current interval.
+	intervalSegment string
+
+	warmup *warmupTracker
+
+	// Merges the intervals of the measurement phase for the RunSummary, which
+	// is built once the data logger stops.
+	summaryAccumulator *summaryAccumulator
+	summary            *RunSummary
+
+	// Warns when the latencies of a workload exceed the range of its
+	// histograms. See WorkloadConfig.Histogram.
+	overflowWarner *overflowWarner
+
+	// The metrics served at /metrics of the HTTP server, which are updated
+	// after each interval is logged.
+	metrics *prometheusMetrics
 }
 
 func NewDataLogger(dataLogger *DataLogger) (*DataLogger, error) {
 	dataLogger.logger = logrus.WithField("tag", "data_logger")
 
-	if dataLogger.OutputFilename == "" {
-		return nil, errors.New("must specify output filename for data logger")
+	if dataLogger.OutputFilename != "" {
+		dataLogger.Sinks = append([]DataSink{NewSQLiteSink(dataLogger.OutputFilename)}, dataLogger.Sinks...)
+	}
+
+	if len(dataLogger.Sinks) == 0 {
+		return nil, errors.New("must specify output filename or sinks for data logger")
+	}
+
+	if dataLogger.OutputDir == "" {
+		dataLogger.OutputDir = filepath.Dir(dataLogger.OutputFilename)
+	}
+
+	err := dataLogger.Percentiles.ValidateAndSetDefaults()
+	if err != nil {
+		return nil, err
 	}
 
+	dataLogger.summaryAccumulator = newSummaryAccumulator()
+	dataLogger.overflowWarner = newOverflowWarner(dataLogger.logger)
+	dataLogger.metrics = newPrometheusMetrics()
 	return dataLogger, nil
 }
 
@@ -160,14 +300,16 @@ func (d *DataLogger) Run(ctx context.Context, startTime time.Time) {
 		d.TableName = strings.Replace(d.TableName, "-", "_", -1)
 	}
 
-	err := d.initializeLogDatabase()
+	err := d.openSinks()
 	if err != nil {
-		logrus.WithError(err).Panic("failed to initialize log database")
+		logrus.WithError(err).Panic("failed to open data sinks")
 	}
-	defer d.closeLogDatabase()
+	defer d.closeSinks()
 
 	// Initialize the ring
 	d.dataRing = NewRing[*DataSnapshot](d.RingSize)
+	d.intervalSegment = d.Benchmark.segment.Load()
+	d.warmup = newWarmupTracker(startTime, d.WarmupDuration, d.WarmupEvents)
 
 	// Start collecting data!
 	nextWakeupTime := time.Now().Add(d.Interval)
@@ -198,55 +340,143 @@ func (d *DataLogger) DataSnapshots() []*DataSnapshot {
 	return d.dataRing.ReadAllOrdered()
 }
 
-func (d *DataLogger) initializeLogDatabase() error {
-	var err error
-	d.db, err = sql.Open("sqlite3", d.OutputFilename)
-	if err != nil {
-		return err
+// Writes the metrics of the latest intervals in the Prometheus text exposition
+// format. Safe to call from any goroutine.
+func (d *DataLogger) WriteMetrics(w io.Writer) error {
+	return d.metrics.write(w, d.Benchmark.Name, d.Note)
+}
+
+// Returns the summary of the run once the data logger has stopped, or nil if
+// there is no data in the measurement phase.
+func (d *DataLogger) Summary() *RunSummary {
+	return d.summary
+}
+
+func (d *DataLogger) openSinks() error {
+	run := RunInfo{
+		Benchmark:      d.Benchmark.Name,
+		TableName:      d.TableName,
+		Note:           d.Note,
+		StartTime:      d.startTime,
+		Percentiles:    d.Percentiles,
+		ResolvedConfig: d.ResolvedConfig,
 	}
 
-	tx, err := d.db.Begin()
-	if err != nil {
-		return err
+	// If a sink fails to open, the sinks opened before it are closed, as Run
+	// does not close any sinks in that case.
+	for i, sink := range d.Sinks {
+		err := sink.Open(run)
+		if err != nil {
+			d.closeDataSinks(d.Sinks[:i], nil)
+			return err
+		}
 	}
 
-	_, err = tx.Exec(createMetaTableStatement)
+	if d.ResolvedConfig != nil {
+		err := d.writeResolvedConfigFile()
+		if err != nil {
+			d.closeDataSinks(d.Sinks, nil)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes the resolved config to the output directory, named after the table,
+// so the run can be replayed with -config.
+func (d *DataLogger) writeResolvedConfigFile() error {
+	data, err := yaml.Marshal(d.ResolvedConfig)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	_, err = tx.Exec(fmt.Sprintf(createTableStatement, d.TableName, d.TableName, d.TableName))
+	filename := filepath.Join(d.OutputDir, d.TableName+".config.yaml")
+	err = os.WriteFile(filename, data, 0644)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	_, err = tx.Exec(insertMetaStatement, d.TableName, d.Note, d.Benchmark.Name, d.startTime.Format(time.RFC3339))
+	d.logger.Infof("resolved config written to %s", filename)
+	return nil
+}
+
+// Errors are logged, so all sinks are closed.
+func (d *DataLogger) closeSinks() {
+	// The benchmark workers are stopped before the data logger, so the
+	// downtime windows are final.
+	d.logDowntimeWindows(true)
+
+	d.summary = d.summaryAccumulator.summary(d.Percentiles)
+	if d.summary == nil {
+		d.logger.Warn("no data was collected in the measurement phase, the run has no summary")
+	} else {
+		d.summary.Benchmark = d.Benchmark.Name
+		d.summary.TableName = d.TableName
+		d.summary.Note = d.Note
+
+		err := d.writeSummaryFile()
+		if err != nil {
+			d.logger.WithError(err).Error("failed to write summary file")
+		}
+	}
+
+	d.closeDataSinks(d.Sinks, d.summary)
+}
+
+func (d *DataLogger) closeDataSinks(sinks []DataSink, summary *RunSummary) {
+	for _, sink := range sinks {
+		err := sink.Close(summary)
+		if err != nil {
+			d.logger.WithError(err).Error("failed to close data sink")
+		}
+	}
+}
+
+// Writes the summary in JSON to the output directory, named after the table.
+func (d *DataLogger) writeSummaryFile() error {
+	data, err := json.MarshalIndent(d.summary, "", "  ")
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	err = tx.Commit()
+	filename := filepath.Join(d.OutputDir, d.TableName+".summary.json")
+	err = os.WriteFile(filename, data, 0644)
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	d.logger.Infof("using log data file at %s with table %s", d.OutputFilename, d.TableName)
+	d.logger.Infof("summary written to %s", filename)
 	return nil
 }
 
-func (d *DataLogger) closeLogDatabase() error {
-	defer d.db.Close()
-	_, err := d.db.Exec(updateMetaEndTimeStatement, time.Now().Format(time.RFC3339), d.TableName)
-	return err
+func (d *DataLogger) logDowntimeWindows(includeOngoing bool) {
+	var windows []DowntimeWindow
+	for _, workload := range d.Benchmark.workloads {
+		windows = append(windows, workload.TakeDowntimeWindows(includeOngoing)...)
+	}
+
+	if len(windows) == 0 {
+		return
+	}
+
+	for _, sink := range d.Sinks {
+		downtimeSink, ok := sink.(DowntimeSink)
+		if !ok {
+			continue
+		}
+
+		err := downtimeSink.LogDowntimeWindows(windows)
+		if err != nil {
+			d.logger.WithError(err).Panic("failed to write downtime window")
+		}
+	}
 }
 
 func (d *DataLogger) collectAndLogData() {
 	dataSnapshot := d.collectData()
 	d.logData(dataSnapshot)
+	d.logDowntimeWindows(false)
 }
 
 func (d *DataLogger) collectData() *DataSnapshot {
@@ -256,9 +486,14 @@ func (d *DataLogger) collectData() *DataSnapshot {
 	now := time.Now()
 	dataSnapshot := &DataSnapshot{
 		Time:            now.Sub(d.startTime).Seconds(),
+		Segment:         d.intervalSegment,
 		PerWorkloadData: make(map[string]WorkloadDataSnapshot),
 	}
 
+	// The segment of the next interval is the segment set when this interval
+	// ends.
+	d.intervalSegment = d.Benchmark.segment.Load()
+
 	// We need to take a snapshot of all current histograms. A snapshot implies it
 	// must be done extremely quickly. Specifically, from the moment we get the
 	// histogram from the first worker and the moment we get the histogram from
@@ -287,16 +522,46 @@ func (d *DataLogger) collectData() *DataSnapshot {
 	// the data.
 	region := trace.StartRegion(ctx, "AllocateSliceForData")
 	histograms := make(map[string][]*ExtendedHdrHistogram)
+	responseHistograms := make(map[string][]*ExtendedHdrHistogram)
+	looperLags := make(map[string][]*looperLag)
+	looperLagsCap := 0
 	for _, workload := range d.Benchmark.workloads {
 		config := workload.Config()
 		histograms[config.Name] = make([]*ExtendedHdrHistogram, workload.RateControlConfig().Concurrency)
+		responseHistograms[config.Name] = make([]*ExtendedHdrHistogram, workload.RateControlConfig().Concurrency)
+		if !workload.RateControlConfig().ClosedLoop {
+			looperLags[config.Name] = make([]*looperLag, workload.RateControlConfig().Concurrency)
+			looperLagsCap += workload.RateControlConfig().Concurrency
+		}
 	}
+	allLooperLags := make([]*looperLag, 0, looperLagsCap)
+
+	// The histograms per host and of the connection churn mode are appended
+	// to, so only the capacity is allocated here. The host labels are kept per
+	// workload, as the workloads may connect to different hosts, and the
+	// histograms of the same host are merged across the workloads.
+	hostLabels := make(map[string][]string)
+	hostHistograms := make(map[string][]*ExtendedHdrHistogram)
+	connectHistogramsCap := 0
+	for _, workload := range d.Benchmark.workloads {
+		connectHistogramsCap += workload.RateControlConfig().Concurrency
+		labels := workload.TargetLabels()
+		if len(labels) > 0 {
+			hostLabels[workload.Config().Name] = labels
+		}
+		for _, label := range labels {
+			hostHistograms[label] = make([]*ExtendedHdrHistogram, 0, cap(hostHistograms[label])+workload.RateControlConfig().Concurrency)
+		}
+	}
+
+	connectHistograms := make([]*ExtendedHdrHistogram, 0, connectHistogramsCap)
 
 	// Anonymous function declaration likely needs an allocation too (to capture
 	// variables via closure), so might as well do it early.
 	resetStartTime := func(h *ExtendedHdrHistogram) {
 		h.ResetStartTime(now)
 	}
+	noop := func(*looperLag) {}
 	region.End()
 
 	// Actually swap the data. When the data is swapped, the benchmark workers
@@ -311,6 +576,34 @@ func (d *DataLogger) collectData() *DataSnapshot {
 			histograms[config.Name][i] = onlineHist.Swap(resetStartTime)
 		})
 	}
+	for _, workload := range d.Benchmark.workloads {
+		config := workload.Config()
+		workload.ForEachResponseOnlineHistogram(func(i int, onlineHist *OnlineHistogram) {
+			responseHistograms[config.Name][i] = onlineHist.Swap(resetStartTime)
+		})
+	}
+	for _, workload := range d.Benchmark.workloads {
+		config := workload.Config()
+		workload.ForEachOnlineLooperLag(func(i int, onlineLag *OnlineLooperLag) {
+			looperLags[config.Name][i] = onlineLag.Swap(noop)
+		})
+	}
+	for _, workload := range d.Benchmark.workloads {
+		workload.ForEachConnectOnlineHistogram(func(i int, onlineHist *OnlineHistogram) {
+			connectHistograms = append(connectHistograms, onlineHist.Swap(resetStartTime))
+		})
+	}
+	for _, workload := range d.Benchmark.workloads {
+		labels := hostLabels[workload.Config().Name]
+		if len(labels) == 0 {
+			continue
+		}
+
+		workload.ForEachTargetOnlineHistogram(func(target int, onlineHist *OnlineHistogram) {
+			label := labels[target]
+			hostHistograms[label] = append(hostHistograms[label], onlineHist.Swap(resetStartTime))
+		})
+	}
 	region.End()
 
 	// Read and merge the data. This takes a long time and we don't want to block data collection.
@@ -324,16 +617,41 @@ func (d *DataLogger) collectData() *DataSnapshot {
 		break
 	}
 
-	allWorkloadsMergedHistogram := NewExtendedHdrHistogram(lastStartTime)
+	intervalMidpoint := lastStartTime.Add(now.Sub(lastStartTime) / 2)
+
+	// The merged histograms are in microseconds, regardless of the unit of the
+	// histograms of the workloads.
+	allWorkloadsHistConfig, allWorkloadsVisualization := d.allWorkloadsHistogramConfig()
+	allWorkloadsMergedHistogram := NewExtendedHdrHistogramWithConfig(lastStartTime, allWorkloadsHistConfig)
+	allWorkloadsMergedResponseHistogram := NewExtendedHdrHistogramWithConfig(lastStartTime, allWorkloadsHistConfig)
+	perWorkloadMergedHistograms := make(map[string]*ExtendedHdrHistogram, len(histograms))
+
+	// All workloads only have no desired rate if every workload is closed-loop.
+	dataSnapshot.AllWorkloadData.ClosedLoop = len(histograms) > 0
 	for workloadName, hists := range histograms {
-		perWorkloadMergedHistogram := NewExtendedHdrHistogram(lastStartTime)
+		workload := d.Benchmark.workloads[workloadName]
+		config := workload.Config()
+		histConfig := config.Histogram.inMicroseconds()
+
+		perWorkloadMergedHistogram := NewExtendedHdrHistogramWithConfig(lastStartTime, histConfig)
 		for _, hist := range hists {
 			perWorkloadMergedHistogram.Merge(hist)
 		}
+		perWorkloadMergedHistograms[workloadName] = perWorkloadMergedHistogram
 
-		// TODO: perhaps this is not the best way to get the LatencyHistMin and Max...
-		workload := d.Benchmark.workloads[workloadName]
-		config := workload.Config()
+		perWorkloadMergedResponseHistogram := NewExtendedHdrHistogramWithConfig(lastStartTime, histConfig)
+		for _, hist := range responseHistograms[workloadName] {
+			perWorkloadMergedResponseHistogram.Merge(hist)
+		}
+
+		// The desired rate may vary over time with a load profile. Using the
+		// midpoint of the interval gives the average desired rate over the
+		// interval for linear ramps.
+		desiredRate := workload.DesiredEventRate(intervalMidpoint)
+		closedLoop := workload.RateControlConfig().ClosedLoop
+		if closedLoop {
+			desiredRate = 0
+		}
 
 		dataSnapshot.PerWorkloadData[workloadName] = WorkloadDataSnapshot{
 			IntervalData: perWorkloadMergedHistogram.IntervalData(
@@ -341,15 +659,88 @@ func (d *DataLogger) collectData() *DataSnapshot {
 				config.Visualization.LatencyHistMin,
 				config.Visualization.LatencyHistMax,
 				config.Visualization.LatencyHistSize,
+				d.Percentiles,
 			),
-			DesiredRate: workload.RateControlConfig().EventRate,
+			DesiredRate:         desiredRate,
+			ClosedLoop:          closedLoop,
+			ResponseTime:        d.responseTimeData(perWorkloadMergedResponseHistogram, now, config.Visualization),
+			LooperLag:           mergeLooperLags(looperLags[workloadName]),
+			encodedHist:         d.encodeHistogram(perWorkloadMergedHistogram),
+			encodedResponseHist: d.encodeHistogram(perWorkloadMergedResponseHistogram),
 		}
 
+		d.overflowWarner.record(workloadName, perWorkloadMergedHistogram.overflowCount, config.Histogram)
+
 		allWorkloadsMergedHistogram.Merge(perWorkloadMergedHistogram)
-		dataSnapshot.AllWorkloadData.DesiredRate += workload.RateControlConfig().EventRate
+		allWorkloadsMergedResponseHistogram.Merge(perWorkloadMergedResponseHistogram)
+		allLooperLags = append(allLooperLags, looperLags[workloadName]...)
+		dataSnapshot.AllWorkloadData.DesiredRate += desiredRate
+		dataSnapshot.AllWorkloadData.ClosedLoop = dataSnapshot.AllWorkloadData.ClosedLoop && closedLoop
 	}
 
-	dataSnapshot.AllWorkloadData.IntervalData = allWorkloadsMergedHistogram.IntervalData(now, 1, 300000, 1000) // TODO: configurable
+	dataSnapshot.AllWorkloadData.IntervalData = allWorkloadsMergedHistogram.IntervalData(
+		now,
+		allWorkloadsVisualization.LatencyHistMin,
+		allWorkloadsVisualization.LatencyHistMax,
+		allWorkloadsVisualization.LatencyHistSize,
+		d.Percentiles,
+	)
+	dataSnapshot.AllWorkloadData.encodedHist = d.encodeHistogram(allWorkloadsMergedHistogram)
+	dataSnapshot.AllWorkloadData.ResponseTime = d.responseTimeData(allWorkloadsMergedResponseHistogram, now, allWorkloadsVisualization)
+	dataSnapshot.AllWorkloadData.encodedResponseHist = d.encodeHistogram(allWorkloadsMergedResponseHistogram)
+	dataSnapshot.AllWorkloadData.LooperLag = mergeLooperLags(allLooperLags)
+
+	if len(hostHistograms) > 0 {
+		dataSnapshot.PerHostData = make(map[string]WorkloadDataSnapshot)
+		for label, hists := range hostHistograms {
+			perHostMergedHistogram := NewExtendedHdrHistogramWithConfig(lastStartTime, allWorkloadsHistConfig)
+			for _, hist := range hists {
+				perHostMergedHistogram.Merge(hist)
+			}
+
+			dataSnapshot.PerHostData[label] = WorkloadDataSnapshot{
+				IntervalData: perHostMergedHistogram.IntervalData(
+					now,
+					allWorkloadsVisualization.LatencyHistMin,
+					allWorkloadsVisualization.LatencyHistMax,
+					allWorkloadsVisualization.LatencyHistSize,
+					d.Percentiles,
+				),
+				noDesiredRate: true,
+				encodedHist:   d.encodeHistogram(perHostMergedHistogram),
+			}
+		}
+	}
+
+	if len(connectHistograms) > 0 {
+		connectMergedHistogram := NewExtendedHdrHistogramWithConfig(lastStartTime, allWorkloadsHistConfig)
+		connectVisualization := allWorkloadsHistConfig.visualization()
+		for _, hist := range connectHistograms {
+			connectMergedHistogram.Merge(hist)
+		}
+
+		dataSnapshot.ConnectData = &WorkloadDataSnapshot{
+			IntervalData: connectMergedHistogram.IntervalData(
+				now,
+				connectVisualization.LatencyHistMin,
+				connectVisualization.LatencyHistMax,
+				connectVisualization.LatencyHistSize,
+				d.Percentiles,
+			),
+			noDesiredRate: true,
+			encodedHist:   d.encodeHistogram(connectMergedHistogram),
+		}
+	}
+
+	var warmupCompleted bool
+	dataSnapshot.Phase, warmupCompleted = d.warmup.intervalPhase(now, dataSnapshot.AllWorkloadData.Count)
+	if warmupCompleted {
+		d.logger.WithField("elapsed", dataSnapshot.Time).Info("warmup complete")
+	}
+
+	if dataSnapshot.Phase == PhaseMeasurement {
+		d.summaryAccumulator.add(dataSnapshot, perWorkloadMergedHistograms)
+	}
 	region.End()
 
 	// Reset all the histograms so it can be swapped again
@@ -359,31 +750,90 @@ func (d *DataLogger) collectData() *DataSnapshot {
 			hist.ResetDataOnly()
 		}
 	}
+	for _, hists := range responseHistograms {
+		for _, hist := range hists {
+			hist.ResetDataOnly()
+		}
+	}
+	for _, hists := range hostHistograms {
+		for _, hist := range hists {
+			hist.ResetDataOnly()
+		}
+	}
+	for _, hist := range connectHistograms {
+		hist.ResetDataOnly()
+	}
+	for _, lag := range allLooperLags {
+		lag.reset()
+	}
 	region.End()
 
 	return dataSnapshot
 }
 
+// The range of the uniform histogram of all workloads merged together and of
+// the data per host, which the ranges of the workloads can only widen.
+var allWorkloadsVisualizationFloor = VisualizationConfig{LatencyHistMin: 1, LatencyHistMax: 300000, LatencyHistSize: 1000}
+
+// Returns the histogram config in microseconds and the visualization config
+// that cover the ranges of all workloads, which are used for the data of all
+// workloads merged together and the data per host.
+func (d *DataLogger) allWorkloadsHistogramConfig() (HistogramConfig, VisualizationConfig) {
+	histConfig := DefaultHistogramConfig
+	visualization := allWorkloadsVisualizationFloor
+
+	first := true
+	for _, workload := range d.Benchmark.workloads {
+		config := workload.Config()
+		visualization = visualization.union(config.Visualization)
+		if first {
+			histConfig = config.Histogram.inMicroseconds()
+			first = false
+			continue
+		}
+
+		histConfig = histConfig.union(config.Histogram.inMicroseconds())
+	}
+
+	return histConfig, visualization
+}
+
+// The uniform histogram is only needed for the latency histograms of the
+// monitoring UI, which show the service time, so it is not kept.
+func (d *DataLogger) responseTimeData(hist *ExtendedHdrHistogram, endTime time.Time, visualization VisualizationConfig) *IntervalData {
+	data := hist.IntervalData(
+		endTime,
+		visualization.LatencyHistMin,
+		visualization.LatencyHistMax,
+		visualization.LatencyHistSize,
+		d.Percentiles,
+	)
+	data.UniformHist = nil
+	return &data
+}
+
+// Encoding the histogram should not fail, but if it does, the interval is
+// still logged without the histogram.
+func (d *DataLogger) encodeHistogram(hist *ExtendedHdrHistogram) string {
+	encoded, err := hist.encode()
+	if err != nil {
+		d.logger.WithError(err).Error("failed to encode histogram")
+	}
+
+	return encoded
+}
+
 func (d *DataLogger) logData(dataSnapshot *DataSnapshot) {
 	_, task := trace.NewTask(context.Background(), "LogData")
 	defer task.End()
 
 	d.dataRing.Push(dataSnapshot)
+	d.metrics.update(dataSnapshot)
 
-	args := dataSnapshot.AllWorkloadData.queryArgs("__all__", dataSnapshot.Time)
-	_, err := d.db.Exec(fmt.Sprintf(insertQuery, d.TableName), args...)
-	if err != nil {
-		d.logger.WithError(err).Panic("failed to write data")
-	}
-	d.logger.Debug(args)
-
-	for workloadName, workloadSnapshot := range dataSnapshot.PerWorkloadData {
-		args := workloadSnapshot.queryArgs(workloadName, dataSnapshot.Time)
-		_, err := d.db.Exec(fmt.Sprintf(insertQuery, d.TableName), args...)
+	for _, sink := range d.Sinks {
+		err := sink.Log(dataSnapshot)
 		if err != nil {
 			d.logger.WithError(err).Panic("failed to write data")
 		}
-		d.logger.Debug(args)
 	}
-
 }
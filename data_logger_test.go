@@ -0,0 +1,92 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+// Returns a workload with a worker whose histograms are swapped by the data
+// logger, without running it.
+func newWorkloadForTest(name string, databaseConfig DatabaseConfig, startTime time.Time) *Workload[struct{}] {
+	worker := &BenchmarkWorker[struct{}]{
+		onlineHist:   NewOnlineHistogram(startTime),
+		responseHist: NewOnlineHistogram(startTime),
+	}
+	if len(databaseConfig.Replicas) > 0 {
+		worker.targetHists = make([]*OnlineHistogram, len(databaseConfig.Replicas)+1)
+		for i := range worker.targetHists {
+			worker.targetHists[i] = NewOnlineHistogram(startTime)
+		}
+	}
+
+	rateControlConfig := RateControlConfig{Concurrency: 1, ClosedLoop: true}
+	return &Workload[struct{}]{
+		WorkloadConfig: WorkloadConfig{
+			Name:          name,
+			Histogram:     DefaultHistogramConfig,
+			Visualization: VisualizationConfig{LatencyHistMin: 0, LatencyHistMax: 50000, LatencyHistSize: 1000},
+		},
+		workers:            []*BenchmarkWorker[struct{}]{worker},
+		databaseConfig:     databaseConfig,
+		rateControlConfig:  rateControlConfig,
+		benchmarkEventRate: newEventRateController(startTime, rateControlConfig),
+		workloadScale:      atomic.NewFloat64(1),
+	}
+}
+
+func TestCollectDataPerHost(t *testing.T) {
+	d, _ := newDataLoggerForTest(t)
+	d.startTime = time.Now()
+	d.warmup = newWarmupTracker(d.startTime, 0, 0)
+
+	// The workloads read from different replicas of the same primary.
+	primary := DatabaseConfig{Host: "primary", Port: 3306}
+	a := primary
+	a.Replicas = Replicas{{Host: "replica-a", Port: 3306}}
+	b := primary
+	b.Replicas = Replicas{{Host: "replica-b", Port: 3306}}
+
+	workloadA := newWorkloadForTest("A", a, d.startTime)
+	workloadB := newWorkloadForTest("B", b, d.startTime)
+	d.Benchmark.workloads = map[string]AbstractWorkload{"A": workloadA, "B": workloadB}
+	d.Benchmark.segment = atomic.NewString("")
+
+	workloadA.workers[0].targetHists[0].RecordValue(1000)
+	workloadA.workers[0].targetHists[1].RecordValue(2000)
+	workloadB.workers[0].targetHists[0].RecordValue(1000)
+	workloadB.workers[0].targetHists[1].RecordValue(3000)
+	workloadB.workers[0].targetHists[1].RecordValue(3000)
+
+	dataSnapshot := d.collectData()
+	require.Len(t, dataSnapshot.PerHostData, 3)
+	require.Equal(t, int64(2), dataSnapshot.PerHostData["primary:3306"].Count)
+	require.Equal(t, int64(1), dataSnapshot.PerHostData["replica-a:3306"].Count)
+	require.Equal(t, int64(2), dataSnapshot.PerHostData["replica-b:3306"].Count)
+}
+
+func TestCollectDataClosedLoop(t *testing.T) {
+	d, _ := newDataLoggerForTest(t)
+	d.startTime = time.Now()
+	d.warmup = newWarmupTracker(d.startTime, 0, 0)
+	d.Benchmark.segment = atomic.NewString("")
+
+	workloadA := newWorkloadForTest("A", DatabaseConfig{}, d.startTime)
+	workloadB := newWorkloadForTest("B", DatabaseConfig{}, d.startTime)
+	d.Benchmark.workloads = map[string]AbstractWorkload{"A": workloadA, "B": workloadB}
+	require.True(t, d.collectData().AllWorkloadData.ClosedLoop)
+
+	// The desired rate of all workloads is logged as soon as one workload is
+	// open-loop, regardless of the order the workloads are merged in.
+	workloadB.rateControlConfig = RateControlConfig{EventRate: 100, Concurrency: 1}
+	workloadB.benchmarkEventRate = newEventRateController(d.startTime, workloadB.rateControlConfig)
+	workloadB.workers[0].looperLag = NewOnlineLooperLag()
+	for i := 0; i < 10; i++ {
+		dataSnapshot := d.collectData()
+		require.False(t, dataSnapshot.AllWorkloadData.ClosedLoop)
+		require.True(t, dataSnapshot.PerWorkloadData["A"].ClosedLoop)
+		require.False(t, dataSnapshot.PerWorkloadData["B"].ClosedLoop)
+	}
+}
@@ -0,0 +1,118 @@
+package mybench
+
+import (
+	"strings"
+	"time"
+)
+
+// A DataSink receives the data collected by the DataLogger every interval,
+// such as to write it to a file. Multiple sinks can be used at once, see
+// DataLogger.Sinks. The methods are only called from the data logger
+// goroutine.
+type DataSink interface {
+	// Called once when the data logger starts, before any data is logged.
+	Open(run RunInfo) error
+
+	// Called with the data of every interval. The rows of the data can be
+	// obtained via DataSnapshot.Rows.
+	Log(dataSnapshot *DataSnapshot) error
+
+	// Called once when the data logger stops. The summary is nil if there is
+	// no data in the measurement phase.
+	Close(summary *RunSummary) error
+}
+
+// Sinks that implement the DowntimeSink also receive the downtime windows of
+// the workloads, see DowntimeWindow. The windows are passed once they end, and
+// the windows that have not ended are passed when the data logger stops.
+type DowntimeSink interface {
+	LogDowntimeWindows(windows []DowntimeWindow) error
+}
+
+// The run the data belongs to, which is passed to DataSink.Open.
+type RunInfo struct {
+	Benchmark string
+
+	// The name of the log table of the run, which identifies the run.
+	TableName string
+
+	Note        string
+	StartTime   time.Time
+	Percentiles Percentiles
+
+	// See DataLogger.ResolvedConfig.
+	ResolvedConfig map[string]string
+}
+
+// The columns of the log table up to retry_count, which do not depend on the
+// configuration.
+var fixedLogColumns = []string{
+	"workload",
+	"host",
+	"seconds_since_start",
+	"interval_start",
+	"interval_end",
+	"segment",
+	"phase",
+	"desired_rate",
+	"count",
+	"delta",
+	"rate",
+	"min",
+	"mean",
+	"max",
+	"underflow_count",
+	"overflow_count",
+	"error_count",
+	"deadlock_count",
+	"lock_wait_timeout_count",
+	"lost_connection_count",
+	"query_timeout_count",
+	"other_error_count",
+	"retry_count",
+}
+
+// Returns the names of the values of the rows returned by DataSnapshot.Rows,
+// which are the columns of the log table of the SQLiteSink except for its id.
+func LogColumns(percentiles Percentiles) []string {
+	columns := append([]string{}, fixedLogColumns...)
+	for _, column := range dataColumns(percentiles) {
+		columns = append(columns, strings.Fields(column)[0])
+	}
+
+	return columns
+}
+
+// Returns the definitions of the columns that follow retry_count in the log
+// table of the SQLiteSink, in the order of the values of the rows:
+//
+//   - A column per configured percentile, such as percentile99 and
+//     percentile99_9.
+//   - hdr_histogram: the merged HDR histogram of the interval in the base64
+//     encoded compressed HdrHistogram format, see MergeLoggedIntervals.
+//   - The same latency columns for the response time, such as
+//     response_time_percentile99, which are NULL except for the workloads and
+//     __all__. See EventStat.ResponseTime.
+//   - The looper lag columns, which are NULL in the closed-loop mode and for
+//     the data that does not belong to the workloads. See LooperLagData.
+func dataColumns(percentiles Percentiles) []string {
+	percentileColumns := percentiles.columnNames()
+
+	columns := make([]string, 0, 2*len(percentileColumns)+8)
+	for _, column := range percentileColumns {
+		columns = append(columns, column+" INTEGER")
+	}
+	columns = append(columns, "hdr_histogram TEXT", "response_time_mean INTEGER", "response_time_max INTEGER")
+	for _, column := range percentileColumns {
+		columns = append(columns, "response_time_"+column+" INTEGER")
+	}
+	columns = append(columns,
+		"response_time_hdr_histogram TEXT",
+		"looper_wakeup_lateness_mean REAL",
+		"looper_wakeup_lateness_max INTEGER",
+		"looper_backlog INTEGER",
+		"looper_catching_up_share REAL",
+	)
+
+	return columns
+}
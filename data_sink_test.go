@@ -0,0 +1,190 @@
+package mybench
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dataSnapshotForTest(startTime time.Time, percentiles Percentiles) *DataSnapshot {
+	dataSnapshot := &DataSnapshot{
+		Time:            1,
+		Phase:           PhaseMeasurement,
+		PerWorkloadData: make(map[string]WorkloadDataSnapshot),
+	}
+
+	all := NewExtendedHdrHistogram(startTime)
+	for i, workloadName := range []string{"B", "A"} {
+		hist := NewExtendedHdrHistogram(startTime)
+		for j := 0; j < 10; j++ {
+			hist.RecordValue(int64(1000 * (i + 1)))
+		}
+		all.Merge(hist)
+
+		dataSnapshot.PerWorkloadData[workloadName] = WorkloadDataSnapshot{
+			IntervalData: hist.IntervalData(startTime.Add(time.Second), 1, 300000, 1000, percentiles),
+			DesiredRate:  10,
+			LooperLag:    &LooperLagData{Iterations: 50, Backlog: 3},
+		}
+	}
+
+	dataSnapshot.AllWorkloadData = WorkloadDataSnapshot{
+		IntervalData: all.IntervalData(startTime.Add(time.Second), 1, 300000, 1000, percentiles),
+		DesiredRate:  20,
+	}
+
+	return dataSnapshot
+}
+
+func TestCSVSink(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filename := filepath.Join(t.TempDir(), "data.csv")
+	percentiles := Percentiles{50, 99}
+
+	// The second run is appended to the file of the first run.
+	for _, tableName := range []string{"T1", "T2"} {
+		sink := NewCSVSink(filename)
+		require.Nil(t, sink.Open(RunInfo{TableName: tableName, Percentiles: percentiles}))
+		require.Nil(t, sink.Log(dataSnapshotForTest(startTime, percentiles)))
+		require.Nil(t, sink.Close(nil))
+	}
+
+	file, err := os.Open(filename)
+	require.Nil(t, err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	require.Nil(t, err)
+	require.Equal(t, 7, len(records))
+
+	header := records[0]
+	require.Equal(t, append([]string{"table_name"}, LogColumns(percentiles)...), header)
+
+	value := func(record []string, column string) string {
+		for i, name := range header {
+			if name == column {
+				return record[i]
+			}
+		}
+		t.Fatalf("missing column %s", column)
+		return ""
+	}
+
+	require.Equal(t, "T1", value(records[1], "table_name"))
+	require.Equal(t, "__all__", value(records[1], "workload"))
+	require.Equal(t, "A", value(records[2], "workload"))
+	require.Equal(t, "10", value(records[2], "count"))
+	require.Equal(t, "2000", value(records[2], "percentile99"))
+	require.Equal(t, "3", value(records[2], "looper_backlog"))
+	require.Equal(t, "", value(records[1], "looper_backlog"))
+	require.Equal(t, "B", value(records[3], "workload"))
+	require.Equal(t, "T2", value(records[4], "table_name"))
+
+	sink := NewCSVSink(filename)
+	err = sink.Open(RunInfo{TableName: "T3", Percentiles: Percentiles{50, 99.9}})
+	require.ErrorContains(t, err, "different columns")
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filename := filepath.Join(t.TempDir(), "data.jsonl")
+	percentiles := Percentiles{50, 99}
+
+	sink := NewJSONLinesSink(filename)
+	require.Nil(t, sink.Open(RunInfo{TableName: "T1", Percentiles: percentiles}))
+	require.Nil(t, sink.Log(dataSnapshotForTest(startTime, percentiles)))
+	require.Nil(t, sink.Close(nil))
+
+	file, err := os.Open(filename)
+	require.Nil(t, err)
+	defer file.Close()
+
+	var rows []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var row map[string]interface{}
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.Nil(t, scanner.Err())
+
+	require.Equal(t, 3, len(rows))
+	require.Equal(t, len(LogColumns(percentiles))+1, len(rows[0]))
+	require.Equal(t, "T1", rows[0]["table_name"])
+	require.Equal(t, "__all__", rows[0]["workload"])
+	require.Nil(t, rows[0]["host"])
+	require.Equal(t, 20.0, rows[0]["count"])
+	require.Equal(t, "A", rows[1]["workload"])
+	require.Equal(t, 2000.0, rows[1]["percentile50"])
+	require.Equal(t, string(PhaseMeasurement), rows[1]["phase"])
+}
+
+func TestDataLoggerSinks(t *testing.T) {
+	_, err := NewDataLogger(&DataLogger{})
+	require.ErrorContains(t, err, "must specify")
+
+	dir := t.TempDir()
+	dataLogger, err := NewDataLogger(&DataLogger{
+		OutputFilename: filepath.Join(dir, "data.sqlite"),
+		Sinks:          []DataSink{NewJSONLinesSink(filepath.Join(dir, "data.jsonl"))},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(dataLogger.Sinks))
+	require.IsType(t, &SQLiteSink{}, dataLogger.Sinks[0])
+	require.Equal(t, dir, dataLogger.OutputDir)
+}
+
+// A sink that records whether it is open, and fails to open if openErr is set.
+type recordingSinkForTest struct {
+	openErr error
+	open    bool
+	closed  bool
+}
+
+func (s *recordingSinkForTest) Open(run RunInfo) error {
+	if s.openErr != nil {
+		return s.openErr
+	}
+
+	s.open = true
+	return nil
+}
+
+func (s *recordingSinkForTest) Log(dataSnapshot *DataSnapshot) error {
+	return nil
+}
+
+func (s *recordingSinkForTest) Close(summary *RunSummary) error {
+	s.open = false
+	s.closed = true
+	return nil
+}
+
+func TestDataLoggerClosesOpenedSinksOnOpenError(t *testing.T) {
+	openErr := errors.New("cannot open sink")
+	sinks := []*recordingSinkForTest{{}, {}, {openErr: openErr}, {}}
+
+	dataLogger, err := NewDataLogger(&DataLogger{
+		Benchmark: &Benchmark{Name: "test"},
+		Sinks:     []DataSink{sinks[0], sinks[1], sinks[2], sinks[3]},
+	})
+	require.Nil(t, err)
+	require.ErrorIs(t, dataLogger.openSinks(), openErr)
+
+	for i, sink := range sinks {
+		require.False(t, sink.open, i)
+	}
+	require.True(t, sinks[0].closed)
+	require.True(t, sinks[1].closed)
+
+	// The sinks that were not opened are not closed.
+	require.False(t, sinks[2].closed)
+	require.False(t, sinks[3].closed)
+}
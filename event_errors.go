@@ -0,0 +1,184 @@
+package mybench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// MySQL error codes used to classify the errors returned by Event that are not
+// defined by the go-mysql library, which only has the server error codes up to
+// MySQL 5.5. The other codes are the mysql.ER_* constants. The client error
+// codes for lost connections, such as CR_SERVER_LOST, are not needed, as
+// go-mysql returns mysql.ErrBadConn or a network error instead.
+const (
+	ErrCodeQueryTimeout     uint16 = 3024 // ER_QUERY_TIMEOUT, added in MySQL 5.7
+	ErrCodeConnectionKilled uint16 = 1927 // ER_CONNECTION_KILLED, from MariaDB
+)
+
+// The class of an error returned by Event, which determines the column it is
+// counted in within the log table.
+type ErrorClass int
+
+const (
+	ErrorClassOther ErrorClass = iota
+	ErrorClassDeadlock
+	ErrorClassLockWaitTimeout
+	ErrorClassLostConnection
+	ErrorClassQueryTimeout
+)
+
+var errorClassNames = map[ErrorClass]string{
+	ErrorClassOther:           "other",
+	ErrorClassDeadlock:        "deadlock",
+	ErrorClassLockWaitTimeout: "lock_wait_timeout",
+	ErrorClassLostConnection:  "lost_connection",
+	ErrorClassQueryTimeout:    "query_timeout",
+}
+
+func (c ErrorClass) String() string {
+	return errorClassNames[c]
+}
+
+// Returns the MySQL error code of the error, if the error is or wraps an error
+// returned by the MySQL server.
+func MySQLErrorCode(err error) (uint16, bool) {
+	var myErr *mysql.MyError
+	if errors.As(err, &myErr) {
+		return myErr.Code, true
+	}
+
+	return 0, false
+}
+
+// Classifies an error returned by Event based on its MySQL error code. Errors
+// from the network or the client library that indicate the connection is
+// broken are classified as ErrorClassLostConnection. Events that exceed the
+// event timeout are classified as ErrorClassQueryTimeout.
+func ClassifyError(err error) ErrorClass {
+	if isEventTimeout(err) {
+		return ErrorClassQueryTimeout
+	}
+
+	if code, ok := MySQLErrorCode(err); ok {
+		switch code {
+		case mysql.ER_LOCK_DEADLOCK:
+			return ErrorClassDeadlock
+		case mysql.ER_LOCK_WAIT_TIMEOUT:
+			return ErrorClassLockWaitTimeout
+		case ErrCodeConnectionKilled:
+			return ErrorClassLostConnection
+		case mysql.ER_QUERY_INTERRUPTED, ErrCodeQueryTimeout:
+			return ErrorClassQueryTimeout
+		}
+
+		return ErrorClassOther
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassQueryTimeout
+	}
+
+	var netErr net.Error
+	if errors.Is(err, mysql.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+		return ErrorClassLostConnection
+	}
+
+	return ErrorClassOther
+}
+
+// The number of failed events per ErrorClass. The counts are recorded by the
+// benchmark workers alongside the latency histogram and logged in the error
+// columns of the log table.
+type ErrorCounts struct {
+	Deadlock        int64
+	LockWaitTimeout int64
+	LostConnection  int64
+	QueryTimeout    int64
+	Other           int64
+}
+
+func (c *ErrorCounts) Add(class ErrorClass) {
+	switch class {
+	case ErrorClassDeadlock:
+		c.Deadlock++
+	case ErrorClassLockWaitTimeout:
+		c.LockWaitTimeout++
+	case ErrorClassLostConnection:
+		c.LostConnection++
+	case ErrorClassQueryTimeout:
+		c.QueryTimeout++
+	default:
+		c.Other++
+	}
+}
+
+func (c *ErrorCounts) Merge(other ErrorCounts) {
+	c.Deadlock += other.Deadlock
+	c.LockWaitTimeout += other.LockWaitTimeout
+	c.LostConnection += other.LostConnection
+	c.QueryTimeout += other.QueryTimeout
+	c.Other += other.Other
+}
+
+// Returns the number of failed events of the class.
+func (c ErrorCounts) count(class ErrorClass) int64 {
+	switch class {
+	case ErrorClassDeadlock:
+		return c.Deadlock
+	case ErrorClassLockWaitTimeout:
+		return c.LockWaitTimeout
+	case ErrorClassLostConnection:
+		return c.LostConnection
+	case ErrorClassQueryTimeout:
+		return c.QueryTimeout
+	default:
+		return c.Other
+	}
+}
+
+func (c ErrorCounts) Total() int64 {
+	return c.Deadlock + c.LockWaitTimeout + c.LostConnection + c.QueryTimeout + c.Other
+}
+
+// Decides what a benchmark worker does when Event returns an error. The error
+// is counted regardless of the policy.
+type ErrorPolicy int
+
+const (
+	// Count the error and keep calling Event.
+	ErrorPolicyContinue ErrorPolicy = iota
+
+	// Stop the worker that encountered the error. The other workers keep
+	// running, which lowers the achieved event rate of the workload.
+	ErrorPolicyStopWorker
+
+	// Stop the whole benchmark and exit with the error.
+	ErrorPolicyAbort
+)
+
+var errorPolicyNames = map[ErrorPolicy]string{
+	ErrorPolicyContinue:   "continue",
+	ErrorPolicyStopWorker: "stopworker",
+	ErrorPolicyAbort:      "abort",
+}
+
+func (p ErrorPolicy) String() string {
+	return errorPolicyNames[p]
+}
+
+// Allows ErrorPolicy to be used as a flag.Value.
+func (p *ErrorPolicy) Set(s string) error {
+	for policy, name := range errorPolicyNames {
+		if name == s {
+			*p = policy
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown error policy %s, must be one of continue, stopworker, abort", s)
+}
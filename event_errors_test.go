@@ -0,0 +1,110 @@
+package mybench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	require.Equal(t, ErrorClassDeadlock, ClassifyError(mysql.NewError(mysql.ER_LOCK_DEADLOCK, "Deadlock found when trying to get lock")))
+	require.Equal(t, ErrorClassLockWaitTimeout, ClassifyError(mysql.NewError(mysql.ER_LOCK_WAIT_TIMEOUT, "Lock wait timeout exceeded")))
+	require.Equal(t, ErrorClassLostConnection, ClassifyError(mysql.NewError(ErrCodeConnectionKilled, "Connection was killed")))
+	require.Equal(t, ErrorClassQueryTimeout, ClassifyError(mysql.NewError(ErrCodeQueryTimeout, "Query execution was interrupted, maximum statement execution time exceeded")))
+	require.Equal(t, ErrorClassOther, ClassifyError(mysql.NewError(1062, "Duplicate entry")))
+
+	require.Equal(t, ErrorClassDeadlock, ClassifyError(fmt.Errorf("insert failed: %w", mysql.NewError(mysql.ER_LOCK_DEADLOCK, "Deadlock found when trying to get lock"))))
+
+	require.Equal(t, ErrorClassLostConnection, ClassifyError(fmt.Errorf("query failed: %w", mysql.ErrBadConn)))
+	require.Equal(t, ErrorClassLostConnection, ClassifyError(io.EOF))
+	require.Equal(t, ErrorClassQueryTimeout, ClassifyError(context.DeadlineExceeded))
+	require.Equal(t, ErrorClassOther, ClassifyError(errors.New("unexpected result")))
+}
+
+func TestClassifyLostConnectionErrors(t *testing.T) {
+	s := newTestMySQLServer(t)
+
+	conn, err := s.databaseConfig().Connection()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	killer, err := s.databaseConfig().Connection()
+	require.Nil(t, err)
+	defer killer.Close()
+
+	// The errors go-mysql returns once the server closed the connection.
+	_, err = killer.Execute(fmt.Sprintf("KILL %d", s.connections()[0].conn.ConnectionID()))
+	require.Nil(t, err)
+	_, err = conn.Execute("SELECT 1")
+	require.NotNil(t, err)
+	require.Equal(t, ErrorClassLostConnection, ClassifyError(err), err.Error())
+
+	_, err = conn.Execute("SELECT 1")
+	require.NotNil(t, err)
+	require.Equal(t, ErrorClassLostConnection, ClassifyError(err), err.Error())
+}
+
+func TestErrorCountsInIntervalData(t *testing.T) {
+	startTime := time.Now()
+	hist1 := NewExtendedHdrHistogram(startTime)
+	hist1.RecordValue(1000)
+	hist1.RecordError(ErrorClassDeadlock)
+	hist1.RecordError(ErrorClassDeadlock)
+
+	hist2 := NewExtendedHdrHistogram(startTime)
+	hist2.RecordError(ErrorClassLostConnection)
+	hist2.RecordError(ErrorClassOther)
+
+	hist1.Merge(hist2)
+	data := hist1.IntervalData(startTime.Add(time.Second), 0, 50000, 1000, DefaultPercentiles)
+	require.Equal(t, int64(1), data.Count)
+	require.Equal(t, ErrorCounts{Deadlock: 2, LostConnection: 1, Other: 1}, data.Errors)
+	require.Equal(t, int64(4), data.Errors.Total())
+
+	hist1.ResetDataOnly()
+	data = hist1.IntervalData(startTime.Add(time.Second), 0, 50000, 1000, DefaultPercentiles)
+	require.Equal(t, int64(0), data.Errors.Total())
+}
+
+func TestClosedLooperHandleEventError(t *testing.T) {
+	numEvents := 0
+	var handledErrors []error
+	looper := &ClosedLooper{
+		Event: func(context.Context) error {
+			numEvents++
+			if numEvents%2 == 0 {
+				return fmt.Errorf("event %d failed", numEvents)
+			}
+			return nil
+		},
+		HandleEventError: func(_ context.Context, err error) error {
+			handledErrors = append(handledErrors, err)
+			if len(handledErrors) == 3 {
+				return err
+			}
+			return nil
+		},
+	}
+
+	err := looper.Run(context.Background())
+	require.EqualError(t, err, "event 6 failed")
+	require.Equal(t, 6, numEvents)
+	require.Len(t, handledErrors, 3)
+}
+
+func TestErrorPolicySet(t *testing.T) {
+	var policy ErrorPolicy
+	require.Equal(t, ErrorPolicyContinue, policy)
+	require.Nil(t, policy.Set("stopworker"))
+	require.Equal(t, ErrorPolicyStopWorker, policy)
+	require.Nil(t, policy.Set("abort"))
+	require.Equal(t, ErrorPolicyAbort, policy)
+	require.Equal(t, "abort", policy.String())
+	require.NotNil(t, policy.Set("panic"))
+}
@@ -0,0 +1,196 @@
+package mybench
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/sirupsen/logrus"
+)
+
+// Returned instead of the error of an event that ran longer than the event
+// timeout, which is counted as ErrorClassQueryTimeout. Err is the error
+// returned by the event after its query was killed, which is usually
+// ER_QUERY_INTERRUPTED, or nil if the event completed regardless.
+type EventTimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *EventTimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("event timed out after %s", e.Timeout)
+	}
+
+	return fmt.Sprintf("event timed out after %s: %s", e.Timeout, e.Err)
+}
+
+func (e *EventTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func isEventTimeout(err error) bool {
+	var timeoutErr *EventTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// Kills the queries or the connections of a benchmark worker. This cannot be
+// done over the connections of the worker as they are busy with the query, so
+// a side connection is opened to each target when it is first needed.
+//
+// The kill methods are called from other goroutines than the worker, such as
+// the timer of the event timeout or the benchmark when draining the workers
+// times out. The worker updates the connection IDs after opening new
+// connections via update.
+type queryKiller struct {
+	mut     *sync.Mutex
+	targets []killTarget
+}
+
+type killTarget struct {
+	config        DatabaseConfig
+	connectionIDs []uint32
+	sideConn      *Connection
+}
+
+func newQueryKiller(targets *workerTargets) *queryKiller {
+	k := &queryKiller{
+		mut:     &sync.Mutex{},
+		targets: make([]killTarget, len(targets.conns)),
+	}
+
+	for i, conn := range targets.conns {
+		// A single connection is enough to kill the queries, and it should be
+		// opened as quickly as possible.
+		k.targets[i].config = conn.config
+		k.targets[i].config.ConnectionMultiplier = 1
+		k.targets[i].config.InitStatements = nil
+	}
+
+	k.update(targets)
+	return k
+}
+
+// Records the connection IDs of the connections of the worker. Must be called
+// by the worker whenever it opens new connections.
+func (k *queryKiller) update(targets *workerTargets) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	for i, conn := range targets.conns {
+		k.targets[i].connectionIDs = k.targets[i].connectionIDs[:0]
+		for _, c := range conn.connList {
+			k.targets[i].connectionIDs = append(k.targets[i].connectionIDs, c.GetConnectionID())
+		}
+	}
+}
+
+// Kills the queries running on all connections of the worker with KILL QUERY.
+// Killing a connection that is not running a query has no effect.
+func (k *queryKiller) killQueries() error {
+	return k.kill("KILL QUERY %d")
+}
+
+// Kills all connections of the worker with KILL, which unblocks the worker
+// even if it is not running a query, such as while reading a result.
+func (k *queryKiller) killConnections() error {
+	return k.kill("KILL %d")
+}
+
+func (k *queryKiller) kill(statementFormat string) error {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	var err error
+	for i := range k.targets {
+		target := &k.targets[i]
+		if target.config.NoConnection || len(target.connectionIDs) == 0 {
+			continue
+		}
+
+		if target.sideConn == nil {
+			target.sideConn, err = target.config.Connection()
+			if err != nil {
+				target.sideConn = nil
+				return err
+			}
+		}
+
+		for _, connectionID := range target.connectionIDs {
+			_, err = target.sideConn.Conn.Execute(fmt.Sprintf(statementFormat, connectionID))
+			if err != nil {
+				// The query may have completed in the meantime, in which case the
+				// connection might not exist anymore.
+				if code, ok := MySQLErrorCode(err); ok && code == mysql.ER_NO_SUCH_THREAD {
+					err = nil
+					continue
+				}
+
+				// The side connection may be broken, so it is opened again next time.
+				target.sideConn.Close()
+				target.sideConn = nil
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (k *queryKiller) close() {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	for i := range k.targets {
+		if k.targets[i].sideConn != nil {
+			k.targets[i].sideConn.Close()
+			k.targets[i].sideConn = nil
+		}
+	}
+}
+
+// Enforces the event timeout of a benchmark worker. The same timer is reused
+// for every event to avoid allocations in the event loop.
+type eventTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+
+	// Signals that the queries were killed after the timer fired.
+	killed chan struct{}
+}
+
+func newEventTimer(timeout time.Duration, killer *queryKiller, logger logrus.FieldLogger) *eventTimer {
+	t := &eventTimer{
+		timeout: timeout,
+		killed:  make(chan struct{}, 1),
+	}
+
+	t.timer = time.AfterFunc(timeout, func() {
+		err := killer.killQueries()
+		if err != nil {
+			logger.WithError(err).Warn("failed to kill the query of a timed out event")
+		}
+		t.killed <- struct{}{}
+	})
+	t.timer.Stop()
+
+	return t
+}
+
+func (t *eventTimer) start() {
+	t.timer.Reset(t.timeout)
+}
+
+// Stops the timer after the event returns. If the timer fired, it waits until
+// the queries are killed, so the kill cannot affect the next event, and
+// returns an EventTimeoutError wrapping the error of the event.
+func (t *eventTimer) stop(err error) error {
+	if t.timer.Stop() {
+		return err
+	}
+
+	<-t.killed
+	return &EventTimeoutError{Timeout: t.timeout, Err: err}
+}
@@ -0,0 +1,160 @@
+package mybench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// A workload whose events sleep for the given duration.
+type sleepingWorkloadForTest struct {
+	WorkloadConfig
+	NoContextData
+	sleep time.Duration
+	err   error
+}
+
+func (w *sleepingWorkloadForTest) Event(WorkerContext[NoContextData]) error {
+	time.Sleep(w.sleep)
+	return w.err
+}
+
+func newTimeoutWorkerForTest(t *testing.T, workload *sleepingWorkloadForTest, eventTimeout time.Duration) *BenchmarkWorker[NoContextData] {
+	rateControlConfig := RateControlConfig{EventRate: 100, Concurrency: 1, OuterLoopRate: 50}
+	worker, err := NewBenchmarkWorker[NoContextData](workload, DatabaseConfig{NoConnection: true}, rateControlConfig, ErrorPolicyContinue, RetryConfig{}, eventTimeout, newAvailabilityTracker("timeout"), func(time.Time) float64 { return 100 }, DefaultHistogramConfig)
+	require.Nil(t, err)
+	worker.onlineHist = NewOnlineHistogram(time.Now())
+	return worker
+}
+
+func TestEventTimeout(t *testing.T) {
+	workload := &sleepingWorkloadForTest{WorkloadConfig: WorkloadConfig{Name: "timeout"}, sleep: 30 * time.Millisecond}
+	worker := newTimeoutWorkerForTest(t, workload, 10*time.Millisecond)
+
+	looper := worker.looper.(*DiscretizedLooper)
+	err := looper.Event(context.Background())
+	require.Equal(t, ErrorClassQueryTimeout, ClassifyError(err))
+	require.EqualError(t, err, "event timed out after 10ms")
+
+	// The error of the event is kept, but the event is still counted as a
+	// timeout.
+	workload.err = mysql.NewError(mysql.ER_QUERY_INTERRUPTED, "Query execution was interrupted")
+	err = looper.Event(context.Background())
+	require.Equal(t, ErrorClassQueryTimeout, ClassifyError(err))
+	require.True(t, errors.Is(err, workload.err))
+
+	// The timer does not fire for events that complete in time, even after
+	// previous events timed out.
+	workload.sleep = 0
+	workload.err = nil
+	require.Nil(t, looper.Event(context.Background()))
+}
+
+func TestNoEventTimeout(t *testing.T) {
+	workload := &sleepingWorkloadForTest{WorkloadConfig: WorkloadConfig{Name: "timeout"}, sleep: 10 * time.Millisecond}
+	worker := newTimeoutWorkerForTest(t, workload, 0)
+	require.Nil(t, worker.eventTimer)
+	require.Nil(t, worker.looper.(*DiscretizedLooper).Event(context.Background()))
+}
+
+func TestEventTimeoutErrorClassification(t *testing.T) {
+	// The timeout takes precedence over the error returned after the query is
+	// killed, which may be any error.
+	deadlock := mysql.NewError(mysql.ER_LOCK_DEADLOCK, "Deadlock found when trying to get lock")
+	require.Equal(t, ErrorClassQueryTimeout, ClassifyError(&EventTimeoutError{Timeout: time.Second, Err: deadlock}))
+	require.Equal(t, ErrorClassQueryTimeout, ClassifyError(fmt.Errorf("wrapped: %w", &EventTimeoutError{Timeout: time.Second})))
+}
+
+func TestLooperStopsWithinBatch(t *testing.T) {
+	// With a low outer loop rate, all events of a second are executed in a
+	// single batch, which takes far longer than the benchmark runs.
+	looper := &DiscretizedLooper{
+		EventRate:     1000,
+		OuterLoopRate: 1,
+		Event: func(context.Context) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	require.Nil(t, looper.Run(ctx))
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+// A workload whose events run a query that blocks until it is killed, which
+// ignores the cancellation of the benchmark.
+type stuckWorkloadForTest struct {
+	WorkloadConfig
+	NoContextData
+	started chan struct{}
+}
+
+func (w *stuckWorkloadForTest) Event(ctx WorkerContext[NoContextData]) error {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+
+	_, err := ctx.Conn.Execute("SELECT SLEEP(3600)")
+	return err
+}
+
+func TestWaitForWorkloadsKillsConnections(t *testing.T) {
+	s := newTestMySQLServer(t)
+
+	workloadIface := &stuckWorkloadForTest{WorkloadConfig: WorkloadConfig{Name: "stuck"}, started: make(chan struct{}, 1)}
+	workload := NewWorkload[NoContextData](workloadIface)
+	rateControlConfig := RateControlConfig{EventRate: 100, Concurrency: 1, OuterLoopRate: 50}
+	workload.FinishInitialization(s.databaseConfig(), rateControlConfig, newEventRateController(time.Now(), rateControlConfig), ErrorPolicyContinue, RetryConfig{}, 0, func(error) {})
+
+	b := &Benchmark{
+		BenchmarkConfig: BenchmarkConfig{DrainTimeout: 100 * time.Millisecond},
+		logger:          logrus.WithField("tag", "test"),
+		workloads:       map[string]AbstractWorkload{"stuck": workload},
+		workloadWg:      &sync.WaitGroup{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workerInitializationWg := &sync.WaitGroup{}
+	workerInitializationWg.Add(1)
+	b.workloadWg.Add(1)
+	workloadDone := make(chan struct{})
+	go func() {
+		defer b.workloadWg.Done()
+		workload.Run(ctx, workerInitializationWg, time.Now())
+		close(workloadDone)
+	}()
+
+	workerInitializationWg.Wait()
+	<-workloadIface.started
+	cancel()
+
+	// The worker only stops once its connection is killed after the drain
+	// timeout, rather than being abandoned.
+	start := time.Now()
+	b.waitForWorkloads()
+	require.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	select {
+	case <-workloadDone:
+	default:
+		require.Fail(t, "the workload was abandoned")
+	}
+
+	workerConn := s.connections()[0]
+	var kills []string
+	for _, serverConn := range s.connections()[1:] {
+		kills = append(kills, serverConn.Queries()...)
+	}
+	require.Equal(t, []string{fmt.Sprintf("KILL %d", workerConn.conn.ConnectionID())}, kills)
+}
@@ -0,0 +1,169 @@
+package mybench
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The range and precision of the HDR histograms that track the latency of the
+// events of a workload. Latencies above the range are only counted in the
+// overflow_count column and are excluded from the percentiles, so workloads
+// whose events can take longer than 10s, such as batch jobs or DDL, should
+// raise HighestTrackable.
+//
+// Regardless of the Unit, the data logger converts the histograms to
+// microseconds when merging them, so the logged latencies are always in
+// microseconds. All workloads merged together use the lowest LowestTrackable,
+// the highest HighestTrackable and the most SignificantFigures of the
+// workloads, so no data is lost when merging.
+type HistogramConfig struct {
+	// The unit of LowestTrackable and HighestTrackable, which is also the
+	// resolution the latencies are recorded at. Must be a multiple of a
+	// microsecond. A coarser unit reduces the memory used by the histograms
+	// of the workers for a wide range, but the histograms are converted when
+	// they are merged. Default: time.Microsecond.
+	Unit time.Duration
+
+	// The range of the trackable latencies, in the Unit. HighestTrackable must
+	// be at least twice LowestTrackable. Default: 1 and 10s.
+	LowestTrackable  int64
+	HighestTrackable int64
+
+	// The number of significant decimal digits the latencies are recorded
+	// with, between 1 and 5. Each additional digit uses about 10x the memory.
+	// Default: 4.
+	SignificantFigures int
+}
+
+// 1us - 10s with 4 significant figures.
+var DefaultHistogramConfig = HistogramConfig{
+	Unit:               time.Microsecond,
+	LowestTrackable:    1,
+	HighestTrackable:   10000000,
+	SignificantFigures: 4,
+}
+
+func (c *HistogramConfig) ValidateAndSetDefaults() error {
+	if c.Unit == 0 {
+		c.Unit = time.Microsecond
+	}
+
+	if c.Unit < time.Microsecond || c.Unit%time.Microsecond != 0 {
+		return errors.New("histogram unit must be a multiple of a microsecond, as the latencies are logged in microseconds")
+	}
+
+	if c.LowestTrackable < 0 || c.HighestTrackable < 0 {
+		return errors.New("histogram range cannot be negative")
+	}
+
+	if c.LowestTrackable == 0 {
+		c.LowestTrackable = 1
+	}
+
+	if c.HighestTrackable == 0 {
+		c.HighestTrackable = max(int64(10*time.Second/c.Unit), 2*c.LowestTrackable)
+	}
+
+	if c.HighestTrackable < 2*c.LowestTrackable {
+		return errors.New("histogram HighestTrackable must be at least twice LowestTrackable")
+	}
+
+	if c.SignificantFigures == 0 {
+		c.SignificantFigures = 4
+	}
+
+	if c.SignificantFigures < 1 || c.SignificantFigures > 5 {
+		return errors.New("histogram SignificantFigures must be between 1 and 5")
+	}
+
+	return nil
+}
+
+// Returns the same range with the unit converted to microseconds. If the unit
+// is not a microsecond, the LowestTrackable is 1, as the HDR histogram uses it
+// as its resolution, which would otherwise be rounded down to a power of 2 that
+// does not divide the converted values. The latencies below the range are
+// already counted as underflow by the histograms in the original unit.
+func (c HistogramConfig) inMicroseconds() HistogramConfig {
+	scale := int64(c.Unit / time.Microsecond)
+	if scale == 1 {
+		return c
+	}
+
+	return HistogramConfig{
+		Unit:               time.Microsecond,
+		LowestTrackable:    1,
+		HighestTrackable:   c.HighestTrackable * scale,
+		SignificantFigures: c.SignificantFigures,
+	}
+}
+
+// Returns the range of the uniform histogram of data that is not tied to the
+// VisualizationConfig of a workload, such as the connection setup latency, the
+// run summary, and merged logged intervals. It covers the range of the
+// histogram, so latencies above allWorkloadsVisualizationFloor are not clipped.
+func (c HistogramConfig) visualization() VisualizationConfig {
+	c = c.inMicroseconds()
+	return allWorkloadsVisualizationFloor.union(VisualizationConfig{
+		LatencyHistMin:  c.LowestTrackable,
+		LatencyHistMax:  c.HighestTrackable,
+		LatencyHistSize: allWorkloadsVisualizationFloor.LatencyHistSize,
+	})
+}
+
+// Returns a config that covers the range and precision of both configs, which
+// must be in microseconds.
+func (c HistogramConfig) union(other HistogramConfig) HistogramConfig {
+	return HistogramConfig{
+		Unit:               time.Microsecond,
+		LowestTrackable:    min(c.LowestTrackable, other.LowestTrackable),
+		HighestTrackable:   max(c.HighestTrackable, other.HighestTrackable),
+		SignificantFigures: max(c.SignificantFigures, other.SignificantFigures),
+	}
+}
+
+// Warns when latencies exceed the range of the histograms of a workload, as
+// they are excluded from the percentiles. As this can happen every interval, a
+// warning is only logged for the first overflow of each workload and then each
+// time its total overflow count doubles. Only used by the data logger
+// goroutine.
+type overflowWarner struct {
+	logger logrus.FieldLogger
+
+	// The total overflow count of each workload, and the total at the last
+	// warning.
+	totals   map[string]int64
+	warnedAt map[string]int64
+}
+
+func newOverflowWarner(logger logrus.FieldLogger) *overflowWarner {
+	return &overflowWarner{
+		logger:   logger,
+		totals:   make(map[string]int64),
+		warnedAt: make(map[string]int64),
+	}
+}
+
+// Records the overflow count of an interval, returning true if a warning was
+// logged.
+func (w *overflowWarner) record(workloadName string, overflowCount int64, config HistogramConfig) bool {
+	if overflowCount == 0 {
+		return false
+	}
+
+	w.totals[workloadName] += overflowCount
+	total := w.totals[workloadName]
+	if total < 2*w.warnedAt[workloadName] {
+		return false
+	}
+
+	w.warnedAt[workloadName] = total
+	w.logger.WithFields(logrus.Fields{
+		"workload":          workloadName,
+		"overflow_count":    total,
+		"highest_trackable": time.Duration(config.HighestTrackable) * config.Unit,
+	}).Warn("latencies exceeded the range of the histogram and are excluded from the percentiles, consider raising WorkloadConfig.Histogram.HighestTrackable")
+	return true
+}
@@ -0,0 +1,134 @@
+package mybench
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramConfigValidateAndSetDefaults(t *testing.T) {
+	config := HistogramConfig{}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, DefaultHistogramConfig, config)
+
+	config = HistogramConfig{Unit: time.Millisecond}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, int64(10000), config.HighestTrackable)
+
+	config = HistogramConfig{Unit: time.Hour}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, int64(2), config.HighestTrackable)
+
+	for _, config := range []HistogramConfig{
+		{Unit: time.Nanosecond},
+		{Unit: 1500 * time.Nanosecond},
+		{LowestTrackable: 10, HighestTrackable: 15},
+		{SignificantFigures: 6},
+	} {
+		require.NotNil(t, config.ValidateAndSetDefaults(), "%+v", config)
+	}
+}
+
+func TestExtendedHdrHistogramUnit(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	config := HistogramConfig{Unit: time.Millisecond, HighestTrackable: 3600000}
+	require.Nil(t, config.ValidateAndSetDefaults())
+
+	// 30s is above the default range, but within the range of the config.
+	hist := NewExtendedHdrHistogramWithConfig(startTime, config)
+	hist.RecordDuration(30 * time.Second)
+	hist.RecordDuration(2 * time.Hour)
+
+	data := hist.IntervalData(startTime.Add(time.Second), 1, 300000, 1000, Percentiles{50})
+	require.Equal(t, int64(0), data.UnderflowCount)
+	require.Equal(t, int64(1), data.OverflowCount)
+	require.Equal(t, int64(30000000), data.Max)
+	require.Equal(t, int64(30000000), data.Percentiles[0].Value)
+
+	// Merging converts the values to the unit of the merged histogram.
+	merged := NewExtendedHdrHistogramWithConfig(startTime, config.inMicroseconds())
+	merged.Merge(hist)
+	merged.RecordDuration(5 * time.Millisecond)
+
+	data = merged.IntervalData(startTime.Add(time.Second), 1, 300000, 1000, Percentiles{50, 100})
+	require.Equal(t, int64(1), data.OverflowCount)
+	require.Equal(t, int64(5000), data.Min)
+	require.InDelta(t, 30000000, data.Max, 30000000*1e-4)
+
+	// Values above the range of the merged histogram are counted as overflow.
+	narrow := NewExtendedHdrHistogram(startTime)
+	narrow.Merge(merged)
+	require.Equal(t, int64(2), narrow.overflowCount)
+	require.Equal(t, int64(1), narrow.hist.TotalCount())
+}
+
+func TestHistogramConfigUnion(t *testing.T) {
+	a := HistogramConfig{Unit: time.Millisecond, LowestTrackable: 1, HighestTrackable: 3600000, SignificantFigures: 3}
+	b := DefaultHistogramConfig
+
+	union := a.inMicroseconds().union(b.inMicroseconds())
+	require.Equal(t, HistogramConfig{
+		Unit:               time.Microsecond,
+		LowestTrackable:    1,
+		HighestTrackable:   3600000000,
+		SignificantFigures: 4,
+	}, union)
+}
+
+func TestOverflowWarner(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = io.Discard
+	w := newOverflowWarner(logger)
+	config := DefaultHistogramConfig
+
+	require.False(t, w.record("A", 0, config))
+	require.True(t, w.record("A", 1, config))
+	require.True(t, w.record("A", 1, config))
+	require.False(t, w.record("A", 1, config))
+	require.True(t, w.record("A", 1, config))
+	require.False(t, w.record("A", 3, config))
+	require.True(t, w.record("A", 1, config))
+
+	// Each workload is warned about separately.
+	require.True(t, w.record("B", 5, config))
+}
+
+func TestAllWorkloadsHistogramConfig(t *testing.T) {
+	d := &DataLogger{Benchmark: &Benchmark{workloads: map[string]AbstractWorkload{}}}
+
+	histConfig, visualization := d.allWorkloadsHistogramConfig()
+	require.Equal(t, DefaultHistogramConfig, histConfig)
+	require.Equal(t, allWorkloadsVisualizationFloor, visualization)
+
+	// The default range of the workloads does not narrow the range of all
+	// workloads.
+	d.Benchmark.workloads["A"] = &Workload[struct{}]{WorkloadConfig: WorkloadConfig{
+		Histogram:     DefaultHistogramConfig,
+		Visualization: VisualizationConfig{LatencyHistMin: 0, LatencyHistMax: 50000, LatencyHistSize: 1000},
+	}}
+	_, visualization = d.allWorkloadsHistogramConfig()
+	require.Equal(t, VisualizationConfig{LatencyHistMin: 0, LatencyHistMax: 300000, LatencyHistSize: 1000}, visualization)
+
+	d.Benchmark.workloads["B"] = &Workload[struct{}]{WorkloadConfig: WorkloadConfig{
+		Histogram:     HistogramConfig{Unit: time.Millisecond, LowestTrackable: 1, HighestTrackable: 60000, SignificantFigures: 3},
+		Visualization: VisualizationConfig{LatencyHistMin: 1000, LatencyHistMax: 1000000, LatencyHistSize: 2000},
+	}}
+	histConfig, visualization = d.allWorkloadsHistogramConfig()
+	require.Equal(t, int64(60000000), histConfig.HighestTrackable)
+	require.Equal(t, VisualizationConfig{LatencyHistMin: 0, LatencyHistMax: 1000000, LatencyHistSize: 2000}, visualization)
+}
+
+func TestHistogramConfigVisualization(t *testing.T) {
+	// The default range is wider than the floor.
+	require.Equal(t, VisualizationConfig{LatencyHistMin: 1, LatencyHistMax: 10000000, LatencyHistSize: 1000}, DefaultHistogramConfig.visualization())
+
+	config := HistogramConfig{Unit: time.Millisecond, LowestTrackable: 10, HighestTrackable: 60000, SignificantFigures: 3}
+	require.Equal(t, VisualizationConfig{LatencyHistMin: 1, LatencyHistMax: 60000000, LatencyHistSize: 1000}, config.visualization())
+
+	// Narrow ranges do not narrow the floor.
+	config = HistogramConfig{Unit: time.Microsecond, LowestTrackable: 100, HighestTrackable: 1000, SignificantFigures: 3}
+	require.Equal(t, allWorkloadsVisualizationFloor, config.visualization())
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -17,6 +18,24 @@ type StatusData struct {
 	Note          string
 	Workloads     []string
 	DataSnapshots []*DataSnapshot
+
+	// The latency percentiles recorded in each data snapshot, where 100 is the
+	// maximum latency.
+	Percentiles Percentiles
+
+	RateControlData
+}
+
+// The current rate control parameters, which can be changed while the
+// benchmark is running via the /api/eventrate and /api/workloadscale
+// endpoints.
+type RateControlData struct {
+	EventRate      float64
+	WorkloadScales map[string]float64
+
+	// If true, the benchmark runs in the closed-loop mode and the rate control
+	// parameters cannot be changed.
+	ClosedLoop bool
 }
 
 type HttpServer struct {
@@ -41,13 +60,31 @@ func NewHttpServer(benchmark *Benchmark, note string, port int) *HttpServer {
 
 	s.mux.Handle("/", http.FileServer(http.FS(subFS)))
 	s.mux.HandleFunc("/api/status", s.apiStatus)
+	s.mux.HandleFunc("/api/eventrate", s.apiEventRate)
+	s.mux.HandleFunc("/api/workloadscale", s.apiWorkloadScale)
+	s.mux.HandleFunc("/metrics", s.metrics)
 	return s
 }
 
+func (s *HttpServer) rateControlData() RateControlData {
+	data := RateControlData{
+		EventRate:      s.benchmark.EventRate(),
+		WorkloadScales: make(map[string]float64, len(s.benchmark.workloads)),
+		ClosedLoop:     s.benchmark.BenchmarkConfig.RateControlConfig.ClosedLoop,
+	}
+
+	for workloadName, workload := range s.benchmark.workloads {
+		data.WorkloadScales[workloadName] = workload.WorkloadScale()
+	}
+
+	return data
+}
+
 func (s *HttpServer) apiStatus(w http.ResponseWriter, req *http.Request) {
 	var statusData StatusData
 	statusData.DataSnapshots = s.benchmark.DataSnapshots()
 	statusData.Note = s.note
+	statusData.Percentiles = s.benchmark.dataLogger.Percentiles
 
 	statusData.Workloads = make([]string, 0, len(s.benchmark.workloads))
 	for workloadName := range s.benchmark.workloads {
@@ -55,9 +92,66 @@ func (s *HttpServer) apiStatus(w http.ResponseWriter, req *http.Request) {
 	}
 
 	statusData.CurrentTime = time.Since(s.benchmark.startTime).Seconds()
+	statusData.RateControlData = s.rateControlData()
+
+	writeJSON(w, statusData)
+}
+
+// Sets the total event rate of the benchmark. Expects a POST request with the
+// form value eventrate.
+func (s *HttpServer) apiEventRate(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	eventRate, err := strconv.ParseFloat(req.FormValue("eventrate"), 64)
+	if err == nil {
+		err = s.benchmark.SetEventRate(eventRate)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, s.rateControlData())
+}
+
+// Sets the workload scale of a single workload. Expects a POST request with the
+// form values workload and workloadscale.
+func (s *HttpServer) apiWorkloadScale(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	workloadScale, err := strconv.ParseFloat(req.FormValue("workloadscale"), 64)
+	if err == nil {
+		err = s.benchmark.SetWorkloadScale(req.FormValue("workload"), workloadScale)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, s.rateControlData())
+}
+
+// Serves the metrics in the Prometheus text exposition format, so the
+// benchmark can be scraped alongside the MySQL exporters.
+func (s *HttpServer) metrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := s.benchmark.dataLogger.WriteMetrics(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
 
+func writeJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Add("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(statusData)
+	err := json.NewEncoder(w).Encode(data)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte(err.Error()))
@@ -0,0 +1,85 @@
+package mybench
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+// Logs the rows of the data to a JSON Lines file, with one JSON object per
+// row. The keys of the objects are the columns of the log table preceded by
+// table_name, which identifies the run, and NULL values are written as null.
+// If the file already exists, the rows are appended to it.
+type JSONLinesSink struct {
+	Filename string
+
+	tableName []byte
+	columns   [][]byte
+	file      *os.File
+	writer    *bufio.Writer
+}
+
+func NewJSONLinesSink(filename string) *JSONLinesSink {
+	return &JSONLinesSink{Filename: filename}
+}
+
+func (s *JSONLinesSink) Open(run RunInfo) error {
+	var err error
+	s.tableName, err = json.Marshal(run.TableName)
+	if err != nil {
+		return err
+	}
+
+	// The keys are encoded once, as they are the same for every row.
+	for _, column := range LogColumns(run.Percentiles) {
+		key, err := json.Marshal(column)
+		if err != nil {
+			return err
+		}
+		s.columns = append(s.columns, key)
+	}
+
+	s.file, err = os.OpenFile(s.Filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	s.writer = bufio.NewWriter(s.file)
+	return nil
+}
+
+func (s *JSONLinesSink) Log(dataSnapshot *DataSnapshot) error {
+	// The object is encoded by hand, as encoding a map would sort the keys
+	// instead of keeping the order of the columns.
+	var line bytes.Buffer
+	for _, row := range dataSnapshot.Rows() {
+		line.Reset()
+		line.WriteString(`{"table_name":`)
+		line.Write(s.tableName)
+		for i, value := range row {
+			encodedValue, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+
+			line.WriteByte(',')
+			line.Write(s.columns[i])
+			line.WriteByte(':')
+			line.Write(encodedValue)
+		}
+		line.WriteString("}\n")
+
+		_, err := s.writer.Write(line.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Flushed every interval, so the file can be followed during the run.
+	return s.writer.Flush()
+}
+
+func (s *JSONLinesSink) Close(summary *RunSummary) error {
+	return s.file.Close()
+}
@@ -0,0 +1,271 @@
+package mybench
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type loadProfileSegmentType int
+
+const (
+	loadProfileSegmentRamp loadProfileSegmentType = iota
+	loadProfileSegmentSine
+)
+
+// A single segment of the load profile. Constant segments (const, hold, step)
+// are represented as ramps where the start and end rates are the same.
+type loadProfileSegment struct {
+	segmentType loadProfileSegmentType
+
+	// The offset of the start of the segment from the start of the benchmark.
+	start time.Duration
+
+	// The duration of the segment. Only the last segment can have a duration of
+	// 0, which means the segment lasts until the end of the benchmark.
+	duration time.Duration
+
+	// The rate at the start and end of a ramp segment, or the mean and the
+	// amplitude of a sine segment.
+	fromRate  float64
+	toRate    float64
+	amplitude float64
+	period    time.Duration
+}
+
+func (s loadProfileSegment) rate(offset time.Duration) float64 {
+	// The rate stays at the value at the end of the segment after it ends.
+	if offset < 0 {
+		offset = 0
+	} else if s.duration > 0 && offset > s.duration {
+		offset = s.duration
+	}
+
+	switch s.segmentType {
+	case loadProfileSegmentRamp:
+		if s.duration == 0 {
+			return s.toRate
+		}
+		return s.fromRate + (s.toRate-s.fromRate)*offset.Seconds()/s.duration.Seconds()
+	case loadProfileSegmentSine:
+		return s.fromRate + s.amplitude*math.Sin(2*math.Pi*offset.Seconds()/s.period.Seconds())
+	}
+
+	panic("not implemented")
+}
+
+func (s loadProfileSegment) endRate() float64 {
+	return s.rate(s.duration)
+}
+
+// A time-varying schedule for the event rate of a benchmark. The load profile
+// is specified as a comma separated list of segments which run one after
+// another, starting at the beginning of the benchmark. The following segments
+// are supported (rates are in events per second):
+//
+//   - const:RATE:DURATION: run at RATE for DURATION.
+//   - ramp:FROM:TO:DURATION: linearly change the rate from FROM to TO over
+//     DURATION.
+//   - hold:DURATION: keep the rate at the end of the previous segment for
+//     DURATION.
+//   - step:RATE[:DURATION]: immediately change the rate to RATE and keep it
+//     for DURATION.
+//   - sine:MEAN:AMPLITUDE:PERIOD[:DURATION]: vary the rate following a sine
+//     curve with the given mean, amplitude, and period (such as a diurnal
+//     cycle compressed into PERIOD).
+//
+// The duration of the last segment can be omitted, in which case it lasts
+// until the end of the benchmark. Otherwise, the rate at the end of the last
+// segment is kept until the end of the benchmark. For example, to ramp from 500
+// to 5000 events/s over 10 minutes, hold for 20 minutes, and then step to
+// 8000 events/s:
+//
+//	ramp:500:5000:10m,hold:20m,step:8000
+//
+// The zero value is an empty load profile, which means the event rate does not
+// vary with time. LoadProfile implements flag.Value.
+type LoadProfile struct {
+	spec     string
+	segments []loadProfileSegment
+}
+
+// Parses a load profile. See LoadProfile for the format of the spec.
+func ParseLoadProfile(spec string) (LoadProfile, error) {
+	profile := LoadProfile{spec: spec}
+	if strings.TrimSpace(spec) == "" {
+		return profile, nil
+	}
+
+	var start time.Duration
+	previousRate := math.NaN()
+
+	segmentSpecs := strings.Split(spec, ",")
+	for i, segmentSpec := range segmentSpecs {
+		parts := strings.Split(strings.TrimSpace(segmentSpec), ":")
+		segment, err := parseLoadProfileSegment(parts, previousRate)
+		if err != nil {
+			return LoadProfile{}, fmt.Errorf("invalid load profile segment %q: %w", segmentSpec, err)
+		}
+
+		if segment.duration == 0 && i != len(segmentSpecs)-1 {
+			return LoadProfile{}, fmt.Errorf("invalid load profile segment %q: only the last segment can omit the duration", segmentSpec)
+		}
+
+		segment.start = start
+		start += segment.duration
+		previousRate = segment.endRate()
+		profile.segments = append(profile.segments, segment)
+	}
+
+	return profile, nil
+}
+
+func parseLoadProfileSegment(parts []string, previousRate float64) (loadProfileSegment, error) {
+	var err error
+	segment := loadProfileSegment{segmentType: loadProfileSegmentRamp}
+	args := parts[1:]
+
+	switch parts[0] {
+	case "const", "step":
+		if len(args) < 1 || len(args) > 2 || (parts[0] == "const" && len(args) != 2) {
+			return segment, fmt.Errorf("expected %s:RATE:DURATION", parts[0])
+		}
+		segment.fromRate, err = parseLoadProfileRate(args[0])
+		segment.toRate = segment.fromRate
+		if err == nil && len(args) == 2 {
+			segment.duration, err = time.ParseDuration(args[1])
+		}
+	case "ramp":
+		if len(args) != 3 {
+			return segment, fmt.Errorf("expected ramp:FROM:TO:DURATION")
+		}
+		segment.fromRate, err = parseLoadProfileRate(args[0])
+		if err == nil {
+			segment.toRate, err = parseLoadProfileRate(args[1])
+		}
+		if err == nil {
+			segment.duration, err = time.ParseDuration(args[2])
+		}
+	case "hold":
+		if len(args) != 1 {
+			return segment, fmt.Errorf("expected hold:DURATION")
+		}
+		if math.IsNaN(previousRate) {
+			return segment, fmt.Errorf("hold cannot be the first segment")
+		}
+		segment.fromRate = previousRate
+		segment.toRate = previousRate
+		segment.duration, err = time.ParseDuration(args[0])
+	case "sine":
+		if len(args) < 3 || len(args) > 4 {
+			return segment, fmt.Errorf("expected sine:MEAN:AMPLITUDE:PERIOD[:DURATION]")
+		}
+		segment.segmentType = loadProfileSegmentSine
+		segment.fromRate, err = parseLoadProfileRate(args[0])
+		if err == nil {
+			segment.amplitude, err = parseLoadProfileRate(args[1])
+		}
+		if err == nil && segment.amplitude > segment.fromRate {
+			err = fmt.Errorf("amplitude cannot be larger than the mean")
+		}
+		if err == nil {
+			segment.period, err = time.ParseDuration(args[2])
+		}
+		if err == nil && segment.period <= 0 {
+			err = fmt.Errorf("period must be positive")
+		}
+		if err == nil && len(args) == 4 {
+			segment.duration, err = time.ParseDuration(args[3])
+		}
+	default:
+		return segment, fmt.Errorf("unknown segment type %s", parts[0])
+	}
+
+	if err == nil && segment.duration < 0 {
+		err = fmt.Errorf("duration cannot be negative")
+	}
+
+	return segment, err
+}
+
+func parseLoadProfileRate(s string) (float64, error) {
+	rate, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return 0, fmt.Errorf("invalid rate %s", s)
+	}
+
+	return rate, nil
+}
+
+// Returns true if the load profile has at least one segment.
+func (p LoadProfile) Enabled() bool {
+	return len(p.segments) > 0
+}
+
+// Returns the scheduled event rate at the given time since the start of the
+// benchmark. Must only be called if the load profile is enabled.
+func (p LoadProfile) Rate(elapsed time.Duration) float64 {
+	for i := len(p.segments) - 1; i >= 0; i-- {
+		segment := p.segments[i]
+		if elapsed >= segment.start || i == 0 {
+			return segment.rate(elapsed - segment.start)
+		}
+	}
+
+	panic("load profile is empty")
+}
+
+// Returns the maximum rate scheduled by the load profile. This is used to
+// calculate the number of workers needed to drive the peak of the profile.
+func (p LoadProfile) MaxRate() float64 {
+	maxRate := 0.0
+	for _, segment := range p.segments {
+		switch segment.segmentType {
+		case loadProfileSegmentRamp:
+			maxRate = math.Max(maxRate, math.Max(segment.fromRate, segment.toRate))
+		case loadProfileSegmentSine:
+			maxRate = math.Max(maxRate, segment.fromRate+segment.amplitude)
+		}
+	}
+
+	return maxRate
+}
+
+// Returns a copy of the load profile with all rates multiplied by the factor.
+// This is used to derive the load profile of each workload based on its
+// WorkloadScale.
+func (p LoadProfile) Scale(factor float64) LoadProfile {
+	scaled := LoadProfile{
+		spec:     p.spec,
+		segments: make([]loadProfileSegment, len(p.segments)),
+	}
+
+	for i, segment := range p.segments {
+		segment.fromRate *= factor
+		segment.toRate *= factor
+		segment.amplitude *= factor
+		scaled.segments[i] = segment
+	}
+
+	return scaled
+}
+
+func (p *LoadProfile) String() string {
+	return p.spec
+}
+
+func (p *LoadProfile) Set(spec string) error {
+	profile, err := ParseLoadProfile(spec)
+	if err != nil {
+		return err
+	}
+
+	*p = profile
+	return nil
+}
@@ -0,0 +1,118 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfileRampHoldStep(t *testing.T) {
+	profile, err := ParseLoadProfile("ramp:500:5000:10m,hold:20m,step:8000")
+	require.Nil(t, err)
+	require.True(t, profile.Enabled())
+
+	require.InDelta(t, 500.0, profile.Rate(0), 1e-9)
+	require.InDelta(t, 2750.0, profile.Rate(5*time.Minute), 1e-9)
+	require.InDelta(t, 5000.0, profile.Rate(10*time.Minute), 1e-9)
+	require.InDelta(t, 5000.0, profile.Rate(29*time.Minute), 1e-9)
+	require.InDelta(t, 8000.0, profile.Rate(30*time.Minute), 1e-9)
+	require.InDelta(t, 8000.0, profile.Rate(5*time.Hour), 1e-9)
+	require.InDelta(t, 8000.0, profile.MaxRate(), 1e-9)
+}
+
+func TestLoadProfileKeepsLastRate(t *testing.T) {
+	profile, err := ParseLoadProfile("const:100:1m,ramp:100:0:1m")
+	require.Nil(t, err)
+
+	require.InDelta(t, 100.0, profile.Rate(30*time.Second), 1e-9)
+	require.InDelta(t, 50.0, profile.Rate(90*time.Second), 1e-9)
+	require.InDelta(t, 0.0, profile.Rate(10*time.Minute), 1e-9)
+}
+
+func TestLoadProfileSine(t *testing.T) {
+	profile, err := ParseLoadProfile("sine:1000:500:4h:6h")
+	require.Nil(t, err)
+
+	require.InDelta(t, 1000.0, profile.Rate(0), 1e-6)
+	require.InDelta(t, 1500.0, profile.Rate(1*time.Hour), 1e-6)
+	require.InDelta(t, 500.0, profile.Rate(3*time.Hour), 1e-6)
+	require.InDelta(t, 1000.0, profile.Rate(7*time.Hour), 1e-6) // value at the end of the segment
+	require.InDelta(t, 1500.0, profile.MaxRate(), 1e-9)
+}
+
+func TestLoadProfileScale(t *testing.T) {
+	profile, err := ParseLoadProfile("ramp:100:200:1m,sine:200:100:1m")
+	require.Nil(t, err)
+
+	scaled := profile.Scale(0.5)
+	require.InDelta(t, 75.0, scaled.Rate(30*time.Second), 1e-9)
+	require.InDelta(t, 150.0, scaled.Rate(75*time.Second), 1e-6)
+	require.InDelta(t, 150.0, scaled.MaxRate(), 1e-9)
+
+	// The original profile must not be modified.
+	require.InDelta(t, 150.0, profile.Rate(30*time.Second), 1e-9)
+}
+
+func TestLoadProfileEmpty(t *testing.T) {
+	profile, err := ParseLoadProfile("")
+	require.Nil(t, err)
+	require.False(t, profile.Enabled())
+
+	config := RateControlConfig{EventRate: 1000, LoadProfile: profile}
+	require.Equal(t, 1000.0, config.EventRateAt(time.Hour))
+	require.Equal(t, 1000.0, config.PeakEventRate())
+}
+
+func TestLoadProfileInvalid(t *testing.T) {
+	invalidSpecs := []string{
+		"hold:10m",
+		"step:100,hold:10m",
+		"ramp:100:200",
+		"const:100",
+		"const:-1:1m",
+		"sine:100:200:1m",
+		"sine:100:50:0s",
+		"unknown:100",
+		"ramp:a:b:1m",
+		"const:100:1x",
+	}
+
+	for _, spec := range invalidSpecs {
+		_, err := ParseLoadProfile(spec)
+		require.NotNil(t, err, spec)
+	}
+}
+
+func TestLoadProfileFlagValue(t *testing.T) {
+	var profile LoadProfile
+	require.Nil(t, profile.Set("step:100"))
+	require.Equal(t, "step:100", profile.String())
+	require.InDelta(t, 100.0, profile.Rate(time.Minute), 1e-9)
+}
+
+func TestLoadProfileConfigValidation(t *testing.T) {
+	newConfig := func() BenchmarkConfig {
+		return BenchmarkConfig{
+			Bench:          true,
+			LogFile:        "data.sqlite",
+			DatabaseConfig: DatabaseConfig{Host: "localhost", ConnectionMultiplier: 1},
+		}
+	}
+
+	config := newConfig()
+	require.Nil(t, config.RateControlConfig.LoadProfile.Set("step:0"))
+	require.ErrorContains(t, config.ValidateAndSetDefaults(), "the load profile must have a non-zero rate")
+
+	// Without a load profile, the error is about the event rate.
+	config = newConfig()
+	config.RateControlConfig.EventRate = -1
+	err := config.ValidateAndSetDefaults()
+	require.ErrorContains(t, err, "-eventrate")
+	require.NotContains(t, err.Error(), "load profile")
+
+	config = newConfig()
+	require.Nil(t, config.RateControlConfig.LoadProfile.Set("ramp:0:500:1m"))
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.Equal(t, 5, config.RateControlConfig.Concurrency)
+}
@@ -0,0 +1,206 @@
+package mybench
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Selects the intervals in the log table of a run to merge with
+// MergeLoggedIntervals. Fields that are not set match all intervals.
+type IntervalSelector struct {
+	// The workload column of the intervals, such as the name of a workload or
+	// __all__ for all workloads merged together. Default: __all__.
+	Workload string
+
+	// The host column, which is only set for the workload __host__.
+	Host string
+
+	// The segment and phase columns, such as PhaseMeasurement to exclude the
+	// warmup.
+	Segment string
+	Phase   Phase
+
+	// Only the intervals logged between From and To since the start of the run
+	// are merged. If To is 0, the intervals until the end of the run are merged.
+	From time.Duration
+	To   time.Duration
+
+	// If true, the histograms of the response time are merged instead of the
+	// histograms of the service time. See EventStat.ResponseTime. The underflow
+	// and overflow counts are only logged for the service time, so they are 0
+	// in the merged data.
+	ResponseTime bool
+}
+
+// Merges the HDR histograms and the counts of the selected intervals of a run
+// logged by the DataLogger. Unlike averaging the percentiles of the intervals,
+// the percentiles of the merged data are exact, as they are calculated from
+// the merged histogram. The table is the name of the log table of the run,
+// which is listed in the meta table.
+//
+// The start and end times of the merged data are the start of the first and
+// the end of the last interval, which are logged with a precision of a second.
+func MergeLoggedIntervals(db *sql.DB, table string, selector IntervalSelector, percentiles Percentiles) (IntervalData, error) {
+	err := percentiles.ValidateAndSetDefaults()
+	if err != nil {
+		return IntervalData{}, err
+	}
+
+	if selector.Workload == "" {
+		selector.Workload = "__all__"
+	}
+
+	conditions := []string{"workload = ?", "seconds_since_start >= ?"}
+	args := []interface{}{selector.Workload, selector.From.Seconds()}
+	if selector.To > 0 {
+		conditions = append(conditions, "seconds_since_start <= ?")
+		args = append(args, selector.To.Seconds())
+	}
+
+	if selector.Host != "" {
+		conditions = append(conditions, "host = ?")
+		args = append(args, selector.Host)
+	}
+
+	if selector.Segment != "" {
+		conditions = append(conditions, "segment = ?")
+		args = append(args, selector.Segment)
+	}
+
+	if selector.Phase != "" {
+		conditions = append(conditions, "phase = ?")
+		args = append(args, string(selector.Phase))
+	}
+
+	histogramColumn := "hdr_histogram"
+	if selector.ResponseTime {
+		histogramColumn = "response_time_hdr_histogram"
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+SELECT
+	interval_start,
+	interval_end,
+	underflow_count,
+	overflow_count,
+	deadlock_count,
+	lock_wait_timeout_count,
+	lost_connection_count,
+	query_timeout_count,
+	other_error_count,
+	retry_count,
+	%s
+FROM %s WHERE %s ORDER BY id`, histogramColumn, table, strings.Join(conditions, " AND ")), args...)
+	if err != nil {
+		return IntervalData{}, err
+	}
+	defer rows.Close()
+
+	// The intervals are decoded first, as the merged histogram must cover the
+	// ranges of all of them.
+	var intervals []*ExtendedHdrHistogram
+	var endTime time.Time
+	for rows.Next() {
+		var intervalStart, intervalEnd string
+		var errorCounts ErrorCounts
+		var underflowCount, overflowCount, retryCount int64
+		var encodedHist sql.NullString
+		err = rows.Scan(
+			&intervalStart,
+			&intervalEnd,
+			&underflowCount,
+			&overflowCount,
+			&errorCounts.Deadlock,
+			&errorCounts.LockWaitTimeout,
+			&errorCounts.LostConnection,
+			&errorCounts.QueryTimeout,
+			&errorCounts.Other,
+			&retryCount,
+			&encodedHist,
+		)
+		if err != nil {
+			return IntervalData{}, err
+		}
+
+		if !encodedHist.Valid {
+			return IntervalData{}, fmt.Errorf("interval ending at %s was logged without a histogram", intervalEnd)
+		}
+
+		startTime, err := time.Parse(time.RFC3339, intervalStart)
+		if err != nil {
+			return IntervalData{}, err
+		}
+
+		endTime, err = time.Parse(time.RFC3339, intervalEnd)
+		if err != nil {
+			return IntervalData{}, err
+		}
+
+		interval, err := decodeLoggedHistogram(encodedHist.String, startTime)
+		if err != nil {
+			return IntervalData{}, fmt.Errorf("failed to decode the histogram of the interval ending at %s: %w", intervalEnd, err)
+		}
+
+		interval.errorCounts = errorCounts
+		interval.retryCount = retryCount
+		if !selector.ResponseTime {
+			interval.underflowCount = underflowCount
+			interval.overflowCount = overflowCount
+		}
+		intervals = append(intervals, interval)
+	}
+
+	if err = rows.Err(); err != nil {
+		return IntervalData{}, err
+	}
+
+	if len(intervals) == 0 {
+		return IntervalData{}, errors.New("no intervals match the selector")
+	}
+
+	merged := mergeIntervalHistograms(intervals)
+	visualization := merged.config.visualization()
+	return merged.IntervalData(endTime, visualization.LatencyHistMin, visualization.LatencyHistMax, visualization.LatencyHistSize, percentiles), nil
+}
+
+// Decodes a histogram logged by the data logger, which is in microseconds. Only
+// the latency data is set, as the counts are logged in separate columns.
+func decodeLoggedHistogram(encoded string, startTime time.Time) (*ExtendedHdrHistogram, error) {
+	hist, err := hdrhistogram.Decode([]byte(encoded))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendedHdrHistogram{
+		hist: hist,
+		config: HistogramConfig{
+			Unit:               time.Microsecond,
+			LowestTrackable:    hist.LowestTrackableValue(),
+			HighestTrackable:   hist.HighestTrackableValue(),
+			SignificantFigures: int(hist.SignificantFigures()),
+		},
+		startTime: startTime,
+	}, nil
+}
+
+// Merges the histograms of consecutive intervals, which must not be empty,
+// into a histogram that covers the ranges of all of them, as the ranges can
+// differ between the workloads. See HistogramConfig.
+func mergeIntervalHistograms(intervals []*ExtendedHdrHistogram) *ExtendedHdrHistogram {
+	config := intervals[0].config
+	for _, interval := range intervals[1:] {
+		config = config.union(interval.config)
+	}
+
+	merged := NewExtendedHdrHistogramWithConfig(intervals[0].startTime, config)
+	for _, interval := range intervals {
+		merged.mergeData(interval)
+	}
+
+	return merged
+}
@@ -0,0 +1,122 @@
+package mybench
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newDataLoggerForTest(t *testing.T) (*DataLogger, *sql.DB) {
+	dataLogger, err := NewDataLogger(&DataLogger{
+		OutputFilename: filepath.Join(t.TempDir(), "data.sqlite"),
+		TableName:      "T",
+		Benchmark:      &Benchmark{Name: "test"},
+		Percentiles:    Percentiles{50, 99},
+	})
+	require.Nil(t, err)
+
+	dataLogger.startTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dataLogger.dataRing = NewRing[*DataSnapshot](10)
+	require.Nil(t, dataLogger.openSinks())
+
+	db := dataLogger.Sinks[0].(*SQLiteSink).db
+	t.Cleanup(func() { db.Close() })
+
+	return dataLogger, db
+}
+
+func logIntervalForTest(d *DataLogger, second int, phase Phase, latency int64, count int) {
+	startTime := d.startTime.Add(time.Duration(second-1) * time.Second)
+	hist := NewExtendedHdrHistogram(startTime)
+	for i := 0; i < count; i++ {
+		hist.RecordValue(latency)
+	}
+	hist.RecordError(ErrorClassDeadlock)
+	hist.RecordValue(0) // underflow
+
+	// The events are delayed by their own latency.
+	responseHist := NewExtendedHdrHistogram(startTime)
+	for i := 0; i < count; i++ {
+		responseHist.RecordValue(2 * latency)
+	}
+
+	d.logData(&DataSnapshot{
+		Time:  float64(second),
+		Phase: phase,
+		AllWorkloadData: WorkloadDataSnapshot{
+			IntervalData:        hist.IntervalData(startTime.Add(time.Second), 1, 300000, 1000, d.Percentiles),
+			ResponseTime:        d.responseTimeData(responseHist, startTime.Add(time.Second), allWorkloadsVisualizationFloor),
+			encodedHist:         d.encodeHistogram(hist),
+			encodedResponseHist: d.encodeHistogram(responseHist),
+		},
+	})
+}
+
+func TestMergeLoggedIntervals(t *testing.T) {
+	d, db := newDataLoggerForTest(t)
+	logIntervalForTest(d, 1, PhaseWarmup, 100000, 100)
+	logIntervalForTest(d, 2, PhaseMeasurement, 1000, 980)
+	logIntervalForTest(d, 3, PhaseMeasurement, 50000, 20)
+
+	// The p99 of the intervals are 1ms and 50ms, while 2% of the events of
+	// both intervals took 50ms.
+	data, err := MergeLoggedIntervals(db, "T", IntervalSelector{Phase: PhaseMeasurement}, Percentiles{50, 99})
+	require.Nil(t, err)
+	require.Equal(t, int64(1002), data.Count)
+	require.Equal(t, int64(2), data.UnderflowCount)
+	require.Equal(t, int64(2), data.Errors.Deadlock)
+	require.Equal(t, d.startTime.Add(time.Second), data.StartTime)
+	require.Equal(t, d.startTime.Add(3*time.Second), data.EndTime)
+
+	p50, _ := data.Percentile(50)
+	require.InDelta(t, 1000, p50, 1)
+	p99, _ := data.Percentile(99)
+	require.InDelta(t, 50000, p99, 10)
+
+	// The uniform histogram covers the range of the logged histograms.
+	require.Equal(t, int64(10000000), data.UniformHist.histMax)
+
+	// Select by time instead of phase.
+	data, err = MergeLoggedIntervals(db, "T", IntervalSelector{From: 2 * time.Second, To: 2 * time.Second}, nil)
+	require.Nil(t, err)
+	require.Equal(t, int64(981), data.Count)
+	require.Equal(t, int64(1000), data.Max)
+
+	data, err = MergeLoggedIntervals(db, "T", IntervalSelector{Phase: PhaseMeasurement, ResponseTime: true}, nil)
+	require.Nil(t, err)
+	require.Equal(t, int64(1000), data.Count)
+	require.InDelta(t, 100000, data.Max, 10)
+
+	_, err = MergeLoggedIntervals(db, "T", IntervalSelector{Workload: "missing"}, nil)
+	require.ErrorContains(t, err, "no intervals")
+}
+
+func TestSQLiteSinkColumns(t *testing.T) {
+	d, db := newDataLoggerForTest(t)
+
+	rows, err := db.Query("SELECT * FROM T")
+	require.Nil(t, err)
+	defer rows.Close()
+
+	// Every column but the id is written from the rows of the data snapshots.
+	columns, err := rows.Columns()
+	require.Nil(t, err)
+	require.Equal(t, append([]string{"id"}, LogColumns(d.Percentiles)...), columns)
+}
+
+func TestSQLiteSinkClosedOnOpenError(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data.sqlite")
+	run := RunInfo{TableName: "T", StartTime: time.Now(), Percentiles: Percentiles{50}}
+
+	sink := NewSQLiteSink(filename)
+	require.Nil(t, sink.Open(run))
+	require.Nil(t, sink.Close(nil))
+
+	// The table of the run already exists.
+	sink = NewSQLiteSink(filename)
+	require.ErrorContains(t, sink.Open(run), "already exists")
+	require.Nil(t, sink.db)
+}
@@ -3,8 +3,6 @@ package mybench
 import (
 	"context"
 	"fmt"
-	"math"
-	"math/rand"
 	"runtime/trace"
 	"time"
 )
@@ -36,36 +34,85 @@ type OuterLoopStat struct {
 
 	// The cumulative number of events executed.
 	CumulativeNumberOfEvents int64
+
+	// The event rate of the outer loop iteration. See
+	// DiscretizedLooper.EventRateFunc.
+	EventRate float64
+
+	// True if the looper is so far behind that it runs the events one at a time
+	// without sleeping, in an attempt to catch up with the desired schedule.
+	CatchingUp bool
 }
 
 type EventStat struct {
+	// The service time of the event, from its actual start to its end.
 	TimeTaken time.Duration
+
+	// The response time of the event, from the time it should have started
+	// according to the arrival process to its end. Unlike the TimeTaken, this
+	// includes the time the event was delayed because the looper fell behind,
+	// which avoids coordinated omission. This is never less than the
+	// TimeTaken, as events in a batch may start before their intended start
+	// time. It is equal to the TimeTaken in the closed-loop mode, where events
+	// have no intended start time.
+	ResponseTime time.Duration
 }
 
+// The type of the arrival process used by the DiscretizedLooper. See
+// ArrivalConfig for details.
 type LooperType int
 
-const (
-	LooperTypeUniform LooperType = iota
-	LooperTypePoisson
-)
-
 type DiscretizedLooper struct {
 	EventRate       float64
 	OuterLoopRate   float64
 	LooperType      LooperType
 	DebugIdentifier string
 
+	// If set, this is called at the beginning of every outer loop iteration to
+	// get the event rate, which allows the event rate to vary over time. If not
+	// set, EventRate is used.
+	EventRateFunc func(now time.Time) float64
+
+	// If set, this generates the time between events. If not set, an arrival
+	// process is created based on LooperType with the default parameters.
+	ArrivalProcess ArrivalProcess
+
 	// The context passed is a trace context from runtime/trace package
 	Event          func(context.Context) error
 	TraceEvent     func(EventStat)
 	TraceOuterLoop func(OuterLoopStat)
 
-	startTime time.Time
+	// If set, this is called when Event returns an error. The looper keeps
+	// going if it returns nil and stops with the returned error otherwise. If
+	// not set, the looper stops on the first error. TraceEvent is not called
+	// for events that return an error.
+	HandleEventError func(context.Context, error) error
+
+	// If set, this is called with the number of events skipped after
+	// SkipEventsUntil is called, so they can be counted as failed.
+	TraceSkippedEvents func(n int64)
+
+	startTime        time.Time
+	skipUntil        time.Time
+	currentEventRate float64
+
+	// The intended start times of the events in the current batch, which is
+	// reused to avoid allocations.
+	intendedStartTimes []time.Time
 }
 
 func (l *DiscretizedLooper) Run(ctx context.Context) error {
 	l.startTime = time.Now()
 
+	if l.ArrivalProcess == nil {
+		arrivalConfig := ArrivalConfig{LooperType: l.LooperType}
+		err := arrivalConfig.ValidateAndSetDefaults()
+		if err != nil {
+			return err
+		}
+		l.ArrivalProcess = arrivalConfig.NewArrivalProcess()
+	}
+
 	nextExpectedEventTime := l.startTime
 	nextWakeupTime := l.startTime
 	eventBatchSize := int64(0)
@@ -90,6 +137,12 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 			lastWakeupTime := nextWakeupTime
 			actualWakeupTime := time.Now()
 
+			l.currentEventRate = l.EventRate
+			catchingUp := false
+			if l.EventRateFunc != nil {
+				l.currentEventRate = l.EventRateFunc(actualWakeupTime)
+			}
+
 			// Calculate the event batch size for this period
 			// ==============================================
 			//
@@ -113,7 +166,14 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 			//       to fall further behind, the next iterations will eventually lead to
 			//       case 1.
 			nextWakeupTime = nextWakeupTime.Add(time.Duration(1.0 / l.OuterLoopRate * 1000000000))
-			if actualWakeupTime.Sub(nextWakeupTime) >= 0 {
+			if l.currentEventRate <= 0 {
+				// No events should run at all at the moment (for example, a load profile
+				// may step the rate down to 0). The next event is expected no earlier
+				// than the next period, as the inter-arrival duration is undefined.
+				eventBatchSize = 0
+				nextExpectedEventTime = nextWakeupTime
+				l.intendedStartTimes = l.intendedStartTimes[:0]
+			} else if actualWakeupTime.Sub(nextWakeupTime) >= 0 {
 				// This case is when the looper is really behind, as the next wake up time
 				// is behind the current actual wake up time.. so we just execute as
 				// fast as possible until we catch up (which might be never). The next
@@ -121,18 +181,23 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 				// past, which means no sleeping (unless the Poisson distribution sample
 				// something significantly in the future, which then it sleeps)
 				eventBatchSize = 1
+				catchingUp = true
 				nextWakeupTime = nextExpectedEventTime
+				l.intendedStartTimes = append(l.intendedStartTimes[:0], nextExpectedEventTime)
 				nextExpectedEventTime = nextExpectedEventTime.Add(l.interArrivalDuration())
 			} else if nextExpectedEventTime.Sub(nextWakeupTime) >= 0 {
 				// In this case, the next expected event time is in the next period, so we
 				// just skip this period without executing any events.
 				eventBatchSize = 0
+				l.intendedStartTimes = l.intendedStartTimes[:0]
 			} else {
 				// nextExpectedEventTime - lastWakeupTime - actualWakeupTime - nextWakeupTime
 				// lastWakeupTime - nextExpectedEventTime - actualWakeupTime - nextWakeupTime
 				// lastWakeupTime - actualWakeupTime - nextExpectedEventTime - nextWakeupTime
 				eventBatchSize = 0 // TODO: Should this be 0 or 1? Is there
+				l.intendedStartTimes = l.intendedStartTimes[:0]
 				for nextExpectedEventTime.Sub(nextWakeupTime) < 0 {
+					l.intendedStartTimes = append(l.intendedStartTimes, nextExpectedEventTime)
 					nextExpectedEventTime = nextExpectedEventTime.Add(l.interArrivalDuration())
 					eventBatchSize++
 				}
@@ -152,7 +217,20 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 			// iteration. Since there's no sleep in an Event, then the desired rate
 			// higher than 100Hz can be achieved. Effectively, this discretizes time
 			// into windows due to the limitations imposed by Golang and Linux.
+			skippedEvents := int64(0)
 			for i := int64(0); i < eventBatchSize; i++ {
+				// A large batch can take a long time if the events are slow, so the
+				// looper stops between the events once the benchmark is stopped.
+				if ctx.Err() != nil {
+					eventBatchSize = i
+					break
+				}
+
+				if l.intendedStartTimes[i].Before(l.skipUntil) {
+					skippedEvents++
+					continue
+				}
+
 				var eventStart time.Time
 				if l.TraceEvent != nil {
 					eventStart = time.Now()
@@ -160,19 +238,48 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 
 				region := trace.StartRegion(traceTaskCtx, eventRegionName)
 				err := l.Event(traceTaskCtx)
+				region.End()
 				if err != nil {
-					region.End()
-					return err
+					err = handleEventError(ctx, l.HandleEventError, err)
+					if err != nil {
+						return err
+					}
+					continue
 				}
-				region.End()
 
 				if l.TraceEvent != nil {
-					l.TraceEvent(EventStat{
-						TimeTaken: time.Since(eventStart),
-					})
+					eventEnd := time.Now()
+					stat := EventStat{
+						TimeTaken:    eventEnd.Sub(eventStart),
+						ResponseTime: eventEnd.Sub(l.intendedStartTimes[i]),
+					}
+					if stat.ResponseTime < stat.TimeTaken {
+						stat.ResponseTime = stat.TimeTaken
+					}
+					l.TraceEvent(stat)
+				}
+			}
+			executedNumberOfEvents += eventBatchSize - skippedEvents
+
+			if !l.skipUntil.IsZero() {
+				// The events of the later batches are skipped by moving the schedule
+				// past the skipped period, which also keeps the looper from catching
+				// up on the period it was blocked for.
+				for l.currentEventRate > 0 && nextExpectedEventTime.Before(l.skipUntil) {
+					skippedEvents++
+					nextExpectedEventTime = nextExpectedEventTime.Add(l.interArrivalDuration())
 				}
+
+				if nextWakeupTime.Before(l.skipUntil) {
+					nextWakeupTime = l.skipUntil
+				}
+
+				l.skipUntil = time.Time{}
+			}
+
+			if skippedEvents > 0 && l.TraceSkippedEvents != nil {
+				l.TraceSkippedEvents(skippedEvents)
 			}
-			executedNumberOfEvents += eventBatchSize
 			eventsEnd := time.Now()
 
 			if l.TraceOuterLoop != nil {
@@ -185,10 +292,16 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 					NextDesiredWakeupTime:    nextWakeupTime,
 					NextExpectedEventTime:    nextExpectedEventTime,
 					CumulativeNumberOfEvents: executedNumberOfEvents,
+					EventRate:                l.currentEventRate,
+					CatchingUp:               catchingUp,
 				}
 				l.TraceOuterLoop(outerLoopStat)
 			}
 
+			if ctx.Err() != nil {
+				return nil
+			}
+
 			region := trace.StartRegion(traceTaskCtx, "Sleep")
 			now := time.Now()
 			delta := nextWakeupTime.Sub(now)
@@ -206,21 +319,23 @@ func (l *DiscretizedLooper) Run(ctx context.Context) error {
 	}
 }
 
-func (l *DiscretizedLooper) interArrivalDuration() time.Duration {
-	switch l.LooperType {
-	case LooperTypeUniform:
-		return l.interArrivalDurationUniform()
-	case LooperTypePoisson:
-		return l.interArrivalDurationPoisson()
-	}
-
-	panic("not implemented")
+// Skips the events whose intended start time is before t instead of running
+// them late to catch up with the schedule. This is meant to be called by
+// HandleEventError after an outage during which the events could not have run,
+// such as while reconnecting after the connection was lost, as the backlog
+// would otherwise run in a burst afterwards without being counted as failed.
+func (l *DiscretizedLooper) SkipEventsUntil(t time.Time) {
+	l.skipUntil = t
 }
 
-func (l *DiscretizedLooper) interArrivalDurationUniform() time.Duration {
-	return time.Duration(1.0 / l.EventRate * 1000000000)
+func handleEventError(ctx context.Context, handler func(context.Context, error) error, err error) error {
+	if handler == nil {
+		return err
+	}
+
+	return handler(ctx, err)
 }
 
-func (l *DiscretizedLooper) interArrivalDurationPoisson() time.Duration {
-	return time.Duration(-math.Log(1-rand.Float64()) / l.EventRate * 1000000000)
+func (l *DiscretizedLooper) interArrivalDuration() time.Duration {
+	return l.ArrivalProcess.InterArrivalDuration(l.currentEventRate)
 }
@@ -0,0 +1,111 @@
+package mybench
+
+import (
+	"time"
+)
+
+// The lag of the loopers of a workload behind the desired schedule during an
+// interval, which shows if a latency spike is caused by the database or by
+// the benchmark itself falling behind. The lag is collected from the
+// OuterLoopStat of each outer loop iteration of the DiscretizedLooper, so it
+// is not recorded in the closed-loop mode, which has no schedule.
+type LooperLagData struct {
+	// The number of outer loop iterations in the interval.
+	Iterations int64
+
+	// The time the outer loop iterations woke up after their desired wakeup
+	// time, in microseconds. The lateness grows quickly once the loopers are
+	// catching up, as they no longer sleep between the events.
+	WakeupLatenessMean float64
+	WakeupLatenessMax  int64
+
+	// The number of events that should have started by the end of an outer
+	// loop iteration but did not, summed over the workers. The largest backlog
+	// of each worker in the interval is used.
+	Backlog int64
+
+	// The share of the workers that were catching up at some point in the
+	// interval, between 0 and 1. See OuterLoopStat.CatchingUp. Workers that
+	// did not finish an outer loop iteration in the interval, as their events
+	// are slower than the interval, are counted as catching up.
+	CatchingUpShare float64
+}
+
+// The lag of a single worker, which is recorded for every outer loop
+// iteration and reset after every interval by the data logger.
+type looperLag struct {
+	iterations           int64
+	catchingUpIterations int64
+	wakeupLatenessSum    time.Duration
+	wakeupLatenessMax    time.Duration
+	backlogMax           int64
+}
+
+func (l *looperLag) record(stat OuterLoopStat) {
+	l.iterations++
+	if stat.CatchingUp {
+		l.catchingUpIterations++
+	}
+
+	lateness := max(stat.ActualWakeupTime.Sub(stat.DesiredWakeupTime), 0)
+	l.wakeupLatenessSum += lateness
+	l.wakeupLatenessMax = max(l.wakeupLatenessMax, lateness)
+
+	// The events due by the end of the iteration are the next expected event
+	// and the ones that follow it before the end.
+	behind := stat.EventsEnd.Sub(stat.NextExpectedEventTime)
+	if behind >= 0 && stat.EventRate > 0 {
+		l.backlogMax = max(l.backlogMax, int64(behind.Seconds()*stat.EventRate)+1)
+	}
+}
+
+func (l *looperLag) reset() {
+	*l = looperLag{}
+}
+
+// Records the looper lag of a worker with the LockedDoubleBuffer, the same
+// way as the OnlineHistogram.
+type OnlineLooperLag struct {
+	*LockedDoubleBuffer[*looperLag]
+}
+
+func NewOnlineLooperLag() *OnlineLooperLag {
+	return &OnlineLooperLag{
+		LockedDoubleBuffer: NewLockedDoubleBuffer(func() *looperLag {
+			return &looperLag{}
+		}),
+	}
+}
+
+func (l *OnlineLooperLag) Record(stat OuterLoopStat) {
+	l.SafeActiveWrite(func(lag *looperLag) {
+		lag.record(stat)
+	})
+}
+
+// Merges the lag of the workers. Returns nil if there are no workers.
+func mergeLooperLags(lags []*looperLag) *LooperLagData {
+	if len(lags) == 0 {
+		return nil
+	}
+
+	data := &LooperLagData{}
+	var wakeupLatenessSum time.Duration
+	catchingUpWorkers := 0
+	for _, lag := range lags {
+		data.Iterations += lag.iterations
+		wakeupLatenessSum += lag.wakeupLatenessSum
+		data.WakeupLatenessMax = max(data.WakeupLatenessMax, lag.wakeupLatenessMax.Microseconds())
+		data.Backlog += lag.backlogMax
+		if lag.catchingUpIterations > 0 || lag.iterations == 0 {
+			catchingUpWorkers++
+		}
+	}
+
+	if data.Iterations > 0 {
+		data.WakeupLatenessMean = float64(wakeupLatenessSum.Microseconds()) / float64(data.Iterations)
+	}
+	data.CatchingUpShare = float64(catchingUpWorkers) / float64(len(lags))
+
+	return data
+}
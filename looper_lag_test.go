@@ -0,0 +1,61 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLooperLagRecord(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lag := &looperLag{}
+
+	// On schedule: woke up 1ms late and the next event is in the next period.
+	lag.record(OuterLoopStat{
+		DesiredWakeupTime:     start,
+		ActualWakeupTime:      start.Add(time.Millisecond),
+		EventsEnd:             start.Add(5 * time.Millisecond),
+		NextExpectedEventTime: start.Add(20 * time.Millisecond),
+		EventRate:             200,
+	})
+	require.Equal(t, int64(0), lag.backlogMax)
+
+	// Behind: the next event was due 100ms ago, so 21 events at 200 events/s
+	// are due.
+	lag.record(OuterLoopStat{
+		DesiredWakeupTime:     start.Add(20 * time.Millisecond),
+		ActualWakeupTime:      start.Add(50 * time.Millisecond),
+		EventsEnd:             start.Add(200 * time.Millisecond),
+		NextExpectedEventTime: start.Add(100 * time.Millisecond),
+		EventRate:             200,
+		CatchingUp:            true,
+	})
+
+	require.Equal(t, int64(2), lag.iterations)
+	require.Equal(t, int64(1), lag.catchingUpIterations)
+	require.Equal(t, 31*time.Millisecond, lag.wakeupLatenessSum)
+	require.Equal(t, 30*time.Millisecond, lag.wakeupLatenessMax)
+	require.Equal(t, int64(21), lag.backlogMax)
+
+	lag.reset()
+	require.Equal(t, looperLag{}, *lag)
+}
+
+func TestMergeLooperLags(t *testing.T) {
+	require.Nil(t, mergeLooperLags(nil))
+
+	data := mergeLooperLags([]*looperLag{
+		{iterations: 50, wakeupLatenessSum: 50 * time.Millisecond, wakeupLatenessMax: 2 * time.Millisecond},
+		{iterations: 30, catchingUpIterations: 10, wakeupLatenessSum: 350 * time.Millisecond, wakeupLatenessMax: 40 * time.Millisecond, backlogMax: 8},
+		// A worker stuck in a slow event for the whole interval.
+		{},
+		{iterations: 20, backlogMax: 2},
+	})
+
+	require.Equal(t, int64(100), data.Iterations)
+	require.Equal(t, 4000.0, data.WakeupLatenessMean)
+	require.Equal(t, int64(40000), data.WakeupLatenessMax)
+	require.Equal(t, int64(10), data.Backlog)
+	require.Equal(t, 0.5, data.CatchingUpShare)
+}
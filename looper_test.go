@@ -2,6 +2,7 @@ package mybench
 
 import (
 	"context"
+	"errors"
 	"math"
 	"sync"
 	"testing"
@@ -318,3 +319,88 @@ func TestLooperVaryingEventDurationWithSometimesBackPressure(t *testing.T) {
 	// TODO
 	t.Skip()
 }
+
+func TestLooperResponseTime(t *testing.T) {
+	looper := &DiscretizedLooper{
+		EventRate:     200,
+		OuterLoopRate: 50,
+		LooperType:    LooperTypeUniform,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Each event takes 4 times longer than the time between events, so the
+	// looper falls further behind with every event.
+	looper.Event = func(context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	catchingUp := false
+	looper.TraceOuterLoop = func(stat OuterLoopStat) {
+		catchingUp = catchingUp || stat.CatchingUp
+	}
+
+	stats := make([]EventStat, 0, 40)
+	looper.TraceEvent = func(stat EventStat) {
+		stats = append(stats, stat)
+		if len(stats) == cap(stats) {
+			cancel()
+		}
+	}
+
+	err := looper.Run(ctx)
+	require.Nil(t, err)
+	require.Equal(t, 40, len(stats))
+
+	for _, stat := range stats {
+		require.True(t, stat.ResponseTime >= stat.TimeTaken, "response time %v is less than the service time %v", stat.ResponseTime, stat.TimeTaken)
+	}
+
+	// The 40th event should have started at 195ms, but can only start after the
+	// 39 events before it, which take at least 780ms.
+	lastStat := stats[len(stats)-1]
+	require.True(t, lastStat.TimeTaken < 100*time.Millisecond, "service time %v should not include the delay", lastStat.TimeTaken)
+	require.True(t, lastStat.ResponseTime >= 500*time.Millisecond, "response time %v should include the delay", lastStat.ResponseTime)
+	require.True(t, catchingUp, "looper should be catching up")
+}
+
+func TestLooperSkipEventsUntil(t *testing.T) {
+	looper := &DiscretizedLooper{
+		EventRate:     1000,
+		OuterLoopRate: 100,
+		LooperType:    LooperTypeUniform,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+
+	executedEvents := int64(0)
+	looper.Event = func(context.Context) error {
+		executedEvents++
+		if executedEvents == 50 {
+			return errors.New("connection lost")
+		}
+		return nil
+	}
+
+	// The outage blocks the looper for 200ms, during which about 200 events
+	// should have started.
+	looper.HandleEventError = func(context.Context, error) error {
+		time.Sleep(200 * time.Millisecond)
+		looper.SkipEventsUntil(time.Now())
+		return nil
+	}
+
+	skippedEvents := int64(0)
+	looper.TraceSkippedEvents = func(n int64) {
+		skippedEvents += n
+	}
+
+	require.Nil(t, looper.Run(ctx))
+
+	// The skipped events are not run afterwards to catch up.
+	require.InDelta(t, 200, skippedEvents, 50)
+	require.InDelta(t, 400, executedEvents, 100)
+}
@@ -0,0 +1,281 @@
+package mybench
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/server"
+	"github.com/stretchr/testify/require"
+)
+
+// The collations the test server knows the names of, indexed by the collation
+// id sent by the client in the handshake.
+var testMySQLCollations = map[uint8]string{
+	8:   "latin1_swedish_ci",
+	33:  "utf8mb3_general_ci",
+	45:  "utf8mb4_general_ci",
+	46:  "utf8mb4_bin",
+	255: "utf8mb4_0900_ai_ci",
+}
+
+// The default collation of each charset the test server knows, which is what
+// a plain SET NAMES sets.
+var testMySQLDefaultCollations = map[string]string{
+	"latin1":  "latin1_swedish_ci",
+	"utf8mb3": "utf8mb3_general_ci",
+	"utf8mb4": "utf8mb4_0900_ai_ci",
+}
+
+// An in-process server that speaks the MySQL protocol, so the connection code
+// can be tested without a database. Each connection records the queries and
+// prepared statements it receives and keeps the session state the tests
+// check. It is closed when the test finishes.
+type testMySQLServer struct {
+	listener net.Listener
+
+	mut   sync.Mutex
+	conns []*testMySQLConn
+}
+
+func newTestMySQLServer(t *testing.T) *testMySQLServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	s := &testMySQLServer{listener: listener}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			netConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(netConn)
+			}()
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+		s.mut.Lock()
+		for _, conn := range s.conns {
+			conn.netConn.Close()
+		}
+		s.mut.Unlock()
+		wg.Wait()
+	})
+
+	return s
+}
+
+// Returns a config for connections to the server.
+func (s *testMySQLServer) databaseConfig() DatabaseConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return DatabaseConfig{
+		Host:                 addr.IP.String(),
+		Port:                 addr.Port,
+		User:                 "root",
+		ConnectionMultiplier: 1,
+	}
+}
+
+// Returns the connections accepted so far, in the order they were opened.
+func (s *testMySQLServer) connections() []*testMySQLConn {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	return append([]*testMySQLConn(nil), s.conns...)
+}
+
+func (s *testMySQLServer) serve(netConn net.Conn) {
+	conn := &testMySQLConn{server: s, netConn: netConn, interrupt: make(chan struct{}, 1)}
+	serverConn, err := server.NewConn(netConn, "root", "", conn)
+	if err != nil {
+		netConn.Close()
+		return
+	}
+
+	conn.mut.Lock()
+	conn.conn = serverConn
+	conn.collation = testMySQLCollations[serverConn.Charset()]
+	conn.mut.Unlock()
+
+	s.mut.Lock()
+	s.conns = append(s.conns, conn)
+	s.mut.Unlock()
+
+	for !serverConn.Closed() {
+		if serverConn.HandleCommand() != nil {
+			return
+		}
+	}
+}
+
+// Returns the connection with the ID, or nil if there is none.
+func (s *testMySQLServer) connection(connectionID uint64) *testMySQLConn {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for _, conn := range s.conns {
+		if uint64(conn.conn.ConnectionID()) == connectionID {
+			return conn
+		}
+	}
+
+	return nil
+}
+
+// A connection of the test server, which implements server.Handler. Queries
+// that start with SELECT SLEEP block until they are killed with KILL QUERY or
+// KILL, which also closes the connection, while DO SLEEP sleeps for the given
+// number of seconds.
+type testMySQLConn struct {
+	server    *testMySQLServer
+	netConn   net.Conn
+	conn      *server.Conn
+	interrupt chan struct{}
+
+	mut         sync.Mutex
+	collation   string
+	queries     []string
+	prepared    []string
+	closedStmts int
+}
+
+func (c *testMySQLConn) Queries() []string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return append([]string(nil), c.queries...)
+}
+
+func (c *testMySQLConn) Prepared() []string {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return append([]string(nil), c.prepared...)
+}
+
+func (c *testMySQLConn) ClosedStmts() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.closedStmts
+}
+
+func (c *testMySQLConn) UseDB(dbName string) error {
+	return nil
+}
+
+func (c *testMySQLConn) HandleQuery(query string) (*mysql.Result, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.queries = append(c.queries, query)
+	fields := strings.Fields(query)
+	upper := strings.ToUpper(query)
+
+	switch {
+	case strings.HasPrefix(upper, "SELECT SLEEP("):
+		c.mut.Unlock()
+		<-c.interrupt
+		c.mut.Lock()
+		return nil, mysql.NewError(mysql.ER_QUERY_INTERRUPTED, "Query execution was interrupted")
+	case strings.HasPrefix(upper, "DO SLEEP("):
+		seconds, err := strconv.ParseFloat(strings.TrimSuffix(query[len("DO SLEEP("):], ")"), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mut.Unlock()
+		time.Sleep(time.Duration(seconds * float64(time.Second)))
+		c.mut.Lock()
+	case strings.HasPrefix(upper, "KILL "):
+		connectionID, err := strconv.ParseUint(fields[len(fields)-1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		target := c.server.connection(connectionID)
+		if target == nil {
+			return nil, mysql.NewError(mysql.ER_NO_SUCH_THREAD, fmt.Sprintf("Unknown thread id: %d", connectionID))
+		}
+
+		select {
+		case target.interrupt <- struct{}{}:
+		default:
+		}
+		if strings.ToUpper(fields[1]) != "QUERY" {
+			target.netConn.Close()
+		}
+	case strings.HasPrefix(upper, "SET NAMES "):
+		if len(fields) == 5 && strings.ToUpper(fields[3]) == "COLLATE" {
+			c.collation = fields[4]
+		} else {
+			c.collation = testMySQLDefaultCollations[fields[2]]
+		}
+	case upper == "SELECT @@COLLATION_CONNECTION":
+		return textResult("@@collation_connection", c.collation)
+	case upper == "SELECT CONNECTION_ID()":
+		return textResult("CONNECTION_ID()", c.conn.ConnectionID())
+	case upper == "BEGIN":
+		c.conn.SetInTransaction()
+	case upper == "COMMIT" || upper == "ROLLBACK":
+		c.conn.ClearInTransaction()
+	}
+
+	return nil, nil
+}
+
+func (c *testMySQLConn) HandleFieldList(table string, fieldWildcard string) ([]*mysql.Field, error) {
+	return nil, fmt.Errorf("field list is not supported")
+}
+
+func (c *testMySQLConn) HandleStmtPrepare(query string) (int, int, interface{}, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.prepared = append(c.prepared, query)
+	return strings.Count(query, "?"), 0, query, nil
+}
+
+func (c *testMySQLConn) HandleStmtExecute(context interface{}, query string, args []interface{}) (*mysql.Result, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.queries = append(c.queries, query)
+	return nil, nil
+}
+
+func (c *testMySQLConn) HandleStmtClose(context interface{}) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.closedStmts++
+	return nil
+}
+
+func (c *testMySQLConn) HandleOtherCommand(cmd byte, data []byte) error {
+	return mysql.NewError(mysql.ER_UNKNOWN_ERROR, fmt.Sprintf("command %d is not supported", cmd))
+}
+
+func textResult(column string, value interface{}) (*mysql.Result, error) {
+	resultset, err := mysql.BuildSimpleTextResultset([]string{column}, [][]interface{}{{value}})
+	if err != nil {
+		return nil, err
+	}
+
+	return mysql.NewResult(resultset), nil
+}
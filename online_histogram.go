@@ -17,21 +17,39 @@ type IntervalData struct {
 	Rate      float64
 
 	// All data in microseconds
-	Min          int64
-	Mean         float64
-	Max          int64
-	Percentile25 int64
-	Percentile50 int64
-	Percentile75 int64
-	Percentile90 int64
-	Percentile99 int64
+	Min  int64
+	Mean float64
+	Max  int64
+
+	// The latency at each configured percentile, in ascending order of the
+	// percentiles. See Percentiles.
+	Percentiles []PercentileValue
 
 	UnderflowCount int64
 	OverflowCount  int64
 
+	// The number of events that returned an error, which are not included in
+	// the Count and the latency data.
+	Errors ErrorCounts
+
+	// The number of times events were retried. See RetryConfig.
+	Retries int64
+
 	UniformHist *UniformHistogram
 }
 
+// Returns the latency at the percentile, or false if the percentile is not
+// recorded.
+func (d IntervalData) Percentile(percentile float64) (int64, bool) {
+	for _, v := range d.Percentiles {
+		if v.Percentile == percentile {
+			return v.Value, true
+		}
+	}
+
+	return 0, false
+}
+
 // This is a double buffer implemented using a lock. The target usage is as
 // follows:
 //
@@ -84,17 +102,29 @@ func (b *LockedDoubleBuffer[T]) SafeActiveWrite(f func(T)) {
 // This extends the HDR histogram so it can track:
 // - Start time
 // - Under and overflow counts
+// - Error counts of failed events
+// - Retry counts of events
+// - The unit of the recorded values
 type ExtendedHdrHistogram struct {
-	hist *hdrhistogram.Histogram
+	hist   *hdrhistogram.Histogram
+	config HistogramConfig
 
 	startTime      time.Time
 	overflowCount  int64
 	underflowCount int64
+	errorCounts    ErrorCounts
+	retryCount     int64
 }
 
 func NewExtendedHdrHistogram(startTime time.Time) *ExtendedHdrHistogram {
+	return NewExtendedHdrHistogramWithConfig(startTime, DefaultHistogramConfig)
+}
+
+// The config must be valid, see HistogramConfig.ValidateAndSetDefaults.
+func NewExtendedHdrHistogramWithConfig(startTime time.Time, config HistogramConfig) *ExtendedHdrHistogram {
 	hist := &ExtendedHdrHistogram{
-		hist:           hdrhistogram.New(1, 10000000, 4), // 1us - 10s by default. TODO: make configurable?
+		hist:           hdrhistogram.New(config.LowestTrackable, config.HighestTrackable, config.SignificantFigures),
+		config:         config,
 		startTime:      startTime,
 		overflowCount:  0,
 		underflowCount: 0,
@@ -103,24 +133,43 @@ func NewExtendedHdrHistogram(startTime time.Time) *ExtendedHdrHistogram {
 	return hist
 }
 
+// Records a value in the unit of the histogram.
 func (h *ExtendedHdrHistogram) RecordValue(v int64) {
+	h.recordValues(v, 1)
+}
+
+func (h *ExtendedHdrHistogram) RecordDuration(d time.Duration) {
+	h.recordValues(int64(d/h.config.Unit), 1)
+}
+
+func (h *ExtendedHdrHistogram) recordValues(v, n int64) {
 	if v > h.hist.HighestTrackableValue() {
-		h.overflowCount++
+		h.overflowCount += n
 		return
 	}
 
 	if v < h.hist.LowestTrackableValue() {
-		h.underflowCount++
+		h.underflowCount += n
 		return
 	}
 
-	h.hist.RecordValue(v)
+	h.hist.RecordValues(v, n)
+}
+
+func (h *ExtendedHdrHistogram) RecordError(class ErrorClass) {
+	h.errorCounts.Add(class)
+}
+
+func (h *ExtendedHdrHistogram) RecordRetries(n int64) {
+	h.retryCount += n
 }
 
 func (h *ExtendedHdrHistogram) ResetDataOnly() {
 	h.hist.Reset()
 	h.underflowCount = 0
 	h.overflowCount = 0
+	h.errorCounts = ErrorCounts{}
+	h.retryCount = 0
 }
 
 func (h *ExtendedHdrHistogram) ResetStartTime(startTime time.Time) {
@@ -135,37 +184,74 @@ func (h *ExtendedHdrHistogram) Merge(other *ExtendedHdrHistogram) {
 		panic(fmt.Sprintf("failed to merge histograms with different start time: %v %v", h.startTime, other.startTime))
 	}
 
+	h.mergeData(other)
+}
+
+// Merges the data regardless of the start time, which is used to merge the
+// histograms of consecutive intervals. If the histograms have different units,
+// the values of the other histogram are converted, which is slower as it
+// iterates over all its buckets. Values outside of the range of this histogram
+// are counted as underflow or overflow.
+func (h *ExtendedHdrHistogram) mergeData(other *ExtendedHdrHistogram) {
 	h.underflowCount += other.underflowCount
 	h.overflowCount += other.overflowCount
+	h.errorCounts.Merge(other.errorCounts)
+	h.retryCount += other.retryCount
+
+	if h.config.Unit == other.config.Unit {
+		h.overflowCount += h.hist.Merge(other.hist)
+		return
+	}
+
+	for _, bar := range other.hist.Distribution() {
+		if bar.Count > 0 {
+			h.recordValues(int64(time.Duration(bar.From)*other.config.Unit/h.config.Unit), bar.Count)
+		}
+	}
+}
 
-	h.hist.Merge(other.hist)
+// Converts a value in the unit of the histogram to microseconds.
+func (h *ExtendedHdrHistogram) microseconds(v int64) int64 {
+	return v * int64(h.config.Unit/time.Microsecond)
 }
 
-func (h *ExtendedHdrHistogram) IntervalData(endTime time.Time, histMin, histMax, histSize int64) IntervalData {
+func (h *ExtendedHdrHistogram) IntervalData(endTime time.Time, histMin, histMax, histSize int64, percentiles Percentiles) IntervalData {
 	data := IntervalData{
 		StartTime:      h.startTime,
 		EndTime:        endTime,
 		Count:          h.hist.TotalCount() + h.underflowCount + h.overflowCount,
-		Min:            h.hist.Min(),
-		Mean:           h.hist.Mean(),
-		Max:            h.hist.Max(),
+		Min:            h.microseconds(h.hist.Min()),
+		Mean:           h.hist.Mean() * float64(h.config.Unit/time.Microsecond),
+		Max:            h.microseconds(h.hist.Max()),
 		UnderflowCount: h.underflowCount,
 		OverflowCount:  h.overflowCount,
+		Errors:         h.errorCounts,
+		Retries:        h.retryCount,
 	}
 
-	percentiles := h.hist.ValueAtPercentiles([]float64{25.0, 50.0, 75.0, 90.0, 99.0})
+	values := h.hist.ValueAtPercentiles(percentiles)
 	data.Delta = data.EndTime.Sub(data.StartTime).Seconds()
 	data.Rate = float64(data.Count) / data.Delta
-	data.Percentile25 = percentiles[25.0]
-	data.Percentile50 = percentiles[50.0]
-	data.Percentile75 = percentiles[75.0]
-	data.Percentile90 = percentiles[90.0]
-	data.Percentile99 = percentiles[99.0]
+	data.Percentiles = make([]PercentileValue, len(percentiles))
+	for i, percentile := range percentiles {
+		data.Percentiles[i] = PercentileValue{Percentile: percentile, Value: h.microseconds(values[percentile])}
+	}
 
 	data.UniformHist = h.uniformDistribution(histMin, histMax, histSize)
 	return data
 }
 
+// Returns the HDR histogram in the base64 encoded compressed HdrHistogram
+// format, which can be decoded with hdrhistogram.Decode. Unlike the
+// percentiles, the histograms of multiple intervals can be merged losslessly.
+// The underflow and overflow counts are not included, and the values are in
+// the unit of the histogram, which is a microsecond for all the histograms
+// logged by the data logger.
+func (h *ExtendedHdrHistogram) encode() (string, error) {
+	encoded, err := h.hist.Encode(hdrhistogram.V2CompressedEncodingCookieBase)
+	return string(encoded), err
+}
+
 // Just an imperfect approximation for now.
 func (h *ExtendedHdrHistogram) uniformDistribution(histMin, histMax, histSize int64) *UniformHistogram {
 	hist := NewUniformHistogram(histMin, histMax, histSize)
@@ -180,7 +266,7 @@ func (h *ExtendedHdrHistogram) uniformDistribution(histMin, histMax, histSize in
 			v = (bar.To + bar.From) / 2
 		}
 
-		hist.RecordValues(v, bar.Count)
+		hist.RecordValues(h.microseconds(v), bar.Count)
 	}
 
 	hist.RecordValues(histMin, h.underflowCount)
@@ -194,9 +280,13 @@ type OnlineHistogram struct {
 }
 
 func NewOnlineHistogram(startTime time.Time) *OnlineHistogram {
+	return NewOnlineHistogramWithConfig(startTime, DefaultHistogramConfig)
+}
+
+func NewOnlineHistogramWithConfig(startTime time.Time, config HistogramConfig) *OnlineHistogram {
 	return &OnlineHistogram{
 		LockedDoubleBuffer: NewLockedDoubleBuffer(func() *ExtendedHdrHistogram {
-			return NewExtendedHdrHistogram(startTime)
+			return NewExtendedHdrHistogramWithConfig(startTime, config)
 		}),
 	}
 }
@@ -207,6 +297,24 @@ func (h *OnlineHistogram) RecordValue(v int64) {
 	})
 }
 
+func (h *OnlineHistogram) RecordDuration(d time.Duration) {
+	h.SafeActiveWrite(func(hdrHist *ExtendedHdrHistogram) {
+		hdrHist.RecordDuration(d)
+	})
+}
+
+func (h *OnlineHistogram) RecordError(class ErrorClass) {
+	h.SafeActiveWrite(func(hdrHist *ExtendedHdrHistogram) {
+		hdrHist.RecordError(class)
+	})
+}
+
+func (h *OnlineHistogram) RecordRetries(n int64) {
+	h.SafeActiveWrite(func(hdrHist *ExtendedHdrHistogram) {
+		hdrHist.RecordRetries(n)
+	})
+}
+
 func (h *OnlineHistogram) Swap(preSwapCallback func(nonActiveData *ExtendedHdrHistogram)) *ExtendedHdrHistogram {
 	return h.LockedDoubleBuffer.Swap(preSwapCallback)
 }
@@ -0,0 +1,111 @@
+package mybench
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The latency percentiles recorded for each interval, such as 50, 99, and
+// 99.9. 100 is the maximum latency. It can be used as a flag.Value in the form
+// of 50,99,99.9,max.
+type Percentiles []float64
+
+var DefaultPercentiles = Percentiles{25, 50, 75, 90, 99}
+
+func (p Percentiles) String() string {
+	labels := make([]string, len(p))
+	for i, percentile := range p {
+		if percentile == 100 {
+			labels[i] = "max"
+		} else {
+			labels[i] = formatPercentile(percentile)
+		}
+	}
+
+	return strings.Join(labels, ",")
+}
+
+// Replaces the percentiles, so the default can be overridden.
+func (p *Percentiles) Set(value string) error {
+	percentiles := Percentiles{}
+	for _, label := range strings.Split(value, ",") {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+
+		if label == "max" {
+			percentiles = append(percentiles, 100)
+			continue
+		}
+
+		percentile, err := strconv.ParseFloat(strings.TrimPrefix(label, "p"), 64)
+		if err != nil {
+			return fmt.Errorf("invalid percentile %s: %w", label, err)
+		}
+
+		percentiles = append(percentiles, percentile)
+	}
+
+	*p = percentiles
+	return p.ValidateAndSetDefaults()
+}
+
+// Sorts the percentiles and removes duplicates. Uses DefaultPercentiles if no
+// percentiles are set.
+func (p *Percentiles) ValidateAndSetDefaults() error {
+	if len(*p) == 0 {
+		*p = append(Percentiles{}, DefaultPercentiles...)
+		return nil
+	}
+
+	for _, percentile := range *p {
+		if percentile <= 0 || percentile > 100 {
+			return fmt.Errorf("percentile %v must be greater than 0 and at most 100", percentile)
+		}
+	}
+
+	sort.Float64s(*p)
+	deduplicated := (*p)[:1]
+	for _, percentile := range (*p)[1:] {
+		if percentile != deduplicated[len(deduplicated)-1] {
+			deduplicated = append(deduplicated, percentile)
+		}
+	}
+	*p = deduplicated
+
+	return nil
+}
+
+func (p Percentiles) Contains(percentile float64) bool {
+	for _, v := range p {
+		if v == percentile {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The names of the columns of the percentiles in the log table, such as
+// percentile99 and percentile99_9.
+func (p Percentiles) columnNames() []string {
+	names := make([]string, len(p))
+	for i, percentile := range p {
+		names[i] = "percentile" + strings.ReplaceAll(formatPercentile(percentile), ".", "_")
+	}
+
+	return names
+}
+
+func formatPercentile(percentile float64) string {
+	return strconv.FormatFloat(percentile, 'f', -1, 64)
+}
+
+// The latency at a percentile of an interval in microseconds.
+type PercentileValue struct {
+	Percentile float64
+	Value      int64
+}
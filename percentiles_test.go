@@ -0,0 +1,50 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercentilesFlag(t *testing.T) {
+	var percentiles Percentiles
+	require.Nil(t, percentiles.Set("99.99, p50,max,99,99.9,99"))
+	require.Equal(t, Percentiles{50, 99, 99.9, 99.99, 100}, percentiles)
+	require.Equal(t, "50,99,99.9,99.99,max", percentiles.String())
+	require.Equal(t, []string{"percentile50", "percentile99", "percentile99_9", "percentile99_99", "percentile100"}, percentiles.columnNames())
+
+	require.NotNil(t, percentiles.Set("0"))
+	require.NotNil(t, percentiles.Set("100.1"))
+	require.NotNil(t, percentiles.Set("p99x"))
+
+	percentiles = nil
+	require.Nil(t, percentiles.ValidateAndSetDefaults())
+	require.Equal(t, DefaultPercentiles, percentiles)
+}
+
+func TestIntervalDataPercentiles(t *testing.T) {
+	startTime := time.Now()
+	hist := NewExtendedHdrHistogram(startTime)
+	for v := int64(1); v <= 10000; v++ {
+		hist.RecordValue(v)
+	}
+
+	data := hist.IntervalData(startTime.Add(time.Second), 0, 50000, 1000, Percentiles{50, 99.9, 100})
+	require.Len(t, data.Percentiles, 3)
+
+	p50, found := data.Percentile(50)
+	require.True(t, found)
+	require.InDelta(t, 5000, p50, 10)
+
+	p999, found := data.Percentile(99.9)
+	require.True(t, found)
+	require.InDelta(t, 9990, p999, 10)
+
+	max, found := data.Percentile(100)
+	require.True(t, found)
+	require.Equal(t, data.Max, max)
+
+	_, found = data.Percentile(99)
+	require.False(t, found)
+}
@@ -0,0 +1,246 @@
+package mybench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The metrics of the benchmark in the Prometheus text exposition format,
+// served by the HTTP server at /metrics. The metrics are updated from the data
+// snapshots after the data logger collects them, so scraping never touches the
+// histograms of the workers. The counters and the sums of the summaries are
+// cumulative over the run, while the gauges and the quantiles of the summaries
+// are the values of the last interval.
+//
+// Every sample is labeled with the benchmark name, the note and the workload,
+// where the workload __all__ is all workloads merged together, so it should be
+// excluded when aggregating over the workloads.
+type prometheusMetrics struct {
+	mut sync.Mutex
+
+	// The cumulative data of each workload, indexed by the workload name.
+	workloads map[string]*workloadMetrics
+
+	// The data snapshot of the last interval. Nil before the first interval.
+	latest *DataSnapshot
+}
+
+type workloadMetrics struct {
+	count          int64
+	underflowCount int64
+	overflowCount  int64
+	errors         ErrorCounts
+	retries        int64
+
+	// The sum of the latencies in microseconds, estimated from the mean of
+	// each interval.
+	latencySum float64
+
+	responseTimeCount int64
+	responseTimeSum   float64
+}
+
+func newPrometheusMetrics() *prometheusMetrics {
+	return &prometheusMetrics{
+		workloads: make(map[string]*workloadMetrics),
+	}
+}
+
+// Adds the data of an interval. Only called by the data logger goroutine.
+func (m *prometheusMetrics) update(dataSnapshot *DataSnapshot) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	m.latest = dataSnapshot
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		metrics, found := m.workloads[workloadName]
+		if !found {
+			metrics = &workloadMetrics{}
+			m.workloads[workloadName] = metrics
+		}
+
+		metrics.count += data.Count
+		metrics.underflowCount += data.UnderflowCount
+		metrics.overflowCount += data.OverflowCount
+		metrics.errors.Merge(data.Errors)
+		metrics.retries += data.Retries
+		metrics.latencySum += data.Mean * float64(data.Count)
+
+		if data.ResponseTime != nil {
+			metrics.responseTimeCount += data.ResponseTime.Count
+			metrics.responseTimeSum += data.ResponseTime.Mean * float64(data.ResponseTime.Count)
+		}
+	})
+}
+
+// Iterates over __all__ followed by the workloads sorted by name, which is the
+// order the samples are written in. Must be called with the lock held.
+func (m *prometheusMetrics) workloadDataSnapshots(f func(workloadName string, data WorkloadDataSnapshot)) {
+	if m.latest == nil {
+		return
+	}
+
+	f("__all__", m.latest.AllWorkloadData)
+	for _, workloadName := range sortedKeys(m.latest.PerWorkloadData) {
+		f(workloadName, m.latest.PerWorkloadData[workloadName])
+	}
+}
+
+// Writes all the metrics, labeled with the benchmark name and the note.
+func (m *prometheusMetrics) write(w io.Writer, benchmarkName, note string) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	bw := bufio.NewWriter(w)
+	e := &prometheusEncoder{
+		w:          bw,
+		baseLabels: fmt.Sprintf(`benchmark="%s",note="%s"`, escapeLabelValue(benchmarkName), escapeLabelValue(note)),
+	}
+
+	e.family("mybench_events_total", "counter", "The number of events completed without an error.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		e.sample("mybench_events_total", workloadName, "", float64(m.workloads[workloadName].count))
+	})
+
+	e.family("mybench_errors_total", "counter", "The number of events that returned an error, by the class of the error.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		errors := m.workloads[workloadName].errors
+		for _, class := range []ErrorClass{ErrorClassDeadlock, ErrorClassLockWaitTimeout, ErrorClassLostConnection, ErrorClassQueryTimeout, ErrorClassOther} {
+			e.sample("mybench_errors_total", workloadName, fmt.Sprintf(`class="%s"`, class), float64(errors.count(class)))
+		}
+	})
+
+	e.family("mybench_retries_total", "counter", "The number of times events were retried.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		e.sample("mybench_retries_total", workloadName, "", float64(m.workloads[workloadName].retries))
+	})
+
+	e.family("mybench_latency_underflow_total", "counter", "The number of events with a latency below the range of the histogram.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		e.sample("mybench_latency_underflow_total", workloadName, "", float64(m.workloads[workloadName].underflowCount))
+	})
+
+	e.family("mybench_latency_overflow_total", "counter", "The number of events with a latency above the range of the histogram.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		e.sample("mybench_latency_overflow_total", workloadName, "", float64(m.workloads[workloadName].overflowCount))
+	})
+
+	e.family("mybench_rate", "gauge", "The achieved rate of events per second in the last interval.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		e.sample("mybench_rate", workloadName, "", data.Rate)
+	})
+
+	e.family("mybench_desired_rate", "gauge", "The desired rate of events per second in the last interval. Not set in the closed-loop mode.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		if !data.ClosedLoop {
+			e.sample("mybench_desired_rate", workloadName, "", data.DesiredRate)
+		}
+	})
+
+	e.family("mybench_latency_seconds", "summary", "The latency of the events, measured from their actual start time. The quantiles are of the last interval.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		metrics := m.workloads[workloadName]
+		e.summary("mybench_latency_seconds", workloadName, data.IntervalData, metrics.count, metrics.latencySum)
+	})
+
+	e.family("mybench_response_time_seconds", "summary", "The response time of the events, measured from their intended start time. The quantiles are of the last interval.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		if data.ResponseTime != nil {
+			metrics := m.workloads[workloadName]
+			e.summary("mybench_response_time_seconds", workloadName, *data.ResponseTime, metrics.responseTimeCount, metrics.responseTimeSum)
+		}
+	})
+
+	e.family("mybench_looper_wakeup_lateness_mean_seconds", "gauge", "The mean lateness of the looper wakeups in the last interval. Not set in the closed-loop mode.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		if data.LooperLag != nil {
+			e.sample("mybench_looper_wakeup_lateness_mean_seconds", workloadName, "", data.LooperLag.WakeupLatenessMean/1e6)
+		}
+	})
+
+	e.family("mybench_looper_wakeup_lateness_max_seconds", "gauge", "The maximum lateness of the looper wakeups in the last interval. Not set in the closed-loop mode.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		if data.LooperLag != nil {
+			e.sample("mybench_looper_wakeup_lateness_max_seconds", workloadName, "", float64(data.LooperLag.WakeupLatenessMax)/1e6)
+		}
+	})
+
+	e.family("mybench_looper_backlog", "gauge", "The maximum number of events the loopers were behind the desired schedule in the last interval. Not set in the closed-loop mode.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		if data.LooperLag != nil {
+			e.sample("mybench_looper_backlog", workloadName, "", float64(data.LooperLag.Backlog))
+		}
+	})
+
+	e.family("mybench_looper_catching_up_share", "gauge", "The share of the workers whose loopers were catching up in the last interval. Not set in the closed-loop mode.")
+	m.workloadDataSnapshots(func(workloadName string, data WorkloadDataSnapshot) {
+		if data.LooperLag != nil {
+			e.sample("mybench_looper_catching_up_share", workloadName, "", data.LooperLag.CatchingUpShare)
+		}
+	})
+
+	return bw.Flush()
+}
+
+// Writes the metric families line by line. Errors are reported by the Flush of
+// the underlying writer.
+type prometheusEncoder struct {
+	w          *bufio.Writer
+	baseLabels string
+}
+
+func (e *prometheusEncoder) family(name, metricType, help string) {
+	fmt.Fprintf(e.w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(e.w, "# TYPE %s %s\n", name, metricType)
+}
+
+func (e *prometheusEncoder) sample(name, workloadName, extraLabels string, value float64) {
+	labels := fmt.Sprintf(`%s,workload="%s"`, e.baseLabels, escapeLabelValue(workloadName))
+	if extraLabels != "" {
+		labels += "," + extraLabels
+	}
+
+	fmt.Fprintf(e.w, "%s{%s} %s\n", name, labels, formatPrometheusValue(value))
+}
+
+// Writes a quantile per configured percentile, where the percentile 100 is the
+// quantile 1, followed by the cumulative sum and count. The latencies are
+// converted from microseconds to seconds.
+func (e *prometheusEncoder) summary(name, workloadName string, data IntervalData, count int64, sum float64) {
+	for _, percentile := range data.Percentiles {
+		value := float64(percentile.Value) / 1e6
+		if data.Count == 0 {
+			value = math.NaN()
+		}
+
+		quantile := strconv.FormatFloat(percentile.Percentile/100, 'g', 10, 64)
+		e.sample(name, workloadName, fmt.Sprintf(`quantile="%s"`, quantile), value)
+	}
+
+	e.sample(name+"_sum", workloadName, "", sum/1e6)
+	e.sample(name+"_count", workloadName, "", float64(count))
+}
+
+var prometheusLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return prometheusLabelValueReplacer.Replace(value)
+}
+
+func formatPrometheusValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,57 @@
+package mybench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	percentiles := Percentiles{50, 99.9, 100}
+
+	m := newPrometheusMetrics()
+
+	var empty strings.Builder
+	require.Nil(t, m.write(&empty, "test", ""))
+	require.NotContains(t, empty.String(), "workload=")
+
+	m.update(dataSnapshotForTest(startTime, percentiles))
+	m.update(dataSnapshotForTest(startTime.Add(time.Second), percentiles))
+
+	var out strings.Builder
+	require.Nil(t, m.write(&out, "test", "a \"quoted\" note"))
+	metrics := out.String()
+
+	labels := func(workload string) string {
+		return `{benchmark="test",note="a \"quoted\" note",workload="` + workload + `"`
+	}
+
+	// The counters are cumulative over both intervals.
+	require.Contains(t, metrics, "# TYPE mybench_events_total counter\n")
+	require.Contains(t, metrics, "mybench_events_total"+labels("__all__")+"} 40\n")
+	require.Contains(t, metrics, "mybench_events_total"+labels("A")+"} 20\n")
+	require.Contains(t, metrics, "mybench_errors_total"+labels("A")+`,class="deadlock"} 0`+"\n")
+	require.Contains(t, metrics, "mybench_latency_seconds_count"+labels("B")+"} 20\n")
+	require.Contains(t, metrics, "mybench_latency_seconds_sum"+labels("B")+"} 0.02\n")
+
+	// The gauges and the quantiles are of the last interval.
+	require.Contains(t, metrics, "mybench_desired_rate"+labels("__all__")+"} 20\n")
+	require.Contains(t, metrics, "# TYPE mybench_latency_seconds summary\n")
+	require.Contains(t, metrics, "mybench_latency_seconds"+labels("A")+`,quantile="0.5"} 0.002`+"\n")
+	require.Contains(t, metrics, "mybench_latency_seconds"+labels("A")+`,quantile="0.999"} 0.002`+"\n")
+	require.Contains(t, metrics, "mybench_latency_seconds"+labels("A")+`,quantile="1"} 0.002`+"\n")
+	require.Contains(t, metrics, "mybench_looper_backlog"+labels("A")+"} 3\n")
+	require.NotContains(t, metrics, "mybench_looper_backlog"+labels("__all__"))
+
+	// The test data has no response time.
+	require.NotContains(t, metrics, "mybench_response_time_seconds{")
+
+	// __all__ is written first, followed by the workloads sorted by name.
+	allIndex := strings.Index(metrics, "mybench_rate"+labels("__all__"))
+	aIndex := strings.Index(metrics, "mybench_rate"+labels("A"))
+	bIndex := strings.Index(metrics, "mybench_rate"+labels("B"))
+	require.True(t, allIndex >= 0 && allIndex < aIndex && aIndex < bIndex)
+}
@@ -0,0 +1,245 @@
+package mybench
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A replica of the primary database configured via DatabaseConfig.Host.
+type ReplicaConfig struct {
+	Host string
+
+	// Defaults to the port of the primary.
+	Port int
+
+	// The relative share of the reads sent to this replica with the weighted
+	// ReplicaPolicy. Default: 1.
+	Weight float64
+}
+
+// A list of replicas, which can be used as a flag.Value in the form of a comma
+// separated list of host[:port][=weight], such as replica-1:3306=2,replica-2.
+type Replicas []ReplicaConfig
+
+func (r Replicas) String() string {
+	replicas := make([]string, len(r))
+	for i, replica := range r {
+		replicas[i] = replica.Host
+		if replica.Port != 0 {
+			replicas[i] = net.JoinHostPort(replica.Host, strconv.Itoa(replica.Port))
+		}
+
+		if replica.Weight != 0 {
+			replicas[i] += "=" + strconv.FormatFloat(replica.Weight, 'f', -1, 64)
+		}
+	}
+
+	return strings.Join(replicas, ",")
+}
+
+func (r *Replicas) Set(s string) error {
+	replicas := Replicas{}
+	for _, replicaSpec := range strings.Split(s, ",") {
+		replicaSpec = strings.TrimSpace(replicaSpec)
+		if replicaSpec == "" {
+			continue
+		}
+
+		replica := ReplicaConfig{Host: replicaSpec}
+		if addr, weight, found := strings.Cut(replicaSpec, "="); found {
+			var err error
+			replica.Host = addr
+			replica.Weight, err = strconv.ParseFloat(weight, 64)
+			if err != nil || replica.Weight <= 0 {
+				return fmt.Errorf("invalid weight for replica %s, must be a positive number", replicaSpec)
+			}
+		}
+
+		if host, port, err := net.SplitHostPort(replica.Host); err == nil {
+			replica.Host = host
+			replica.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return fmt.Errorf("invalid port for replica %s: %w", replicaSpec, err)
+			}
+		}
+
+		replicas = append(replicas, replica)
+	}
+
+	*r = replicas
+	return nil
+}
+
+// Decides which replica WorkerContext.Reader returns.
+type ReplicaPolicy int
+
+const (
+	// Cycles through the replicas in order.
+	ReplicaPolicyRoundRobin ReplicaPolicy = iota
+
+	// Picks a random replica with a probability proportional to its weight.
+	ReplicaPolicyWeighted
+)
+
+var replicaPolicyNames = map[ReplicaPolicy]string{
+	ReplicaPolicyRoundRobin: "roundrobin",
+	ReplicaPolicyWeighted:   "weighted",
+}
+
+func (p ReplicaPolicy) String() string {
+	return replicaPolicyNames[p]
+}
+
+// Allows ReplicaPolicy to be used as a flag.Value.
+func (p *ReplicaPolicy) Set(s string) error {
+	for policy, name := range replicaPolicyNames {
+		if name == s {
+			*p = policy
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown replica policy %s, must be roundrobin or weighted", s)
+}
+
+func (cfg *DatabaseConfig) validateAndSetReplicaDefaults() error {
+	for i := range cfg.Replicas {
+		replica := &cfg.Replicas[i]
+		if replica.Host == "" {
+			return errors.New("must specify the host of every replica in -replicas")
+		}
+
+		if replica.Port == 0 {
+			replica.Port = cfg.Port
+		}
+
+		if replica.Weight < 0 {
+			return fmt.Errorf("the weight of replica %s cannot be negative", replica.Host)
+		} else if replica.Weight == 0 {
+			replica.Weight = 1
+		}
+	}
+
+	return nil
+}
+
+// Returns the DatabaseConfig to connect to the replica, which is identical to
+// the config of the primary except for the address.
+func (cfg DatabaseConfig) replicaConfig(replica ReplicaConfig) DatabaseConfig {
+	replicaCfg := cfg
+	replicaCfg.Host = replica.Host
+	replicaCfg.Port = replica.Port
+	replicaCfg.Socket = ""
+	replicaCfg.Replicas = nil
+	return replicaCfg
+}
+
+// Returns the labels of the targets of the connections, which are the primary
+// followed by the replicas. The latency is logged per target.
+func (cfg DatabaseConfig) targetLabels() []string {
+	labels := []string{cfg.addr()}
+	for _, replica := range cfg.Replicas {
+		labels = append(labels, cfg.replicaConfig(replica).addr())
+	}
+
+	return labels
+}
+
+// Holds the connections of a benchmark worker to the primary and the replicas,
+// and tracks which of them was used last, so the latency of the event can be
+// attributed to it.
+type workerTargets struct {
+	// The primary is at index 0, followed by the replicas.
+	conns []*Connection
+
+	policy          ReplicaPolicy
+	weights         []float64
+	totalWeight     float64
+	roundRobinIndex int
+	rand            *Rand
+
+	// The index of the connection used by the current event.
+	current int
+}
+
+func newWorkerTargets(cfg DatabaseConfig, primary *Connection, rand *Rand) (*workerTargets, error) {
+	targets := &workerTargets{
+		conns:  []*Connection{primary},
+		policy: cfg.ReplicaPolicy,
+		rand:   rand,
+	}
+
+	for _, replica := range cfg.Replicas {
+		conn, err := cfg.replicaConfig(replica).Connection()
+		if err != nil {
+			targets.close()
+			return nil, err
+		}
+
+		targets.conns = append(targets.conns, conn)
+		targets.weights = append(targets.weights, replica.Weight)
+		targets.totalWeight += replica.Weight
+	}
+
+	return targets, nil
+}
+
+func (t *workerTargets) writer() *Connection {
+	t.current = 0
+	return t.conns[0]
+}
+
+func (t *workerTargets) reader() *Connection {
+	numReplicas := len(t.conns) - 1
+	if numReplicas == 0 {
+		return t.writer()
+	}
+
+	switch t.policy {
+	case ReplicaPolicyWeighted:
+		v := t.rand.Float64() * t.totalWeight
+		t.current = numReplicas // guards against rounding errors
+		for i, weight := range t.weights {
+			if v < weight {
+				t.current = i + 1
+				break
+			}
+			v -= weight
+		}
+	default:
+		t.current = t.roundRobinIndex + 1
+		t.roundRobinIndex = (t.roundRobinIndex + 1) % numReplicas
+	}
+
+	return t.conns[t.current]
+}
+
+// Closes all connections and opens them again. Returns the time taken to open
+// the new connections, which excludes closing the old ones.
+func (t *workerTargets) reconnect() (time.Duration, error) {
+	for _, conn := range t.conns {
+		conn.closeForReconnect()
+	}
+
+	start := time.Now()
+	for _, conn := range t.conns {
+		err := conn.reopen()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return time.Since(start), nil
+}
+
+// Closes the connections to the replicas. The connection to the primary is
+// closed separately as it is also the WorkerContext.Conn.
+func (t *workerTargets) close() {
+	for _, conn := range t.conns[1:] {
+		conn.Close()
+	}
+}
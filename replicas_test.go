@@ -0,0 +1,92 @@
+package mybench
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicasFlag(t *testing.T) {
+	var replicas Replicas
+	require.Nil(t, replicas.Set("replica-1:3307=2, replica-2,10.0.0.3=0.5"))
+	require.Equal(t, Replicas{
+		{Host: "replica-1", Port: 3307, Weight: 2},
+		{Host: "replica-2"},
+		{Host: "10.0.0.3", Weight: 0.5},
+	}, replicas)
+	require.Equal(t, "replica-1:3307=2,replica-2,10.0.0.3=0.5", replicas.String())
+
+	require.NotNil(t, replicas.Set("replica-1=heavy"))
+	require.NotNil(t, replicas.Set("replica-1=0"))
+	require.NotNil(t, replicas.Set("replica-1:port"))
+
+	var policy ReplicaPolicy
+	require.Nil(t, policy.Set("weighted"))
+	require.Equal(t, ReplicaPolicyWeighted, policy)
+	require.NotNil(t, policy.Set("random"))
+}
+
+func TestReplicaDefaults(t *testing.T) {
+	cfg := DatabaseConfig{
+		Host:     "primary",
+		Port:     3306,
+		Socket:   "/var/run/mysqld/mysqld.sock",
+		Replicas: Replicas{{Host: "replica-1"}, {Host: "replica-2", Port: 3307, Weight: 3}},
+	}
+	require.Nil(t, cfg.validateAndSetReplicaDefaults())
+	require.Equal(t, Replicas{{Host: "replica-1", Port: 3306, Weight: 1}, {Host: "replica-2", Port: 3307, Weight: 3}}, cfg.Replicas)
+
+	// The replicas are always connected to via TCP.
+	require.Equal(t, []string{"/var/run/mysqld/mysqld.sock", "replica-1:3306", "replica-2:3307"}, cfg.targetLabels())
+
+	cfg.Replicas = Replicas{{Host: ""}}
+	require.NotNil(t, cfg.validateAndSetReplicaDefaults())
+}
+
+func newWorkerTargetsForTest(t *testing.T, policy ReplicaPolicy, replicas Replicas) *workerTargets {
+	cfg := DatabaseConfig{Host: "primary", Port: 3306, NoConnection: true, Replicas: replicas, ReplicaPolicy: policy}
+	require.Nil(t, cfg.validateAndSetReplicaDefaults())
+
+	primary, err := cfg.Connection()
+	require.Nil(t, err)
+
+	targets, err := newWorkerTargets(cfg, primary, NewRand())
+	require.Nil(t, err)
+	return targets
+}
+
+func TestWorkerTargetsRoundRobin(t *testing.T) {
+	targets := newWorkerTargetsForTest(t, ReplicaPolicyRoundRobin, Replicas{{Host: "replica-1"}, {Host: "replica-2"}})
+	require.Len(t, targets.conns, 3)
+
+	var readTargets []int
+	for i := 0; i < 4; i++ {
+		conn := targets.reader()
+		require.Same(t, targets.conns[targets.current], conn)
+		readTargets = append(readTargets, targets.current)
+	}
+	require.Equal(t, []int{1, 2, 1, 2}, readTargets)
+
+	require.Same(t, targets.conns[0], targets.writer())
+	require.Equal(t, 0, targets.current)
+}
+
+func TestWorkerTargetsWeighted(t *testing.T) {
+	targets := newWorkerTargetsForTest(t, ReplicaPolicyWeighted, Replicas{{Host: "replica-1", Weight: 3}, {Host: "replica-2", Weight: 1}})
+
+	counts := make([]int, len(targets.conns))
+	for i := 0; i < 10000; i++ {
+		targets.reader()
+		counts[targets.current]++
+	}
+
+	require.Equal(t, 0, counts[0])
+	require.InDelta(t, 7500, counts[1], 300)
+	require.InDelta(t, 2500, counts[2], 300)
+}
+
+func TestWorkerTargetsWithoutReplicas(t *testing.T) {
+	targets := newWorkerTargetsForTest(t, ReplicaPolicyRoundRobin, nil)
+	require.Same(t, targets.conns[0], targets.reader())
+	require.Equal(t, 0, targets.current)
+}
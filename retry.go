@@ -0,0 +1,179 @@
+package mybench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// A list of MySQL error codes, which can be used as a flag.Value in the form
+// of a comma separated list such as 1213,1205.
+type ErrorCodes []uint16
+
+func (c ErrorCodes) String() string {
+	codes := make([]string, len(c))
+	for i, code := range c {
+		codes[i] = strconv.Itoa(int(code))
+	}
+
+	return strings.Join(codes, ",")
+}
+
+func (c *ErrorCodes) Set(s string) error {
+	codes := ErrorCodes{}
+	for _, code := range strings.Split(s, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+
+		parsedCode, err := strconv.ParseUint(code, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid MySQL error code %s: %w", code, err)
+		}
+
+		codes = append(codes, uint16(parsedCode))
+	}
+
+	*c = codes
+	return nil
+}
+
+func (c ErrorCodes) Contains(code uint16) bool {
+	for _, retryableCode := range c {
+		if retryableCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decides which latency is recorded for an event that is retried.
+type RetryLatencyMode int
+
+const (
+	// Record the time taken by all attempts of the event, including the
+	// backoff between them. This is the latency experienced by the application.
+	RetryLatencyEndToEnd RetryLatencyMode = iota
+
+	// Record the time taken by the first attempt only. This is the latency of
+	// the queries as experienced by the database.
+	RetryLatencyFirstAttempt
+)
+
+var retryLatencyModeNames = map[RetryLatencyMode]string{
+	RetryLatencyEndToEnd:     "endtoend",
+	RetryLatencyFirstAttempt: "firstattempt",
+}
+
+func (m RetryLatencyMode) String() string {
+	return retryLatencyModeNames[m]
+}
+
+// Allows RetryLatencyMode to be used as a flag.Value.
+func (m *RetryLatencyMode) Set(s string) error {
+	for mode, name := range retryLatencyModeNames {
+		if name == s {
+			*m = mode
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown retry latency mode %s, must be endtoend or firstattempt", s)
+}
+
+// Configures the retries of events that fail with a retryable MySQL error,
+// similar to how applications retry transactions that fail due to a deadlock.
+// The event is only counted as failed if all attempts fail. The retries are
+// counted in the retry_count column of the log table.
+type RetryConfig struct {
+	// The maximum number of times Event is called for a single event,
+	// including the first attempt. 0 or 1 disables retries.
+	MaxAttempts int
+
+	// The time to wait before the first retry. The backoff is doubled after
+	// every retry, up to MaxBackoff, and is randomized between 50% and 100% of
+	// its value to avoid synchronized retries. Default: 0, which retries
+	// immediately.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// The MySQL error codes to retry. Default: 1213 (deadlock) and 1205 (lock
+	// wait timeout).
+	RetryableErrorCodes ErrorCodes
+
+	LatencyMode RetryLatencyMode
+}
+
+func (c RetryConfig) Enabled() bool {
+	return c.MaxAttempts > 1
+}
+
+func (c *RetryConfig) ValidateAndSetDefaults() error {
+	if c.MaxAttempts < 0 {
+		return errors.New("the maximum number of attempts cannot be negative")
+	}
+
+	if c.Backoff < 0 || c.MaxBackoff < 0 {
+		return errors.New("the retry backoff cannot be negative")
+	}
+
+	if c.MaxBackoff == 0 || c.MaxBackoff < c.Backoff {
+		c.MaxBackoff = c.Backoff
+	}
+
+	if c.RetryableErrorCodes == nil {
+		c.RetryableErrorCodes = ErrorCodes{mysql.ER_LOCK_DEADLOCK, mysql.ER_LOCK_WAIT_TIMEOUT}
+	}
+
+	return nil
+}
+
+// Returns true if the error has a MySQL error code that should be retried.
+func (c RetryConfig) Retryable(err error) bool {
+	code, ok := MySQLErrorCode(err)
+	return ok && c.RetryableErrorCodes.Contains(code)
+}
+
+// Randomizes the backoff between 50% and 100% of its value, so workers that
+// fail at the same time do not retry at the same time.
+func jitterBackoff(backoff time.Duration, randFloat func() float64) time.Duration {
+	return time.Duration((0.5 + 0.5*randFloat()) * float64(backoff))
+}
+
+// Calls the event until it succeeds, fails with an error that is not
+// retryable, or MaxAttempts is reached, waiting for the backoff between the
+// attempts. Returns the number of retries, the time taken by the first
+// attempt, and the error of the last attempt. The backoff is cut short if the
+// context is cancelled. randFloat returns a random number in [0, 1) to
+// randomize the backoff.
+func (c RetryConfig) run(ctx context.Context, event func() error, randFloat func() float64) (int64, time.Duration, error) {
+	start := time.Now()
+	err := event()
+	firstAttemptLatency := time.Since(start)
+
+	var retries int64
+	backoff := c.Backoff
+	for attempt := 1; attempt < c.MaxAttempts && err != nil && c.Retryable(err); attempt++ {
+		if backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return retries, firstAttemptLatency, err
+			case <-time.After(jitterBackoff(backoff, randFloat)):
+			}
+
+			backoff = min(2*backoff, c.MaxBackoff)
+		}
+
+		retries++
+		err = event()
+	}
+
+	return retries, firstAttemptLatency, err
+}
@@ -0,0 +1,130 @@
+package mybench
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func newRetryConfigForTest(t *testing.T, maxAttempts int, backoff time.Duration) RetryConfig {
+	config := RetryConfig{MaxAttempts: maxAttempts, Backoff: backoff}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	return config
+}
+
+// Returns an event that fails with the given errors before succeeding.
+func failingEventForTest(attempts *int, errs ...error) func() error {
+	return func() error {
+		*attempts++
+		if *attempts <= len(errs) {
+			return errs[*attempts-1]
+		}
+		return nil
+	}
+}
+
+func randFloatForTest() float64 {
+	return 0.5
+}
+
+func TestRetryUntilSuccess(t *testing.T) {
+	config := newRetryConfigForTest(t, 5, 0)
+	deadlock := mysql.NewError(mysql.ER_LOCK_DEADLOCK, "Deadlock found when trying to get lock")
+
+	attempts := 0
+	retries, _, err := config.run(context.Background(), failingEventForTest(&attempts, deadlock, deadlock), randFloatForTest)
+	require.Nil(t, err)
+	require.Equal(t, int64(2), retries)
+	require.Equal(t, 3, attempts)
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	config := newRetryConfigForTest(t, 3, 0)
+	lockWaitTimeout := mysql.NewError(mysql.ER_LOCK_WAIT_TIMEOUT, "Lock wait timeout exceeded")
+
+	attempts := 0
+	retries, _, err := config.run(context.Background(), failingEventForTest(&attempts, lockWaitTimeout, lockWaitTimeout, lockWaitTimeout, lockWaitTimeout), randFloatForTest)
+	require.Equal(t, lockWaitTimeout, err)
+	require.Equal(t, int64(2), retries)
+	require.Equal(t, 3, attempts)
+}
+
+func TestRetryOnlyRetryableErrors(t *testing.T) {
+	config := newRetryConfigForTest(t, 5, 0)
+
+	attempts := 0
+	duplicateEntry := mysql.NewError(1062, "Duplicate entry")
+	retries, _, err := config.run(context.Background(), failingEventForTest(&attempts, duplicateEntry), randFloatForTest)
+	require.Equal(t, duplicateEntry, err)
+	require.Equal(t, int64(0), retries)
+	require.Equal(t, 1, attempts)
+
+	attempts = 0
+	otherErr := errors.New("unexpected result")
+	retries, _, err = config.run(context.Background(), failingEventForTest(&attempts, otherErr), randFloatForTest)
+	require.Equal(t, otherErr, err)
+	require.Equal(t, int64(0), retries)
+
+	config.RetryableErrorCodes = ErrorCodes{1062}
+	attempts = 0
+	retries, _, err = config.run(context.Background(), failingEventForTest(&attempts, duplicateEntry), randFloatForTest)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), retries)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	config := newRetryConfigForTest(t, 4, 10*time.Millisecond)
+	config.MaxBackoff = 15 * time.Millisecond
+	deadlock := mysql.NewError(mysql.ER_LOCK_DEADLOCK, "Deadlock found when trying to get lock")
+
+	attempts := 0
+	start := time.Now()
+	retries, firstAttemptLatency, err := config.run(context.Background(), failingEventForTest(&attempts, deadlock, deadlock, deadlock), randFloatForTest)
+	require.Nil(t, err)
+	require.Equal(t, int64(3), retries)
+
+	// The backoffs are 10ms, 15ms, and 15ms, randomized to 75% each.
+	require.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	require.Less(t, firstAttemptLatency, 10*time.Millisecond)
+
+	// The backoff is cut short once the context is cancelled.
+	config.Backoff = time.Hour
+	config.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	attempts = 0
+	retries, _, err = config.run(ctx, failingEventForTest(&attempts, deadlock), randFloatForTest)
+	require.Equal(t, deadlock, err)
+	require.Equal(t, int64(0), retries)
+}
+
+func TestRetryConfigValidation(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 3, Backoff: 10 * time.Millisecond}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.True(t, config.Enabled())
+	require.Equal(t, 10*time.Millisecond, config.MaxBackoff)
+	require.Equal(t, ErrorCodes{mysql.ER_LOCK_DEADLOCK, mysql.ER_LOCK_WAIT_TIMEOUT}, config.RetryableErrorCodes)
+
+	config = RetryConfig{}
+	require.Nil(t, config.ValidateAndSetDefaults())
+	require.False(t, config.Enabled())
+
+	config = RetryConfig{MaxAttempts: -1}
+	require.NotNil(t, config.ValidateAndSetDefaults())
+
+	var codes ErrorCodes
+	require.Nil(t, codes.Set("1213, 1205,3024"))
+	require.Equal(t, ErrorCodes{1213, 1205, 3024}, codes)
+	require.Equal(t, "1213,1205,3024", codes.String())
+	require.NotNil(t, codes.Set("deadlock"))
+
+	var mode RetryLatencyMode
+	require.Nil(t, mode.Set("firstattempt"))
+	require.Equal(t, RetryLatencyFirstAttempt, mode)
+	require.NotNil(t, mode.Set("last"))
+}
@@ -0,0 +1,376 @@
+package mybench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type SaturationSearchMode int
+
+const (
+	SaturationSearchModeNone SaturationSearchMode = iota
+
+	// Increases the event rate by a fixed step until the SLO is violated.
+	SaturationSearchModeStep
+
+	// Bisects the event rate between the start rate and the max rate.
+	SaturationSearchModeBinary
+)
+
+var saturationSearchModeNames = map[SaturationSearchMode]string{
+	SaturationSearchModeNone:   "",
+	SaturationSearchModeStep:   "step",
+	SaturationSearchModeBinary: "binary",
+}
+
+func (m SaturationSearchMode) String() string {
+	return saturationSearchModeNames[m]
+}
+
+// Allows SaturationSearchMode to be used as a flag.Value.
+func (m *SaturationSearchMode) Set(s string) error {
+	for mode, name := range saturationSearchModeNames {
+		if name == s {
+			*m = mode
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown saturation search mode %s, must be step or binary", s)
+}
+
+// A latency and throughput objective that the benchmark must meet at a given
+// event rate for the rate to be considered sustainable.
+type SLO struct {
+	// The latency percentile to check. Must be one of the percentiles recorded
+	// by the benchmark, see BenchmarkConfig.Percentiles. Default: 99.
+	Percentile float64
+
+	// The maximum allowed latency at the percentile.
+	Latency time.Duration
+
+	// The minimum ratio between the achieved and the desired event rate.
+	// Default: 0.98.
+	MinRateRatio float64
+}
+
+// Configures the automatic search for the highest event rate that meets the
+// SLO. When enabled, the benchmark runs through a sequence of event rates and
+// stops once the search is complete. Each rate is held for SettleDuration,
+// after which the data collected over HoldDuration is judged against the SLO.
+type SaturationSearchConfig struct {
+	Mode SaturationSearchMode
+
+	// The first event rate to try. Defaults to the EventRate.
+	StartRate float64
+
+	// The highest event rate to try. Concurrency is calculated from this rate
+	// if not specified.
+	MaxRate float64
+
+	// The increase of the event rate after every passing step in the step mode.
+	// Defaults to a tenth of the range between StartRate and MaxRate.
+	StepRate float64
+
+	// The binary search stops once the range of event rates left to search is
+	// smaller than this fraction of the MaxRate. Default: 0.02.
+	Precision float64
+
+	// How long each event rate is held before the data is judged, to let the
+	// database settle at the new rate. Default: 30s.
+	SettleDuration time.Duration
+
+	// How long the data is collected for each event rate after settling.
+	// Default: 60s.
+	HoldDuration time.Duration
+
+	SLO SLO
+}
+
+func (c SaturationSearchConfig) Enabled() bool {
+	return c.Mode != SaturationSearchModeNone
+}
+
+// The percentiles are the ones recorded by the benchmark, which must include
+// the SLO percentile.
+func (c *SaturationSearchConfig) ValidateAndSetDefaults(eventRate float64, percentiles Percentiles) error {
+	if c.StartRate == 0 {
+		c.StartRate = eventRate
+	}
+
+	if c.MaxRate <= c.StartRate {
+		return errors.New("-searchmax must be greater than the start rate of the search")
+	}
+
+	if c.StepRate == 0 {
+		c.StepRate = (c.MaxRate - c.StartRate) / 10
+	}
+
+	if c.Precision == 0 {
+		c.Precision = 0.02
+	}
+
+	if c.SettleDuration == 0 {
+		c.SettleDuration = 30 * time.Second
+	}
+
+	if c.HoldDuration == 0 {
+		c.HoldDuration = 60 * time.Second
+	}
+
+	if c.SLO.Percentile == 0 {
+		c.SLO.Percentile = 99
+	}
+
+	if c.SLO.MinRateRatio == 0 {
+		c.SLO.MinRateRatio = 0.98
+	}
+
+	if c.StartRate <= 0 || c.StepRate <= 0 || c.Precision <= 0 {
+		return errors.New("the start rate, step rate, and precision of the search must be positive")
+	}
+
+	if c.SettleDuration < 0 || c.HoldDuration <= 0 {
+		return errors.New("the settle and hold durations of the search must be positive")
+	}
+
+	if c.SLO.Latency <= 0 {
+		return errors.New("must specify -slolatency for the saturation search")
+	}
+
+	if !percentiles.Contains(c.SLO.Percentile) {
+		return fmt.Errorf("unsupported SLO percentile %v, must be one of the recorded percentiles %s (see -percentiles)", c.SLO.Percentile, percentiles)
+	}
+
+	return nil
+}
+
+// The outcome of a single step of the saturation search.
+type SaturationSearchStep struct {
+	// The label of the segment in the log table.
+	Segment string
+
+	// The desired event rate of the step.
+	EventRate float64
+
+	// The achieved event rate, averaged over the hold duration.
+	AchievedRate float64
+
+	// The latency at the SLO percentile over the hold duration.
+	Latency time.Duration
+
+	// True if the step meets the SLO.
+	Passed bool
+}
+
+type SaturationSearchResult struct {
+	Steps []SaturationSearchStep
+
+	// The highest event rate that meets the SLO. 0 if even the start rate does
+	// not meet the SLO.
+	BestRate float64
+}
+
+// Judges a step of the search based on the data collected during its hold
+// duration. The histograms of the intervals are merged, so the latency at the
+// SLO percentile is exact for the whole hold duration and a single bad interval
+// is not averaged away. If more events than allowed by the percentile exceeded
+// the range of the histograms, the latency is the highest trackable value.
+func judgeSaturationSearchStep(step SaturationSearchStep, slo SLO, dataSnapshots []*DataSnapshot) (SaturationSearchStep, error) {
+	var count int64
+	var delta, desiredEvents float64
+	intervals := make([]*ExtendedHdrHistogram, 0, len(dataSnapshots))
+	for _, dataSnapshot := range dataSnapshots {
+		data := dataSnapshot.AllWorkloadData
+		count += data.Count
+		delta += data.Delta
+		desiredEvents += data.DesiredRate * data.Delta
+
+		interval, err := decodeLoggedHistogram(data.encodedHist, data.StartTime)
+		if err != nil {
+			return step, fmt.Errorf("failed to decode the histogram of the interval ending at %s: %w", data.EndTime.Format(time.RFC3339), err)
+		}
+
+		interval.underflowCount = data.UnderflowCount
+		interval.overflowCount = data.OverflowCount
+		intervals = append(intervals, interval)
+	}
+
+	if delta == 0 || desiredEvents == 0 || count == 0 {
+		step.Passed = false
+		return step, nil
+	}
+
+	merged := mergeIntervalHistograms(intervals)
+	latency := merged.hist.ValueAtPercentile(slo.Percentile)
+	if float64(merged.overflowCount) > float64(count)*(1-slo.Percentile/100) {
+		latency = merged.hist.HighestTrackableValue()
+	}
+
+	step.AchievedRate = float64(count) / delta
+	step.Latency = time.Duration(latency) * time.Microsecond
+	step.Passed = step.Latency <= slo.Latency && float64(count)/desiredEvents >= slo.MinRateRatio
+	return step, nil
+}
+
+// Runs the search algorithm, independent of the benchmark. runStep runs the
+// benchmark at the given event rate and judges the result.
+func runSaturationSearch(config SaturationSearchConfig, runStep func(eventRate float64) (SaturationSearchStep, error)) (SaturationSearchResult, error) {
+	result := SaturationSearchResult{}
+
+	run := func(eventRate float64) (bool, error) {
+		step, err := runStep(eventRate)
+		if err != nil {
+			return false, err
+		}
+
+		result.Steps = append(result.Steps, step)
+		if step.Passed && eventRate > result.BestRate {
+			result.BestRate = eventRate
+		}
+
+		return step.Passed, nil
+	}
+
+	switch config.Mode {
+	case SaturationSearchModeStep:
+		for eventRate := config.StartRate; eventRate <= config.MaxRate; eventRate += config.StepRate {
+			passed, err := run(eventRate)
+			if err != nil || !passed {
+				return result, err
+			}
+		}
+	case SaturationSearchModeBinary:
+		passed, err := run(config.StartRate)
+		if err != nil || !passed {
+			return result, err
+		}
+
+		low, high := config.StartRate, config.MaxRate
+		passed, err = run(high)
+		if err != nil || passed {
+			return result, err
+		}
+
+		for high-low > config.Precision*config.MaxRate {
+			mid := (low + high) / 2
+			passed, err = run(mid)
+			if err != nil {
+				return result, err
+			}
+
+			if passed {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Runs the saturation search on a started benchmark by changing its event rate
+// and judging the data logged for each step against the SLO. Every step is
+// logged as a separate segment in the log table. Returns early with an error
+// if the benchmark is stopped before the search is complete.
+func (b *Benchmark) RunSaturationSearch(config SaturationSearchConfig) (SaturationSearchResult, error) {
+	// The data for each step is read from the ring of data snapshots, which
+	// only holds a limited amount of data.
+	if config.HoldDuration > time.Duration(b.LogRingSize-1)*b.LogInterval {
+		return SaturationSearchResult{}, fmt.Errorf("the hold duration of the search cannot be longer than %v", time.Duration(b.LogRingSize-1)*b.LogInterval)
+	}
+
+	stepNumber := 0
+	runStep := func(eventRate float64) (SaturationSearchStep, error) {
+		stepNumber++
+		step := SaturationSearchStep{
+			Segment:   fmt.Sprintf("search step %d: %.1f/s", stepNumber, eventRate),
+			EventRate: eventRate,
+		}
+
+		logger := b.logger.WithFields(logrus.Fields{"step": stepNumber, "eventrate": eventRate})
+		logger.Info("starting saturation search step")
+
+		b.SetSegment(step.Segment + " (settle)")
+		err := b.SetEventRate(eventRate)
+		if err != nil {
+			return step, err
+		}
+
+		err = b.sleepUnlessStopped(config.SettleDuration)
+		if err != nil {
+			return step, err
+		}
+
+		b.SetSegment(step.Segment)
+		holdStart := time.Now()
+		err = b.sleepUnlessStopped(config.HoldDuration)
+		if err != nil {
+			return step, err
+		}
+
+		// Wait for the data logger to collect the last interval of the hold.
+		err = b.sleepUnlessStopped(b.LogInterval)
+		if err != nil {
+			return step, err
+		}
+		holdEnd := holdStart.Add(config.HoldDuration)
+
+		var dataSnapshots []*DataSnapshot
+		for _, dataSnapshot := range b.DataSnapshots() {
+			data := dataSnapshot.AllWorkloadData
+			if !data.StartTime.Before(holdStart) && !data.EndTime.After(holdEnd) {
+				dataSnapshots = append(dataSnapshots, dataSnapshot)
+			}
+		}
+
+		step, err = judgeSaturationSearchStep(step, config.SLO, dataSnapshots)
+		if err != nil {
+			return step, err
+		}
+
+		logger.WithFields(logrus.Fields{
+			"achievedrate": step.AchievedRate,
+			"latency":      step.Latency,
+			"passed":       step.Passed,
+		}).Info("finished saturation search step")
+
+		return step, nil
+	}
+
+	result, err := runSaturationSearch(config, runStep)
+	b.SetSegment("")
+	return result, err
+}
+
+func (b *Benchmark) sleepUnlessStopped(d time.Duration) error {
+	select {
+	case <-b.workloadCtx.Done():
+		return context.Canceled
+	case <-time.After(d):
+		return nil
+	}
+}
+
+func logSaturationSearchResult(result SaturationSearchResult) {
+	for _, step := range result.Steps {
+		logrus.WithFields(logrus.Fields{
+			"eventrate":    step.EventRate,
+			"achievedrate": step.AchievedRate,
+			"latency":      step.Latency,
+			"passed":       step.Passed,
+		}).Info(step.Segment)
+	}
+
+	if result.BestRate == 0 {
+		logrus.Warn("saturation search complete: no event rate meets the SLO, try a lower -searchstart")
+		return
+	}
+
+	logrus.Infof("saturation search complete: the highest sustainable event rate is %.1f/s", result.BestRate)
+}
@@ -0,0 +1,168 @@
+package mybench
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Simulates a database that can sustain event rates up to the capacity.
+func fakeSaturationSearchStep(capacity float64, eventRates *[]float64) func(float64) (SaturationSearchStep, error) {
+	return func(eventRate float64) (SaturationSearchStep, error) {
+		*eventRates = append(*eventRates, eventRate)
+		return SaturationSearchStep{EventRate: eventRate, Passed: eventRate <= capacity}, nil
+	}
+}
+
+func TestSaturationSearchStepMode(t *testing.T) {
+	config := SaturationSearchConfig{Mode: SaturationSearchModeStep, StartRate: 1000, MaxRate: 5000, StepRate: 1000}
+
+	var eventRates []float64
+	result, err := runSaturationSearch(config, fakeSaturationSearchStep(3500, &eventRates))
+	require.Nil(t, err)
+	require.Equal(t, []float64{1000, 2000, 3000, 4000}, eventRates)
+	require.Equal(t, 3000.0, result.BestRate)
+	require.Len(t, result.Steps, 4)
+	require.False(t, result.Steps[3].Passed)
+
+	eventRates = nil
+	result, err = runSaturationSearch(config, fakeSaturationSearchStep(10000, &eventRates))
+	require.Nil(t, err)
+	require.Equal(t, []float64{1000, 2000, 3000, 4000, 5000}, eventRates)
+	require.Equal(t, 5000.0, result.BestRate)
+}
+
+func TestSaturationSearchBinaryMode(t *testing.T) {
+	config := SaturationSearchConfig{Mode: SaturationSearchModeBinary, StartRate: 1000, MaxRate: 9000, Precision: 0.01}
+
+	var eventRates []float64
+	result, err := runSaturationSearch(config, fakeSaturationSearchStep(6200, &eventRates))
+	require.Nil(t, err)
+	require.Equal(t, []float64{1000, 9000, 5000, 7000, 6000, 6500, 6250, 6125, 6187.5}, eventRates)
+	require.Equal(t, 6187.5, result.BestRate)
+
+	eventRates = nil
+	result, err = runSaturationSearch(config, fakeSaturationSearchStep(500, &eventRates))
+	require.Nil(t, err)
+	require.Equal(t, []float64{1000}, eventRates)
+	require.Equal(t, 0.0, result.BestRate)
+
+	eventRates = nil
+	result, err = runSaturationSearch(config, fakeSaturationSearchStep(10000, &eventRates))
+	require.Nil(t, err)
+	require.Equal(t, []float64{1000, 9000}, eventRates)
+	require.Equal(t, 9000.0, result.BestRate)
+}
+
+func TestSaturationSearchStopsOnError(t *testing.T) {
+	config := SaturationSearchConfig{Mode: SaturationSearchModeStep, StartRate: 1000, MaxRate: 5000, StepRate: 1000}
+
+	stepErr := errors.New("benchmark stopped")
+	result, err := runSaturationSearch(config, func(eventRate float64) (SaturationSearchStep, error) {
+		if eventRate > 2000 {
+			return SaturationSearchStep{}, stepErr
+		}
+		return SaturationSearchStep{EventRate: eventRate, Passed: true}, nil
+	})
+	require.ErrorIs(t, err, stepErr)
+	require.Equal(t, 2000.0, result.BestRate)
+}
+
+// Records the given number of events at each latency.
+func newDataSnapshotForTest(desiredRate float64, latencies map[time.Duration]int) *DataSnapshot {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	hist := NewExtendedHdrHistogram(startTime)
+	for latency, count := range latencies {
+		for i := 0; i < count; i++ {
+			hist.RecordDuration(latency)
+		}
+	}
+
+	encodedHist, err := hist.encode()
+	if err != nil {
+		panic(err)
+	}
+
+	return &DataSnapshot{
+		AllWorkloadData: WorkloadDataSnapshot{
+			IntervalData: hist.IntervalData(startTime.Add(time.Second), 1, 300000, 1000, Percentiles{99}),
+			DesiredRate:  desiredRate,
+			encodedHist:  encodedHist,
+		},
+	}
+}
+
+func TestJudgeSaturationSearchStep(t *testing.T) {
+	slo := SLO{Percentile: 99, Latency: 20 * time.Millisecond, MinRateRatio: 0.98}
+
+	step, err := judgeSaturationSearchStep(SaturationSearchStep{EventRate: 1000}, slo, []*DataSnapshot{
+		newDataSnapshotForTest(1000, map[time.Duration]int{10 * time.Millisecond: 1000}),
+		newDataSnapshotForTest(1000, map[time.Duration]int{10 * time.Millisecond: 985, 30 * time.Millisecond: 5}),
+	})
+	require.Nil(t, err)
+	require.True(t, step.Passed)
+	require.InDelta(t, 995.0, step.AchievedRate, 1e-9)
+	require.InDelta(t, float64(10*time.Millisecond), float64(step.Latency), float64(2*time.Microsecond))
+
+	// A single bad interval is not averaged away: 2% of the events of the step
+	// are above the SLO latency, although the p99 of the first interval is low.
+	step, err = judgeSaturationSearchStep(SaturationSearchStep{EventRate: 1000}, slo, []*DataSnapshot{
+		newDataSnapshotForTest(1000, map[time.Duration]int{10 * time.Millisecond: 1000}),
+		newDataSnapshotForTest(1000, map[time.Duration]int{10 * time.Millisecond: 950, 30 * time.Millisecond: 40}),
+	})
+	require.Nil(t, err)
+	require.False(t, step.Passed)
+	require.InDelta(t, float64(30*time.Millisecond), float64(step.Latency), float64(5*time.Microsecond))
+
+	// Latency is too high
+	step, err = judgeSaturationSearchStep(SaturationSearchStep{EventRate: 1000}, slo, []*DataSnapshot{
+		newDataSnapshotForTest(1000, map[time.Duration]int{25 * time.Millisecond: 1000}),
+	})
+	require.Nil(t, err)
+	require.False(t, step.Passed)
+
+	// Too many events exceed the range of the histogram
+	step, err = judgeSaturationSearchStep(SaturationSearchStep{EventRate: 1000}, slo, []*DataSnapshot{
+		newDataSnapshotForTest(1000, map[time.Duration]int{5 * time.Millisecond: 980, 20 * time.Second: 20}),
+	})
+	require.Nil(t, err)
+	require.False(t, step.Passed)
+	require.Equal(t, 10*time.Second, step.Latency.Round(time.Second))
+
+	// Achieved rate is too low
+	step, err = judgeSaturationSearchStep(SaturationSearchStep{EventRate: 1000}, slo, []*DataSnapshot{
+		newDataSnapshotForTest(1000, map[time.Duration]int{5 * time.Millisecond: 900}),
+	})
+	require.Nil(t, err)
+	require.False(t, step.Passed)
+
+	// No data
+	step, err = judgeSaturationSearchStep(SaturationSearchStep{EventRate: 1000}, slo, nil)
+	require.Nil(t, err)
+	require.False(t, step.Passed)
+}
+
+func TestSaturationSearchConfigValidation(t *testing.T) {
+	config := SaturationSearchConfig{Mode: SaturationSearchModeStep, MaxRate: 5000, SLO: SLO{Latency: 20 * time.Millisecond}}
+	require.Nil(t, config.ValidateAndSetDefaults(1000, DefaultPercentiles))
+	require.Equal(t, 1000.0, config.StartRate)
+	require.Equal(t, 400.0, config.StepRate)
+	require.Equal(t, 99.0, config.SLO.Percentile)
+
+	config = SaturationSearchConfig{Mode: SaturationSearchModeStep, MaxRate: 5000}
+	require.ErrorContains(t, config.ValidateAndSetDefaults(1000, DefaultPercentiles), "-slolatency")
+
+	config = SaturationSearchConfig{Mode: SaturationSearchModeStep, MaxRate: 500, SLO: SLO{Latency: 20 * time.Millisecond}}
+	require.ErrorContains(t, config.ValidateAndSetDefaults(1000, DefaultPercentiles), "-searchmax")
+
+	config = SaturationSearchConfig{Mode: SaturationSearchModeStep, MaxRate: 5000, SLO: SLO{Percentile: 95, Latency: 20 * time.Millisecond}}
+	require.ErrorContains(t, config.ValidateAndSetDefaults(1000, DefaultPercentiles), "percentile")
+	require.Nil(t, config.ValidateAndSetDefaults(1000, Percentiles{50, 95, 99}))
+
+	var mode SaturationSearchMode
+	require.Nil(t, mode.Set("binary"))
+	require.Equal(t, SaturationSearchModeBinary, mode)
+	require.NotNil(t, mode.Set("linear"))
+}
@@ -0,0 +1,297 @@
+package mybench
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/sirupsen/logrus"
+)
+
+const createMetaTableStatement = `
+CREATE TABLE IF NOT EXISTS meta (
+	table_name TEXT PRIMARY KEY,
+	benchmark_name TEXT,
+	mybench_version TEXT,
+	note TEXT,
+	start_time TEXT,
+	end_time TEXT,
+	config TEXT,
+	summary TEXT
+)
+`
+
+// Columns added to the meta table after its initial version. Existing log
+// files are migrated to have these columns when they are opened.
+var metaTableAddedColumns = []string{
+	"config TEXT",
+	"summary TEXT",
+}
+
+var insertMetaStatement = "INSERT INTO meta (table_name, note, benchmark_name, mybench_version, start_time, config) VALUES (?, ?, ?, '" + VersionString + "', ?, ?)"
+
+// The summary is the RunSummary in JSON, which is NULL if there is no data in
+// the measurement phase.
+const updateMetaEndTimeStatement = `
+UPDATE meta SET end_time = ?, summary = ? WHERE table_name = ?
+`
+
+// The columns after retry_count depend on the configured percentiles, see
+// dataColumns.
+const createTableStatement = `
+CREATE TABLE %[1]s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT, -- Using auto increment allows the id to be strictly monotonic
+	workload TEXT,
+	host TEXT, -- NULL except for the latency per host, which is logged with the workload __host__
+	seconds_since_start REAL,
+	interval_start TEXT,
+	interval_end TEXT,
+	segment TEXT,
+	phase TEXT,
+	desired_rate REAL,
+	count INTEGER,
+	delta REAL,
+	rate REAL,
+	min INTEGER,
+	mean INTEGER,
+	max INTEGER,
+	underflow_count INTEGER,
+	overflow_count INTEGER,
+	error_count INTEGER,
+	deadlock_count INTEGER,
+	lock_wait_timeout_count INTEGER,
+	lost_connection_count INTEGER,
+	query_timeout_count INTEGER,
+	other_error_count INTEGER,
+	retry_count INTEGER,
+	%[2]s
+);
+CREATE INDEX %[1]s_workload ON %[1]s(workload);
+`
+
+// The columns are the LogColumns.
+const insertQuery = `
+INSERT INTO %[1]s (
+	%[2]s
+) VALUES (%[3]s)
+`
+
+// The downtime windows of each workload, see DowntimeWindow. The start and end
+// times are logged with sub-second precision, as failovers can be short.
+const createDowntimeTableStatement = `
+CREATE TABLE %s_downtime (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	workload TEXT,
+	seconds_since_start REAL,
+	start_time TEXT,
+	end_time TEXT, -- NULL if the database was unavailable when the benchmark stopped
+	duration REAL,
+	failed_events INTEGER
+);
+`
+
+const insertDowntimeQuery = `
+INSERT INTO %s_downtime (
+	workload,
+	seconds_since_start,
+	start_time,
+	end_time,
+	duration,
+	failed_events
+) VALUES (?, ?, ?, ?, ?, ?)
+`
+
+// Logs the data to a table in an SQLite file, which is named after the run.
+// The run is listed in the meta table, along with its resolved config and its
+// summary. The downtime windows are logged to a separate table with the
+// _downtime suffix. See MergeLoggedIntervals to analyze the data.
+type SQLiteSink struct {
+	Filename string
+
+	logger          logrus.FieldLogger
+	db              *sql.DB
+	tableName       string
+	startTime       time.Time
+	insertStatement string
+}
+
+func NewSQLiteSink(filename string) *SQLiteSink {
+	return &SQLiteSink{
+		Filename: filename,
+		logger:   logrus.WithField("tag", "sqlite_sink"),
+	}
+}
+
+// If opening fails, the database is closed again, as Close is not called.
+func (s *SQLiteSink) Open(run RunInfo) (err error) {
+	s.tableName = run.TableName
+	s.startTime = run.StartTime
+
+	s.db, err = sql.Open("sqlite3", s.Filename)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			s.db.Close()
+			s.db = nil
+		}
+	}()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(createMetaTableStatement)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = addMissingColumns(tx, "meta", metaTableAddedColumns)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	columnDefinitions := strings.Join(dataColumns(run.Percentiles), ",\n\t")
+
+	columns := LogColumns(run.Percentiles)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	s.insertStatement = fmt.Sprintf(insertQuery, s.tableName, strings.Join(columns, ",\n\t"), placeholders)
+
+	_, err = tx.Exec(fmt.Sprintf(createTableStatement, s.tableName, columnDefinitions))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(createDowntimeTableStatement, s.tableName))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var resolvedConfig interface{} // NULL if there's no resolved config
+	if run.ResolvedConfig != nil {
+		data, err := json.Marshal(run.ResolvedConfig)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		resolvedConfig = string(data)
+	}
+
+	_, err = tx.Exec(insertMetaStatement, s.tableName, run.Note, run.Benchmark, s.startTime.Format(time.RFC3339), resolvedConfig)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	s.logger.Infof("using log data file at %s with table %s", s.Filename, s.tableName)
+	return nil
+}
+
+func (s *SQLiteSink) Log(dataSnapshot *DataSnapshot) error {
+	for _, row := range dataSnapshot.Rows() {
+		_, err := s.db.Exec(s.insertStatement, row...)
+		if err != nil {
+			return err
+		}
+		s.logger.Debug(row)
+	}
+
+	return nil
+}
+
+func (s *SQLiteSink) LogDowntimeWindows(windows []DowntimeWindow) error {
+	for _, window := range windows {
+		var endTime, duration interface{} // NULL if the window has not ended
+		if !window.EndTime.IsZero() {
+			endTime = window.EndTime.Format(time.RFC3339Nano)
+			duration = window.Duration().Seconds()
+		}
+
+		_, err := s.db.Exec(
+			fmt.Sprintf(insertDowntimeQuery, s.tableName),
+			window.Workload,
+			window.StartTime.Sub(s.startTime).Seconds(),
+			window.StartTime.Format(time.RFC3339Nano),
+			endTime,
+			duration,
+			window.FailedEvents,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Records the end time and the summary of the run in the meta table.
+func (s *SQLiteSink) Close(summary *RunSummary) error {
+	defer s.db.Close()
+
+	var summaryArg interface{} // NULL if there is no data in the measurement phase
+	if summary != nil {
+		data, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		summaryArg = string(data)
+	}
+
+	_, err := s.db.Exec(updateMetaEndTimeStatement, time.Now().Format(time.RFC3339), summaryArg, s.tableName)
+	return err
+}
+
+// Adds the columns to the table if they do not exist already. This is used to
+// migrate tables in log files created by older versions of mybench.
+func addMissingColumns(tx *sql.Tx, table string, columns []string) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+
+	existingColumns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		existingColumns[name] = true
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		name := strings.Fields(column)[0]
+		if existingColumns[name] {
+			continue
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
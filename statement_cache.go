@@ -0,0 +1,260 @@
+package mybench
+
+import (
+	"container/list"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// Executes the query with the arguments on the next underlying connection, see
+// DatabaseConfig.ConnectionMultiplier.
+//
+// By default, the query is executed as a prepared statement via the binary
+// protocol. The statement is prepared the first time the query is executed on
+// each underlying connection and reused afterwards, so workloads do not need to
+// prepare their statements in NewContextData. At most
+// DatabaseConfig.StmtCacheSize statements are kept per underlying connection.
+// The cached statements are discarded by Reconnect and prepared again on the
+// new connections.
+//
+// With DatabaseConfig.TextProtocol, the arguments are instead interpolated
+// into the query on the client and the query is executed via the text
+// protocol, which allows the two protocols to be compared with the same
+// workload.
+func (c *Connection) ExecuteCached(query string, args ...interface{}) (*mysql.Result, error) {
+	if c.config.TextProtocol {
+		interpolatedQuery, err := interpolateQuery(query, args)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.Execute(interpolatedQuery)
+	}
+
+	connIndex := c.nextConnIndex()
+	stmt, err := c.cachedStmt(connIndex, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Execute(args...)
+}
+
+// The maximum number of statements ExecuteCached keeps prepared on each
+// underlying connection if DatabaseConfig.StmtCacheSize is not set.
+const DefaultStmtCacheSize = 256
+
+// The statements prepared by ExecuteCached on an underlying connection, keyed
+// by the query. Once the cache is full, the least recently used statement is
+// closed to make room for a new one, so workloads that generate many distinct
+// queries do not exhaust max_prepared_stmt_count on the server.
+type stmtCache struct {
+	size    int
+	entries map[string]*list.Element
+
+	// The cached statements, from the most to the least recently used.
+	lru *list.List
+}
+
+type stmtCacheEntry struct {
+	query string
+	stmt  *client.Stmt
+}
+
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		size = DefaultStmtCacheSize
+	}
+
+	return &stmtCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Returns the cached statement of the query, if any, and marks it as the most
+// recently used.
+func (c *stmtCache) get(query string) (*client.Stmt, bool) {
+	element, found := c.entries[query]
+	if !found {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return element.Value.(*stmtCacheEntry).stmt, true
+}
+
+// Caches the statement of the query, closing the least recently used statement
+// if the cache is full.
+func (c *stmtCache) put(query string, stmt *client.Stmt) error {
+	c.entries[query] = c.lru.PushFront(&stmtCacheEntry{query: query, stmt: stmt})
+	if c.lru.Len() <= c.size {
+		return nil
+	}
+
+	entry := c.lru.Remove(c.lru.Back()).(*stmtCacheEntry)
+	delete(c.entries, entry.query)
+	return entry.stmt.Close()
+}
+
+// Returns the prepared statement of the query on the underlying connection,
+// preparing it if it is not yet cached.
+func (c *Connection) cachedStmt(connIndex int, query string) (*client.Stmt, error) {
+	if c.stmtCache == nil {
+		c.stmtCache = make([]*stmtCache, len(c.connList))
+	}
+
+	if c.stmtCache[connIndex] == nil {
+		c.stmtCache[connIndex] = newStmtCache(c.config.StmtCacheSize)
+	}
+
+	stmt, found := c.stmtCache[connIndex].get(query)
+	if found {
+		return stmt, nil
+	}
+
+	stmt, err := c.connList[connIndex].Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.stmtCache[connIndex].put(query, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close the least recently used prepared statement: %w", err)
+	}
+
+	return stmt, nil
+}
+
+// Discards the cached statements. The statements are not closed, as this is
+// only called once the underlying connections are closed, which releases the
+// statements on the server.
+func (c *Connection) clearStmtCache() {
+	c.stmtCache = nil
+}
+
+// Replaces each ? placeholder in the query with the corresponding argument
+// formatted as an SQL literal. Placeholders in quoted strings and identifiers
+// are ignored. Quotes are escaped by doubling them, and backslashes are not
+// treated as escapes, as they are not with the NO_BACKSLASH_ESCAPES SQL mode,
+// which may be set by DatabaseConfig.InitStatements.
+func interpolateQuery(query string, args []interface{}) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
+
+	var buf strings.Builder
+	buf.Grow(len(query) + len(args)*8)
+
+	argIndex := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			// A doubled quote closes and reopens the quote.
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			if argIndex >= len(args) {
+				return "", fmt.Errorf("query has more placeholders than the %d arguments: %s", len(args), query)
+			}
+
+			err := appendSQLLiteral(&buf, args[argIndex])
+			if err != nil {
+				return "", err
+			}
+
+			argIndex++
+			continue
+		}
+
+		buf.WriteByte(ch)
+	}
+
+	if argIndex != len(args) {
+		return "", fmt.Errorf("query has %d placeholders but %d arguments: %s", argIndex, len(args), query)
+	}
+
+	return buf.String(), nil
+}
+
+func appendSQLLiteral(buf *strings.Builder, arg interface{}) error {
+	switch v := arg.(type) {
+	case nil:
+		buf.WriteString("NULL")
+	case int:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int8:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int16:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int32:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case uint:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint8:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(v, 10))
+	case float32:
+		buf.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		if v {
+			buf.WriteString("1")
+		} else {
+			buf.WriteString("0")
+		}
+	case string:
+		// The literals are valid regardless of whether NO_BACKSLASH_ESCAPES is
+		// set. As backslashes cannot be escaped the same way in both modes,
+		// strings containing them are written as hex literals, which are binary
+		// strings converted to the charset of the column they are stored in.
+		if strings.ContainsRune(v, '\\') {
+			buf.WriteString("X'")
+			buf.WriteString(hex.EncodeToString([]byte(v)))
+			buf.WriteByte('\'')
+		} else {
+			buf.WriteByte('\'')
+			buf.WriteString(strings.ReplaceAll(v, "'", "''"))
+			buf.WriteByte('\'')
+		}
+	case []byte:
+		if v == nil {
+			buf.WriteString("NULL")
+		} else {
+			// Binary data is written as a hex literal, as it may not be valid in
+			// the charset of the connection.
+			buf.WriteString("X'")
+			buf.WriteString(hex.EncodeToString(v))
+			buf.WriteByte('\'')
+		}
+	case time.Time:
+		// The time is written in UTC, as the literal has no time zone.
+		buf.WriteByte('\'')
+		buf.WriteString(v.UTC().Format("2006-01-02 15:04:05.999999"))
+		buf.WriteByte('\'')
+	default:
+		return fmt.Errorf("unsupported argument type %T for the text protocol", arg)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/client"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateQuery(t *testing.T) {
+	query, err := interpolateQuery("SELECT * FROM t WHERE id = ?", nil)
+	require.Nil(t, err)
+	require.Equal(t, "SELECT * FROM t WHERE id = ?", query)
+
+	query, err = interpolateQuery(
+		"INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		[]interface{}{int64(-1), uint32(2), 1.5, true, "it's", []byte{0x00, 0xff}, nil, time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)},
+	)
+	require.Nil(t, err)
+	require.Equal(t, `INSERT INTO t VALUES (-1, 2, 1.5, 1, 'it''s', X'00ff', NULL, '2024-01-02 03:04:05.000006')`, query)
+
+	// Question marks in strings and identifiers are not placeholders.
+	query, err = interpolateQuery("SELECT '?', \"a\"\"?\", `?` FROM t WHERE a = ? AND b = 'x'", []interface{}{1})
+	require.Nil(t, err)
+	require.Equal(t, "SELECT '?', \"a\"\"?\", `?` FROM t WHERE a = 1 AND b = 'x'", query)
+
+	// Backslashes are not escapes, as they are not with NO_BACKSLASH_ESCAPES.
+	query, err = interpolateQuery(`SELECT 'a\', ?`, []interface{}{1})
+	require.Nil(t, err)
+	require.Equal(t, `SELECT 'a\', 1`, query)
+}
+
+func TestInterpolateQueryBackslashes(t *testing.T) {
+	// Strings with backslashes are written as hex literals, which mean the same
+	// with and without NO_BACKSLASH_ESCAPES, unlike any escaped string literal.
+	query, err := interpolateQuery("SELECT ?, ?", []interface{}{`a\`, `\'`})
+	require.Nil(t, err)
+	require.Equal(t, `SELECT X'615c', X'5c27'`, query)
+}
+
+func TestInterpolateQueryTimeInUTC(t *testing.T) {
+	zone := time.FixedZone("UTC-5", -5*60*60)
+	query, err := interpolateQuery("SELECT ?", []interface{}{time.Date(2024, 1, 2, 3, 4, 5, 0, zone)})
+	require.Nil(t, err)
+	require.Equal(t, "SELECT '2024-01-02 08:04:05'", query)
+}
+
+func TestInterpolateQueryErrors(t *testing.T) {
+	_, err := interpolateQuery("SELECT ?, ?", []interface{}{1})
+	require.ErrorContains(t, err, "more placeholders")
+
+	_, err = interpolateQuery("SELECT ?", []interface{}{1, 2})
+	require.ErrorContains(t, err, "1 placeholders but 2 arguments")
+
+	_, err = interpolateQuery("SELECT ?", []interface{}{struct{}{}})
+	require.ErrorContains(t, err, "unsupported argument type")
+}
+
+func TestStmtCacheClearedOnClose(t *testing.T) {
+	conn := &Connection{connList: []*client.Conn{}}
+	conn.stmtCache = []*stmtCache{newStmtCache(0)}
+
+	require.Nil(t, conn.Close())
+	require.Nil(t, conn.stmtCache)
+}
+
+func TestExecuteCached(t *testing.T) {
+	s := newTestMySQLServer(t)
+	cfg := s.databaseConfig()
+	cfg.ConnectionMultiplier = 2
+
+	conn, err := cfg.Connection()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	// Each statement is prepared once on each underlying connection.
+	for i := 0; i < 4; i++ {
+		_, err = conn.ExecuteCached("SELECT * FROM t WHERE id = ?", i)
+		require.Nil(t, err)
+	}
+
+	serverConns := s.connections()
+	require.Len(t, serverConns, 2)
+	for _, serverConn := range serverConns {
+		require.Equal(t, []string{"SELECT * FROM t WHERE id = ?"}, serverConn.Prepared())
+		require.Len(t, serverConn.Queries(), 2)
+	}
+
+	// The statements are prepared again on the new connections.
+	require.Nil(t, conn.Reconnect())
+	for i := 0; i < 2; i++ {
+		_, err = conn.ExecuteCached("SELECT * FROM t WHERE id = ?", i)
+		require.Nil(t, err)
+	}
+
+	serverConns = s.connections()
+	require.Len(t, serverConns, 4)
+	for _, serverConn := range serverConns[2:] {
+		require.Equal(t, []string{"SELECT * FROM t WHERE id = ?"}, serverConn.Prepared())
+	}
+}
+
+func TestExecuteCachedEvictsLeastRecentlyUsed(t *testing.T) {
+	s := newTestMySQLServer(t)
+	cfg := s.databaseConfig()
+	cfg.StmtCacheSize = 2
+
+	conn, err := cfg.Connection()
+	require.Nil(t, err)
+	defer conn.Close()
+
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3", "SELECT 1", "SELECT 2"} {
+		_, err = conn.ExecuteCached(query)
+		require.Nil(t, err)
+	}
+
+	// SELECT 2 is evicted by SELECT 3 as SELECT 1 was used more recently, so
+	// it is prepared again, which evicts SELECT 3.
+	serverConn := s.connections()[0]
+	require.Equal(t, []string{"SELECT 1", "SELECT 2", "SELECT 3", "SELECT 2"}, serverConn.Prepared())
+	require.Eventually(t, func() bool { return serverConn.ClosedStmts() == 2 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, conn.stmtCache[0].lru.Len())
+}
@@ -0,0 +1,239 @@
+package mybench
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The summary of a run, which is built from the histograms of all the
+// intervals of the measurement phase merged together, so the percentiles are
+// exact for the whole run. It is printed when the benchmark stops, written to
+// a JSON file next to the log file, and stored in the summary column of the
+// meta table.
+type RunSummary struct {
+	Benchmark string
+	TableName string
+	Note      string
+
+	// The start of the first and the end of the last interval of the
+	// measurement phase. The intervals of the warmup are excluded, see Phase.
+	StartTime time.Time
+	EndTime   time.Time
+	Duration  float64
+
+	Percentiles Percentiles
+
+	// All workloads merged together.
+	AllWorkloads WorkloadSummary
+
+	// The summary of each workload, indexed by the workload name.
+	Workloads map[string]WorkloadSummary
+}
+
+type WorkloadSummary struct {
+	Count int64
+
+	// The achieved rate and the mean desired rate over the measurement phase.
+	// The desired rate is 0 in the closed-loop mode.
+	Rate        float64
+	DesiredRate float64
+	ClosedLoop  bool
+
+	// All latencies in microseconds
+	Min         int64
+	Mean        float64
+	Max         int64
+	Percentiles []PercentileValue
+
+	UnderflowCount int64
+	OverflowCount  int64
+	Errors         ErrorCounts
+	Retries        int64
+}
+
+// Prints the summary as a table, with a row per workload followed by all
+// workloads merged together. The latencies are in milliseconds.
+func (s *RunSummary) WriteTable(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "Summary of %s (%.1fs of measurement from %s to %s):\n", s.TableName, s.Duration, s.StartTime.Format(time.RFC3339), s.EndTime.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	header := []string{"workload", "events", "rate/s", "desired/s", "min", "mean"}
+	for _, percentile := range s.Percentiles {
+		if percentile < 100 {
+			header = append(header, fmt.Sprintf("p%s", formatPercentile(percentile)))
+		}
+	}
+	header = append(header, "max", "errors", "retries", "underflow", "overflow")
+	fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+
+	names := make([]string, 0, len(s.Workloads))
+	for name := range s.Workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		writeWorkloadSummaryRow(tw, name, s.Workloads[name])
+	}
+	writeWorkloadSummaryRow(tw, "__all__", s.AllWorkloads)
+
+	return tw.Flush()
+}
+
+func writeWorkloadSummaryRow(w io.Writer, name string, summary WorkloadSummary) {
+	desiredRate := "-"
+	if !summary.ClosedLoop {
+		desiredRate = fmt.Sprintf("%.1f", summary.DesiredRate)
+	}
+
+	row := []string{
+		name,
+		fmt.Sprintf("%d", summary.Count),
+		fmt.Sprintf("%.1f", summary.Rate),
+		desiredRate,
+		formatMilliseconds(float64(summary.Min)),
+		formatMilliseconds(summary.Mean),
+	}
+	for _, percentile := range summary.Percentiles {
+		if percentile.Percentile < 100 {
+			row = append(row, formatMilliseconds(float64(percentile.Value)))
+		}
+	}
+	row = append(row,
+		formatMilliseconds(float64(summary.Max)),
+		fmt.Sprintf("%d", summary.Errors.Total()),
+		fmt.Sprintf("%d", summary.Retries),
+		fmt.Sprintf("%d", summary.UnderflowCount),
+		fmt.Sprintf("%d", summary.OverflowCount),
+	)
+
+	fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+}
+
+func formatMilliseconds(us float64) string {
+	return fmt.Sprintf("%.3fms", us/1000)
+}
+
+// Merges the data of the intervals of the measurement phase for the
+// RunSummary. Only used by the data logger goroutine.
+type summaryAccumulator struct {
+	startTime time.Time
+	endTime   time.Time
+
+	allWorkloads *ExtendedHdrHistogram
+	workloads    map[string]*ExtendedHdrHistogram
+
+	// The desired rate integrated over the intervals, which is divided by the
+	// duration to get the mean desired rate.
+	desiredEvents map[string]float64
+	closedLoop    map[string]bool
+}
+
+func newSummaryAccumulator() *summaryAccumulator {
+	return &summaryAccumulator{
+		workloads:     make(map[string]*ExtendedHdrHistogram),
+		desiredEvents: make(map[string]float64),
+		closedLoop:    make(map[string]bool),
+	}
+}
+
+// Adds the merged histograms of the workloads of an interval, which are in
+// microseconds. The merged histograms have the same range as the histograms of
+// the first interval, and all workloads merged together cover the ranges of all
+// of them.
+func (a *summaryAccumulator) add(dataSnapshot *DataSnapshot, histograms map[string]*ExtendedHdrHistogram) {
+	if a.allWorkloads == nil {
+		a.startTime = dataSnapshot.AllWorkloadData.StartTime
+
+		var allWorkloadsConfig *HistogramConfig
+		for _, hist := range histograms {
+			config := hist.config
+			if allWorkloadsConfig != nil {
+				config = allWorkloadsConfig.union(config)
+			}
+			allWorkloadsConfig = &config
+		}
+
+		if allWorkloadsConfig == nil {
+			allWorkloadsConfig = &DefaultHistogramConfig
+		}
+		a.allWorkloads = NewExtendedHdrHistogramWithConfig(a.startTime, *allWorkloadsConfig)
+	}
+	a.endTime = dataSnapshot.AllWorkloadData.EndTime
+
+	for workloadName, hist := range histograms {
+		workloadHist, found := a.workloads[workloadName]
+		if !found {
+			workloadHist = NewExtendedHdrHistogramWithConfig(a.startTime, hist.config)
+			a.workloads[workloadName] = workloadHist
+		}
+
+		workloadHist.mergeData(hist)
+		a.allWorkloads.mergeData(hist)
+
+		workloadData := dataSnapshot.PerWorkloadData[workloadName]
+		a.desiredEvents[workloadName] += workloadData.DesiredRate * workloadData.Delta
+		a.closedLoop[workloadName] = workloadData.ClosedLoop
+	}
+}
+
+// Returns nil if no intervals of the measurement phase were added.
+func (a *summaryAccumulator) summary(percentiles Percentiles) *RunSummary {
+	if a.allWorkloads == nil {
+		return nil
+	}
+
+	summary := &RunSummary{
+		StartTime:   a.startTime,
+		EndTime:     a.endTime,
+		Duration:    a.endTime.Sub(a.startTime).Seconds(),
+		Percentiles: percentiles,
+		Workloads:   make(map[string]WorkloadSummary),
+	}
+
+	// All workloads only have no desired rate if every workload is closed-loop.
+	summary.AllWorkloads.ClosedLoop = len(a.workloads) > 0
+	for workloadName, hist := range a.workloads {
+		workloadSummary := a.workloadSummary(hist, percentiles)
+		workloadSummary.ClosedLoop = a.closedLoop[workloadName]
+		if !workloadSummary.ClosedLoop && summary.Duration > 0 {
+			workloadSummary.DesiredRate = a.desiredEvents[workloadName] / summary.Duration
+		}
+
+		summary.Workloads[workloadName] = workloadSummary
+		summary.AllWorkloads.DesiredRate += workloadSummary.DesiredRate
+		summary.AllWorkloads.ClosedLoop = summary.AllWorkloads.ClosedLoop && workloadSummary.ClosedLoop
+	}
+
+	allWorkloadsSummary := a.workloadSummary(a.allWorkloads, percentiles)
+	allWorkloadsSummary.DesiredRate = summary.AllWorkloads.DesiredRate
+	allWorkloadsSummary.ClosedLoop = summary.AllWorkloads.ClosedLoop
+	summary.AllWorkloads = allWorkloadsSummary
+
+	return summary
+}
+
+func (a *summaryAccumulator) workloadSummary(hist *ExtendedHdrHistogram, percentiles Percentiles) WorkloadSummary {
+	visualization := hist.config.visualization()
+	data := hist.IntervalData(a.endTime, visualization.LatencyHistMin, visualization.LatencyHistMax, visualization.LatencyHistSize, percentiles)
+	return WorkloadSummary{
+		Count:          data.Count,
+		Rate:           data.Rate,
+		Min:            data.Min,
+		Mean:           data.Mean,
+		Max:            data.Max,
+		Percentiles:    data.Percentiles,
+		UnderflowCount: data.UnderflowCount,
+		OverflowCount:  data.OverflowCount,
+		Errors:         data.Errors,
+		Retries:        data.Retries,
+	}
+}
@@ -0,0 +1,133 @@
+package mybench
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func addSummaryIntervalForTest(a *summaryAccumulator, startTime time.Time, desiredRate float64, latencies map[string]int64) {
+	dataSnapshot := &DataSnapshot{
+		Phase:           PhaseMeasurement,
+		PerWorkloadData: make(map[string]WorkloadDataSnapshot),
+	}
+	dataSnapshot.AllWorkloadData.StartTime = startTime
+	dataSnapshot.AllWorkloadData.EndTime = startTime.Add(time.Second)
+
+	histograms := make(map[string]*ExtendedHdrHistogram)
+	for workloadName, latency := range latencies {
+		hist := NewExtendedHdrHistogram(startTime)
+		for i := 0; i < 100; i++ {
+			hist.RecordValue(latency)
+		}
+		hist.RecordError(ErrorClassLockWaitTimeout)
+		histograms[workloadName] = hist
+
+		dataSnapshot.PerWorkloadData[workloadName] = WorkloadDataSnapshot{
+			IntervalData: IntervalData{Delta: 1},
+			DesiredRate:  desiredRate,
+		}
+	}
+
+	a.add(dataSnapshot, histograms)
+}
+
+func TestSummaryAccumulator(t *testing.T) {
+	require.Nil(t, newSummaryAccumulator().summary(DefaultPercentiles))
+
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := newSummaryAccumulator()
+	addSummaryIntervalForTest(a, startTime, 100, map[string]int64{"A": 1000, "B": 3000})
+	addSummaryIntervalForTest(a, startTime.Add(time.Second), 300, map[string]int64{"A": 2000, "B": 3000})
+
+	summary := a.summary(Percentiles{50, 100})
+	require.Equal(t, startTime, summary.StartTime)
+	require.Equal(t, 2.0, summary.Duration)
+
+	workloadA := summary.Workloads["A"]
+	require.Equal(t, int64(200), workloadA.Count)
+	require.Equal(t, 100.0, workloadA.Rate)
+	require.Equal(t, 200.0, workloadA.DesiredRate)
+	require.InDelta(t, 1500, workloadA.Mean, 1)
+	require.Equal(t, int64(2), workloadA.Errors.LockWaitTimeout)
+	require.Equal(t, 50.0, workloadA.Percentiles[0].Percentile)
+	require.InDelta(t, 1000, workloadA.Percentiles[0].Value, 1)
+	require.InDelta(t, 2000, workloadA.Percentiles[1].Value, 1)
+
+	require.Equal(t, int64(400), summary.AllWorkloads.Count)
+	require.Equal(t, 200.0, summary.AllWorkloads.Rate)
+	require.Equal(t, 400.0, summary.AllWorkloads.DesiredRate)
+	require.InDelta(t, 3000, summary.AllWorkloads.Max, 1)
+	require.Equal(t, int64(4), summary.AllWorkloads.Errors.Total())
+
+	var table strings.Builder
+	require.Nil(t, summary.WriteTable(&table))
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	require.Equal(t, 5, len(lines))
+	require.Equal(t, []string{"workload", "events", "rate/s", "desired/s", "min", "mean", "p50", "max", "errors", "retries", "underflow", "overflow"}, strings.Fields(lines[1]))
+	require.Equal(t, []string{"A", "200", "100.0", "200.0", "1.000ms", "1.500ms", "1.000ms", "2.000ms", "2", "0", "0", "0"}, strings.Fields(lines[2]))
+	require.Equal(t, "__all__", strings.Fields(lines[4])[0])
+}
+
+func TestSummaryStoredInMetaTable(t *testing.T) {
+	d, _ := newDataLoggerForTest(t)
+	addSummaryIntervalForTest(d.summaryAccumulator, d.startTime, 100, map[string]int64{"A": 1000})
+	d.closeSinks()
+
+	db, err := sql.Open("sqlite3", d.OutputFilename)
+	require.Nil(t, err)
+	defer db.Close()
+
+	var data string
+	require.Nil(t, db.QueryRow("SELECT summary FROM meta WHERE table_name = 'T'").Scan(&data))
+
+	var summary RunSummary
+	require.Nil(t, json.Unmarshal([]byte(data), &summary))
+	require.Equal(t, "test", summary.Benchmark)
+	require.Equal(t, int64(100), summary.Workloads["A"].Count)
+
+	fileData, err := os.ReadFile(filepath.Join(filepath.Dir(d.OutputFilename), "T.summary.json"))
+	require.Nil(t, err)
+	require.JSONEq(t, data, string(fileData))
+}
+
+func TestSummaryAllWorkloadsClosedLoop(t *testing.T) {
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newAccumulator := func(closedLoop map[string]bool) *summaryAccumulator {
+		a := newSummaryAccumulator()
+		dataSnapshot := &DataSnapshot{
+			Phase:           PhaseMeasurement,
+			PerWorkloadData: make(map[string]WorkloadDataSnapshot),
+		}
+		dataSnapshot.AllWorkloadData.StartTime = startTime
+		dataSnapshot.AllWorkloadData.EndTime = startTime.Add(time.Second)
+
+		histograms := make(map[string]*ExtendedHdrHistogram)
+		for workloadName, workloadClosedLoop := range closedLoop {
+			histograms[workloadName] = NewExtendedHdrHistogram(startTime)
+			dataSnapshot.PerWorkloadData[workloadName] = WorkloadDataSnapshot{
+				IntervalData: IntervalData{Delta: 1},
+				ClosedLoop:   workloadClosedLoop,
+			}
+		}
+
+		a.add(dataSnapshot, histograms)
+		return a
+	}
+
+	summary := newAccumulator(map[string]bool{"A": true, "B": true}).summary(DefaultPercentiles)
+	require.True(t, summary.AllWorkloads.ClosedLoop)
+
+	// The order of the workloads is random, so the summary is checked several
+	// times.
+	for i := 0; i < 10; i++ {
+		summary = newAccumulator(map[string]bool{"A": true, "B": false, "C": true}).summary(DefaultPercentiles)
+		require.False(t, summary.AllWorkloads.ClosedLoop)
+	}
+}
@@ -0,0 +1,62 @@
+package mybench
+
+import (
+	"time"
+)
+
+// The phase of the benchmark an interval of data belongs to, which is logged
+// in the phase column of the log table.
+type Phase string
+
+const (
+	// The database is warming up, such as when the buffer pool is cold. Data
+	// collected during the warmup is excluded from summaries.
+	PhaseWarmup Phase = "warmup"
+
+	// The data is measured and is representative of the benchmark.
+	PhaseMeasurement Phase = "measurement"
+)
+
+// Tracks whether the benchmark is still warming up. The warmup ends once
+// either the warmup duration has elapsed or the warmup number of events have
+// been executed, whichever is configured. An interval is part of the warmup if
+// the warmup was not complete at the start of the interval, so intervals that
+// are only partially warmed up are excluded as well.
+type warmupTracker struct {
+	duration time.Duration
+	events   int64
+
+	startTime  time.Time
+	eventCount int64
+	done       bool
+}
+
+func newWarmupTracker(startTime time.Time, duration time.Duration, events int64) *warmupTracker {
+	return &warmupTracker{
+		duration:  duration,
+		events:    events,
+		startTime: startTime,
+		done:      duration == 0 && events == 0,
+	}
+}
+
+// Returns the phase of an interval that ends at intervalEnd with the given
+// number of events, and updates whether the warmup is complete as of the end
+// of the interval. Must be called for every interval, in order. Returns true
+// as the second value if the warmup completed at the end of this interval.
+func (w *warmupTracker) intervalPhase(intervalEnd time.Time, intervalEventCount int64) (Phase, bool) {
+	if w.done {
+		return PhaseMeasurement, false
+	}
+
+	w.eventCount += intervalEventCount
+	if w.duration > 0 && intervalEnd.Sub(w.startTime) >= w.duration {
+		w.done = true
+	}
+
+	if w.events > 0 && w.eventCount >= w.events {
+		w.done = true
+	}
+
+	return PhaseWarmup, w.done
+}
@@ -0,0 +1,50 @@
+package mybench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmupTrackerDuration(t *testing.T) {
+	startTime := time.Now()
+	tracker := newWarmupTracker(startTime, 2500*time.Millisecond, 0)
+
+	phases := []Phase{}
+	completedAt := -1
+	for i := 1; i <= 5; i++ {
+		phase, completed := tracker.intervalPhase(startTime.Add(time.Duration(i)*time.Second), 100)
+		phases = append(phases, phase)
+		if completed {
+			completedAt = i
+		}
+	}
+
+	// The third interval (2s - 3s) is only partially warmed up and is still part
+	// of the warmup.
+	require.Equal(t, []Phase{PhaseWarmup, PhaseWarmup, PhaseWarmup, PhaseMeasurement, PhaseMeasurement}, phases)
+	require.Equal(t, 3, completedAt)
+}
+
+func TestWarmupTrackerEvents(t *testing.T) {
+	startTime := time.Now()
+	tracker := newWarmupTracker(startTime, 0, 250)
+
+	phases := []Phase{}
+	for i := 1; i <= 4; i++ {
+		phase, _ := tracker.intervalPhase(startTime.Add(time.Duration(i)*time.Second), 100)
+		phases = append(phases, phase)
+	}
+
+	require.Equal(t, []Phase{PhaseWarmup, PhaseWarmup, PhaseWarmup, PhaseMeasurement}, phases)
+}
+
+func TestWarmupTrackerDisabled(t *testing.T) {
+	startTime := time.Now()
+	tracker := newWarmupTracker(startTime, 0, 0)
+
+	phase, completed := tracker.intervalPhase(startTime.Add(time.Second), 100)
+	require.Equal(t, PhaseMeasurement, phase)
+	require.False(t, completed)
+}
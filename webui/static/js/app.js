@@ -1,4 +1,6 @@
 const API_STATUS_URL = "/api/status";
+const API_EVENT_RATE_URL = "/api/eventrate";
+const API_WORKLOAD_SCALE_URL = "/api/workloadscale";
 const VL_SCHEMA = "https://vega.github.io/schema/vega-lite/v5.json";
 
 // TODO:
@@ -23,6 +25,144 @@ async function get_status() {
   return data;
 }
 
+async function post_form(url, form) {
+  const resp = await fetch(url, {
+    method: "POST",
+    body: new URLSearchParams(new FormData(form)),
+  });
+
+  if (!resp.ok) {
+    const msg = await resp.text();
+    alert(`failed to update: ${msg}`);
+    throw new Error(msg);
+  }
+
+  const rate_control_data = await resp.json();
+  update_controls(rate_control_data);
+  form.reset();
+}
+
+function setup_controls(status_data) {
+  // In the closed-loop mode, there is no target event rate to control or to
+  // compare the event rate against.
+  if (status_data.ClosedLoop) {
+    document.getElementById("controls").textContent = "Closed-loop mode: the event rate is not controlled";
+    document.getElementById("event-rate-pct-vis").style.display = "none";
+    return;
+  }
+
+  document.getElementById("eventrate-form").addEventListener("submit", async function (e) {
+    e.preventDefault();
+    await post_form(API_EVENT_RATE_URL, e.target);
+  });
+
+  const controls_span = document.getElementById("workloadscale-controls");
+  for (const workload_name of status_data.Workloads) {
+    const form = document.createElement("form");
+    form.innerHTML = `
+      <input type="hidden" name="workload">
+      <label><span class="workload-name"></span> scale (current: <span class="workloadscale-current"></span>)
+        <input type="number" name="workloadscale" min="0" max="1" step="any" required>
+      </label>
+      <button type="submit">Set</button>
+    `;
+    form.querySelector("input[name=workload]").value = workload_name;
+    form.querySelector(".workload-name").textContent = workload_name;
+    form.dataset.workload = workload_name;
+    form.addEventListener("submit", async function (e) {
+      e.preventDefault();
+      await post_form(API_WORKLOAD_SCALE_URL, e.target);
+    });
+
+    controls_span.appendChild(form);
+  }
+}
+
+function update_controls(rate_control_data) {
+  if (rate_control_data.ClosedLoop) {
+    return;
+  }
+
+  document.getElementById("eventrate-current").textContent = rate_control_data.EventRate.toFixed(1);
+
+  for (const form of document.querySelectorAll("#workloadscale-controls form")) {
+    const workload_scale = rate_control_data.WorkloadScales[form.dataset.workload];
+    form.querySelector(".workloadscale-current").textContent = workload_scale.toFixed(3);
+  }
+}
+
+// Returns the time ranges of the intervals in the warmup phase, which are
+// shaded in the plots.
+function warmup_data(status_data) {
+  let vl_data = [];
+
+  for (const data_snapshot of status_data.DataSnapshots) {
+    if (data_snapshot.Phase == "warmup") {
+      vl_data.push({
+        "Time": data_snapshot.Time - data_snapshot.AllWorkloadData.Delta,
+        "Time end": data_snapshot.Time,
+      });
+    }
+  }
+
+  return vl_data;
+}
+
+function warmup_layer() {
+  return {
+    data: { name: "warmup" },
+    mark: {
+      type: "rect",
+      color: "#999999",
+      opacity: 0.2,
+    },
+    encoding: {
+      "x": { field: "Time", type: "quantitative", title: "Time" },
+      "x2": { field: "Time end" },
+    },
+  };
+}
+
+// Returns null in the closed-loop mode so no desired rate line is drawn.
+function desired_rate(workload_snapshot) {
+  if (workload_snapshot.ClosedLoop) {
+    return null;
+  }
+
+  return workload_snapshot.DesiredRate;
+}
+
+// The percentiles are configured via -percentiles, where 100 is the maximum
+// latency.
+function percentile_label(percentile) {
+  if (percentile == 100) {
+    return "max";
+  }
+
+  return `p${percentile}`;
+}
+
+// The highest percentile below the maximum latency, which is plotted where
+// there is only room for a single percentile.
+function tail_percentile(percentiles) {
+  const below_max = percentiles.filter((percentile) => percentile < 100);
+  if (below_max.length == 0) {
+    return percentiles[percentiles.length - 1];
+  }
+
+  return below_max[below_max.length - 1];
+}
+
+function percentile_latency(snapshot, percentile) {
+  for (const value of snapshot.Percentiles) {
+    if (value.Percentile == percentile) {
+      return value.Value / 1000.0;
+    }
+  }
+
+  return null;
+}
+
 function draw_overall_rate_plot(status_data, time_domain) {
   let vl_data = [];
 
@@ -30,13 +170,14 @@ function draw_overall_rate_plot(status_data, time_domain) {
     vl_data.push({
       "Time": data_snapshot.Time,
       "Event rate": data_snapshot.AllWorkloadData.Rate,
-      "Desired rate": data_snapshot.AllWorkloadData.DesiredRate,
+      "Desired rate": desired_rate(data_snapshot.AllWorkloadData),
     });
   }
 
   window.overall_rate_vega_view
     .signal("time_domain", time_domain)
     .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
     .resize()
     .run();
 }
@@ -45,34 +186,67 @@ function draw_overall_latency_percentile(status_data, time_domain) {
   let vl_data = [];
 
   for (const data_snapshot of status_data.DataSnapshots) {
-    vl_data.push({
-      "Time": data_snapshot.Time,
-      "Latency (ms)": data_snapshot.AllWorkloadData.Percentile50 / 1000,
-      "Percentile": 50,
-    });
+    for (const value of data_snapshot.AllWorkloadData.Percentiles) {
+      vl_data.push({
+        "Time": data_snapshot.Time,
+        "Latency (ms)": value.Value / 1000,
+        "Percentile": percentile_label(value.Percentile),
+      });
+    }
+  }
 
-    vl_data.push({
-      "Time": data_snapshot.Time,
-      "Latency (ms)": data_snapshot.AllWorkloadData.Percentile75 / 1000,
-      "Percentile": 75,
-    });
+  window.latency_percentile_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+}
 
-    vl_data.push({
-      "Time": data_snapshot.Time,
-      "Latency (ms)": data_snapshot.AllWorkloadData.Percentile90 / 1000,
-      "Percentile": 90,
-    });
+// The response time is measured from the intended start time of the events,
+// so it includes the time events were delayed because the looper fell behind.
+function draw_response_time_plots(status_data, time_domain) {
+  const percentile = tail_percentile(status_data.Percentiles);
+  let overall_vl_data = [];
+  let workload_vl_data = [];
 
-    vl_data.push({
-      "Time": data_snapshot.Time,
-      "Latency (ms)": data_snapshot.AllWorkloadData.Percentile99 / 1000,
-      "Percentile": 99,
-    });
+  for (const data_snapshot of status_data.DataSnapshots) {
+    const all_response_time = data_snapshot.AllWorkloadData.ResponseTime;
+    if (all_response_time) {
+      for (const value of all_response_time.Percentiles) {
+        overall_vl_data.push({
+          "Time": data_snapshot.Time,
+          "Response time (ms)": value.Value / 1000,
+          "Percentile": percentile_label(value.Percentile),
+        });
+      }
+    }
+
+    for (const [workload_name, workload_snapshot] of data_snapshot.SortedData) {
+      if (!workload_snapshot.ResponseTime) {
+        continue;
+      }
+
+      workload_vl_data.push({
+        "Time": data_snapshot.Time,
+        "Workload": workload_name,
+        "Response time (ms)": percentile_latency(workload_snapshot.ResponseTime, percentile),
+        "Service time (ms)": percentile_latency(workload_snapshot, percentile),
+      });
+    }
   }
 
-  window.latency_percentile_vega_view
+  window.response_time_percentile_vega_view
     .signal("time_domain", time_domain)
-    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("data", vega.changeset().insert(overall_vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+
+  window.response_time_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(workload_vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
     .resize()
     .run();
 }
@@ -82,12 +256,13 @@ function draw_event_rate_plots(status_data, time_domain) {
 
   for (const data_snapshot of status_data.DataSnapshots) {
     for (const [workload_name, workload_snapshot] of data_snapshot.SortedData) {
+      const workload_desired_rate = desired_rate(workload_snapshot);
       vl_data.push({
         "Time": data_snapshot.Time,
         "Workload": workload_name,
         "Event rate": workload_snapshot.Rate,
-        "Desired rate": workload_snapshot.DesiredRate,
-        "Event rate / desired rate": workload_snapshot.Rate / workload_snapshot.DesiredRate,
+        "Desired rate": workload_desired_rate,
+        "Event rate / desired rate": workload_desired_rate === null ? null : workload_snapshot.Rate / workload_desired_rate,
       });
     }
   }
@@ -95,12 +270,14 @@ function draw_event_rate_plots(status_data, time_domain) {
   window.event_rate_vega_view
     .signal("time_domain", time_domain)
     .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
     .resize()
     .run();
 
   window.event_rate_pct_vega_view
     .signal("time_domain", time_domain)
     .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
     .resize()
     .run();
 }
@@ -110,29 +287,215 @@ function draw_latency_plots(status_data, time_domain) {
 
   for (const data_snapshots of status_data.DataSnapshots) {
     for (const [workload_name, workload_snapshot] of data_snapshots.SortedData) {
-      vl_data.push({
+      let row = {
         "Time": data_snapshots.Time,
         "Workload": workload_name,
         "Mean latency (ms)": workload_snapshot.Mean / 1000.0,
         "Max latency (ms)": workload_snapshot.Max / 1000.0,
-        "25th Percentile": workload_snapshot.Percentile25 / 1000.0,
-        "50th Percentile": workload_snapshot.Percentile50 / 1000.0,
-        "75th Percentile": workload_snapshot.Percentile75 / 1000.0,
-        "90th Percentile": workload_snapshot.Percentile90 / 1000.0,
-        "99th Percentile": workload_snapshot.Percentile99 / 1000.0,
-      });
+      };
+
+      for (const value of workload_snapshot.Percentiles) {
+        row[`${percentile_label(value.Percentile)} latency (ms)`] = value.Value / 1000.0;
+      }
+
+      vl_data.push(row);
     }
   }
 
   window.mean_latency_vega_view
     .signal("time_domain", time_domain)
     .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
     .resize()
     .run();
 
   window.max_latency_vega_view
     .signal("time_domain", time_domain)
     .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+}
+
+// The looper lag is only recorded for the workloads in the open-loop mode. It
+// shows whether mybench falls behind the desired rate, as opposed to the
+// database being slow.
+function draw_looper_lag_plots(status_data, time_domain) {
+  let vl_data = [];
+
+  for (const data_snapshot of status_data.DataSnapshots) {
+    for (const [workload_name, workload_snapshot] of data_snapshot.SortedData) {
+      const lag = workload_snapshot.LooperLag;
+      if (!lag) {
+        continue;
+      }
+
+      vl_data.push({
+        "Time": data_snapshot.Time,
+        "Workload": workload_name,
+        "Max wakeup lateness (ms)": lag.WakeupLatenessMax / 1000.0,
+        "Backlog (events)": lag.Backlog,
+        "Workers catching up (%)": lag.CatchingUpShare * 100,
+      });
+    }
+  }
+
+  if (vl_data.length == 0) {
+    return;
+  }
+
+  for (const id of ["looper-lateness-vis", "looper-backlog-vis", "looper-catching-up-vis"]) {
+    document.getElementById(id).style.display = "";
+  }
+
+  window.looper_lateness_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+
+  window.looper_backlog_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+
+  window.looper_catching_up_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+}
+
+const ERROR_CLASSES = [
+  ["Deadlock", "Deadlock"],
+  ["LockWaitTimeout", "Lock wait timeout"],
+  ["LostConnection", "Lost connection"],
+  ["QueryTimeout", "Query timeout"],
+  ["Other", "Other"],
+];
+
+function total_errors(workload_snapshot) {
+  let total = 0;
+  for (const [error_class, _] of ERROR_CLASSES) {
+    total += workload_snapshot.Errors[error_class];
+  }
+
+  return total;
+}
+
+function draw_error_plots(status_data, time_domain) {
+  let error_class_vl_data = [];
+  let workload_vl_data = [];
+
+  for (const data_snapshot of status_data.DataSnapshots) {
+    const all_workload_data = data_snapshot.AllWorkloadData;
+    for (const [error_class, error_class_name] of ERROR_CLASSES) {
+      error_class_vl_data.push({
+        "Time": data_snapshot.Time,
+        "Error": error_class_name,
+        "Error rate": all_workload_data.Errors[error_class] / all_workload_data.Delta,
+      });
+    }
+
+    for (const [workload_name, workload_snapshot] of data_snapshot.SortedData) {
+      workload_vl_data.push({
+        "Time": data_snapshot.Time,
+        "Workload": workload_name,
+        "Error rate": total_errors(workload_snapshot) / workload_snapshot.Delta,
+      });
+    }
+  }
+
+  window.error_rate_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(error_class_vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+
+  window.workload_error_rate_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(workload_vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+}
+
+// The data per host is only available if replicas are configured.
+function draw_host_plots(status_data, time_domain) {
+  let vl_data = [];
+  const percentile = tail_percentile(status_data.Percentiles);
+
+  for (const data_snapshot of status_data.DataSnapshots) {
+    if (!data_snapshot.PerHostData) {
+      continue;
+    }
+
+    for (const [host, host_snapshot] of Object.entries(data_snapshot.PerHostData).sort()) {
+      vl_data.push({
+        "Time": data_snapshot.Time,
+        "Host": host,
+        "Event rate": host_snapshot.Rate,
+        "Latency (ms)": percentile_latency(host_snapshot, percentile),
+      });
+    }
+  }
+
+  if (vl_data.length == 0) {
+    return;
+  }
+
+  for (const id of ["host-rate-vis", "host-latency-vis"]) {
+    document.getElementById(id).style.display = "";
+  }
+
+  window.host_rate_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+
+  window.host_latency_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
+    .resize()
+    .run();
+}
+
+// The connection setup latency is only available with -churn.
+function draw_connect_plot(status_data, time_domain) {
+  let vl_data = [];
+
+  for (const data_snapshot of status_data.DataSnapshots) {
+    if (!data_snapshot.ConnectData) {
+      continue;
+    }
+
+    for (const value of data_snapshot.ConnectData.Percentiles) {
+      vl_data.push({
+        "Time": data_snapshot.Time,
+        "Percentile": percentile_label(value.Percentile),
+        "Latency (ms)": value.Value / 1000.0,
+      });
+    }
+  }
+
+  if (vl_data.length == 0) {
+    return;
+  }
+
+  document.getElementById("connect-latency-vis").style.display = "";
+
+  window.connect_latency_vega_view
+    .signal("time_domain", time_domain)
+    .change("data", vega.changeset().insert(vl_data).remove(vega.truthy))
+    .change("warmup", vega.changeset().insert(warmup_data(status_data)).remove(vega.truthy))
     .resize()
     .run();
 }
@@ -183,22 +546,303 @@ function update_plots(status_data) {
 
   draw_overall_rate_plot(status_data, time_domain);
   draw_overall_latency_percentile(status_data, time_domain);
+  draw_response_time_plots(status_data, time_domain);
   draw_event_rate_plots(status_data, time_domain);
   draw_latency_plots(status_data, time_domain);
+  draw_error_plots(status_data, time_domain);
+  draw_looper_lag_plots(status_data, time_domain);
+  draw_host_plots(status_data, time_domain);
+  draw_connect_plot(status_data, time_domain);
 
   for (const workload_name of status_data.Workloads) {
     draw_latency_histogram(status_data, workload_name);
   }
 }
 
-async function setup_plots(workloads) {
-  // Overall event rate plot
-  let overall_rate_vl_schema = {
+async function setup_plots(workloads, percentiles) {
+  // Overall event rate plot
+  let overall_rate_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Overall rate",
+    data: { name: "data" },
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false
+            },
+          },
+          "y": { field: "Event rate", type: "quantitative" },
+        },
+      },
+      {
+        mark: {
+          type: "line",
+          strokeWidth: 1,
+          strokeDash: [4, 4],
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+          },
+          "y": { field: "Desired rate", type: "quantitative" },
+        },
+      },
+    ],
+  };
+
+  const overall_rate_vega_promise = vegaEmbed("#overall-rate-vis", overall_rate_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  // Percentile plot
+  let latency_percentile_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Overall latency percentile",
+    data: { name: "data" },
+    signals: [
+      { name: "time_domain", value: [0, 1] },
+    ],
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Latency (ms)", type: "quantitative" },
+          "color": {
+            field: "Percentile",
+            type: "nominal",
+            sort: null,
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+    ]
+  };
+
+  const latency_percentile_vega_promise = vegaEmbed("#overall-latency-percentile-vis", latency_percentile_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  // Response time plots
+  let response_time_percentile_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Overall response time percentile",
+    data: { name: "data" },
+    signals: [
+      { name: "time_domain", value: [0, 1] },
+    ],
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Response time (ms)", type: "quantitative" },
+          "color": {
+            field: "Percentile",
+            type: "nominal",
+            sort: null,
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+    ]
+  };
+
+  const response_time_percentile_vega_promise = vegaEmbed("#overall-response-time-percentile-vis", response_time_percentile_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  // The service time is drawn dashed in the same color as the response time
+  // of each workload, so the difference between them shows how far behind the
+  // looper is.
+  let response_time_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: `${percentile_label(tail_percentile(percentiles))} response time (solid) and service time (dashed)`,
+    data: { name: "data" },
+    signals: [
+      { name: "time_domain", value: [0, 1] },
+    ],
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Response time (ms)", type: "quantitative", title: "Latency (ms)" },
+          "color": {
+            field: "Workload",
+            type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+      {
+        mark: {
+          type: "line",
+          strokeWidth: 1,
+          strokeDash: [4, 4],
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+          },
+          "y": { field: "Service time (ms)", type: "quantitative" },
+          "color": {
+            field: "Workload",
+            type: "nominal",
+          },
+        },
+      },
+    ]
+  };
+
+  const response_time_vega_promise = vegaEmbed("#response-time-vis", response_time_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  // Setup event rate plot
+  let event_rate_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Event rate",
+    data: { name: "data" },
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false
+            },
+          },
+          "y": { field: "Event rate", type: "quantitative" },
+          "color": {
+            field: "Workload",
+            type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+      {
+        mark: {
+          type: "line",
+          strokeWidth: 1,
+          strokeDash: [4, 4],
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+          },
+          "y": { field: "Desired rate", type: "quantitative" },
+          "color": {
+            field: "Workload",
+            type: "nominal",
+          },
+        },
+      },
+    ],
+  };
+
+  const event_rate_vega_promise = vegaEmbed("#event-rate-vis", event_rate_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  let event_rate_pct_vl_spec = { // TODO: merge this into a subplot with the event_rate above to share the X axis
     $schema: VL_SCHEMA,
     width: "container",
-    title: "Overall rate",
+    title: "Event rate / desired rate",
     data: { name: "data" },
     layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
@@ -213,27 +857,66 @@ async function setup_plots(workloads) {
               nice: false
             },
           },
-          "y": { field: "Event rate", type: "quantitative" },
+          "y": { field: "Event rate / desired rate", type: "quantitative" },
+          "color": {
+            field: "Workload",
+            type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
+          },
         },
       },
+    ],
+  };
+
+  const event_rate_pct_vega_promise = vegaEmbed("#event-rate-pct-vis", event_rate_pct_vl_spec, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  let mean_latency_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Mean event latency",
+    data: { name: "data" },
+    signals: [
+      { name: "time_domain", value: [0, 1] },
+    ],
+    layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
-          strokeWidth: 1,
-          strokeDash: [4, 4],
+          point: true,
         },
         encoding: {
           "x": {
             field: "Time",
             type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Mean latency (ms)", type: "quantitative" },
+          "color": {
+            field: "Workload",
+            type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
           },
-          "y": { field: "Desired rate", type: "quantitative" },
         },
       },
-    ],
+    ]
   };
 
-  const overall_rate_vega_promise = vegaEmbed("#overall-rate-vis", overall_rate_vl_schema, {
+  const mean_latency_vega_promise = vegaEmbed("#mean-latency-vis", mean_latency_vl_schema, {
     // Workaround because vega lite can't have signals
     // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
     patch: (spec) => {
@@ -242,16 +925,16 @@ async function setup_plots(workloads) {
     }
   });
 
-  // Percentile plot
-  let latency_percentile_vl_schema = {
+  let max_latency_vl_schema = {
     $schema: VL_SCHEMA,
     width: "container",
-    title: "Overall latency percentile",
+    title: "Max event latency",
     data: { name: "data" },
     signals: [
       { name: "time_domain", value: [0, 1] },
     ],
     layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
@@ -266,9 +949,9 @@ async function setup_plots(workloads) {
               nice: false,
             },
           },
-          "y": { field: "Latency (ms)", type: "quantitative" },
+          "y": { field: "Max latency (ms)", type: "quantitative" },
           "color": {
-            field: "Percentile",
+            field: "Workload",
             type: "nominal",
             legend: {
               orient: "bottom",
@@ -279,7 +962,7 @@ async function setup_plots(workloads) {
     ]
   };
 
-  const latency_percentile_vega_promise = vegaEmbed("#overall-latency-percentile-vis", latency_percentile_vl_schema, {
+  const max_latency_vega_promise = vegaEmbed("#max-latency-vis", max_latency_vl_schema, {
     // Workaround because vega lite can't have signals
     // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
     patch: (spec) => {
@@ -288,13 +971,13 @@ async function setup_plots(workloads) {
     }
   });
 
-  // Setup event rate plot
-  let event_rate_vl_schema = {
+  let error_rate_vl_schema = {
     $schema: VL_SCHEMA,
     width: "container",
-    title: "Event rate",
+    title: "Error rate by error",
     data: { name: "data" },
     layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
@@ -306,12 +989,12 @@ async function setup_plots(workloads) {
             type: "quantitative",
             scale: {
               domain: { signal: "time_domain" },
-              nice: false
+              nice: false,
             },
           },
-          "y": { field: "Event rate", type: "quantitative" },
+          "y": { field: "Error rate", type: "quantitative" },
           "color": {
-            field: "Workload",
+            field: "Error",
             type: "nominal",
             legend: {
               orient: "bottom",
@@ -319,28 +1002,53 @@ async function setup_plots(workloads) {
           },
         },
       },
+    ]
+  };
+
+  const error_rate_vega_promise = vegaEmbed("#error-rate-vis", error_rate_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  let workload_error_rate_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Error rate by workload",
+    data: { name: "data" },
+    layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
-          strokeWidth: 1,
-          strokeDash: [4, 4],
+          point: true,
         },
         encoding: {
           "x": {
             field: "Time",
             type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
           },
-          "y": { field: "Desired rate", type: "quantitative" },
+          "y": { field: "Error rate", type: "quantitative" },
           "color": {
             field: "Workload",
             type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
           },
         },
       },
-    ],
+    ]
   };
 
-  const event_rate_vega_promise = vegaEmbed("#event-rate-vis", event_rate_vl_schema, {
+  const workload_error_rate_vega_promise = vegaEmbed("#workload-error-rate-vis", workload_error_rate_vl_schema, {
     // Workaround because vega lite can't have signals
     // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
     patch: (spec) => {
@@ -349,12 +1057,13 @@ async function setup_plots(workloads) {
     }
   });
 
-  let event_rate_pct_vl_spec = { // TODO: merge this into a subplot with the event_rate above to share the X axis
+  let looper_lateness_vl_schema = {
     $schema: VL_SCHEMA,
     width: "container",
-    title: "Event rate / desired rate",
+    title: "Max looper wakeup lateness",
     data: { name: "data" },
     layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
@@ -366,10 +1075,10 @@ async function setup_plots(workloads) {
             type: "quantitative",
             scale: {
               domain: { signal: "time_domain" },
-              nice: false
+              nice: false,
             },
           },
-          "y": { field: "Event rate / desired rate", type: "quantitative" },
+          "y": { field: "Max wakeup lateness (ms)", type: "quantitative" },
           "color": {
             field: "Workload",
             type: "nominal",
@@ -379,10 +1088,10 @@ async function setup_plots(workloads) {
           },
         },
       },
-    ],
+    ]
   };
 
-  const event_rate_pct_vega_promise = vegaEmbed("#event-rate-pct-vis", event_rate_pct_vl_spec, {
+  const looper_lateness_vega_promise = vegaEmbed("#looper-lateness-vis", looper_lateness_vl_schema, {
     // Workaround because vega lite can't have signals
     // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
     patch: (spec) => {
@@ -391,15 +1100,13 @@ async function setup_plots(workloads) {
     }
   });
 
-  let mean_latency_vl_schema = {
+  let looper_backlog_vl_schema = {
     $schema: VL_SCHEMA,
     width: "container",
-    title: "Mean event latency",
+    title: "Looper backlog",
     data: { name: "data" },
-    signals: [
-      { name: "time_domain", value: [0, 1] },
-    ],
     layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
@@ -414,7 +1121,7 @@ async function setup_plots(workloads) {
               nice: false,
             },
           },
-          "y": { field: "Mean latency (ms)", type: "quantitative" },
+          "y": { field: "Backlog (events)", type: "quantitative" },
           "color": {
             field: "Workload",
             type: "nominal",
@@ -427,7 +1134,7 @@ async function setup_plots(workloads) {
     ]
   };
 
-  const mean_latency_vega_promise = vegaEmbed("#mean-latency-vis", mean_latency_vl_schema, {
+  const looper_backlog_vega_promise = vegaEmbed("#looper-backlog-vis", looper_backlog_vl_schema, {
     // Workaround because vega lite can't have signals
     // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
     patch: (spec) => {
@@ -436,15 +1143,13 @@ async function setup_plots(workloads) {
     }
   });
 
-  let max_latency_vl_schema = {
+  let looper_catching_up_vl_schema = {
     $schema: VL_SCHEMA,
     width: "container",
-    title: "Max event latency",
+    title: "Workers catching up",
     data: { name: "data" },
-    signals: [
-      { name: "time_domain", value: [0, 1] },
-    ],
     layer: [
+      warmup_layer(),
       {
         mark: {
           type: "line",
@@ -459,7 +1164,7 @@ async function setup_plots(workloads) {
               nice: false,
             },
           },
-          "y": { field: "Max latency (ms)", type: "quantitative" },
+          "y": { field: "Workers catching up (%)", type: "quantitative" },
           "color": {
             field: "Workload",
             type: "nominal",
@@ -472,7 +1177,137 @@ async function setup_plots(workloads) {
     ]
   };
 
-  const max_latency_vega_promise = vegaEmbed("#max-latency-vis", max_latency_vl_schema, {
+  const looper_catching_up_vega_promise = vegaEmbed("#looper-catching-up-vis", looper_catching_up_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  let host_rate_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Event rate by host",
+    data: { name: "data" },
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Event rate", type: "quantitative" },
+          "color": {
+            field: "Host",
+            type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+    ]
+  };
+
+  const host_rate_vega_promise = vegaEmbed("#host-rate-vis", host_rate_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  let host_latency_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: `${percentile_label(tail_percentile(percentiles))} latency by host`,
+    data: { name: "data" },
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Latency (ms)", type: "quantitative" },
+          "color": {
+            field: "Host",
+            type: "nominal",
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+    ]
+  };
+
+  const host_latency_vega_promise = vegaEmbed("#host-latency-vis", host_latency_vl_schema, {
+    // Workaround because vega lite can't have signals
+    // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
+    patch: (spec) => {
+      spec.signals.push({ name: "time_domain" });
+      return spec;
+    }
+  });
+
+  let connect_latency_vl_schema = {
+    $schema: VL_SCHEMA,
+    width: "container",
+    title: "Connection setup latency",
+    data: { name: "data" },
+    layer: [
+      warmup_layer(),
+      {
+        mark: {
+          type: "line",
+          point: true,
+        },
+        encoding: {
+          "x": {
+            field: "Time",
+            type: "quantitative",
+            scale: {
+              domain: { signal: "time_domain" },
+              nice: false,
+            },
+          },
+          "y": { field: "Latency (ms)", type: "quantitative" },
+          "color": {
+            field: "Percentile",
+            type: "nominal",
+            sort: null,
+            legend: {
+              orient: "bottom",
+            },
+          },
+        },
+      },
+    ]
+  };
+
+  const connect_latency_vega_promise = vegaEmbed("#connect-latency-vis", connect_latency_vl_schema, {
     // Workaround because vega lite can't have signals
     // https://stackoverflow.com/questions/57707494/whats-the-proper-way-to-implement-a-custom-click-handler-in-vega-lite
     patch: (spec) => {
@@ -531,6 +1366,12 @@ async function setup_plots(workloads) {
   let latency_percentile_vega_result = await latency_percentile_vega_promise;
   window.latency_percentile_vega_view = latency_percentile_vega_result.view;
 
+  let response_time_percentile_vega_result = await response_time_percentile_vega_promise;
+  window.response_time_percentile_vega_view = response_time_percentile_vega_result.view;
+
+  let response_time_vega_result = await response_time_vega_promise;
+  window.response_time_vega_view = response_time_vega_result.view;
+
   let event_rate_vega_result = await event_rate_vega_promise;
   window.event_rate_vega_view = event_rate_vega_result.view;
 
@@ -543,6 +1384,30 @@ async function setup_plots(workloads) {
   let max_latency_vega_result = await max_latency_vega_promise;
   window.max_latency_vega_view = max_latency_vega_result.view;
 
+  let error_rate_vega_result = await error_rate_vega_promise;
+  window.error_rate_vega_view = error_rate_vega_result.view;
+
+  let workload_error_rate_vega_result = await workload_error_rate_vega_promise;
+  window.workload_error_rate_vega_view = workload_error_rate_vega_result.view;
+
+  let looper_lateness_vega_result = await looper_lateness_vega_promise;
+  window.looper_lateness_vega_view = looper_lateness_vega_result.view;
+
+  let looper_backlog_vega_result = await looper_backlog_vega_promise;
+  window.looper_backlog_vega_view = looper_backlog_vega_result.view;
+
+  let looper_catching_up_vega_result = await looper_catching_up_vega_promise;
+  window.looper_catching_up_vega_view = looper_catching_up_vega_result.view;
+
+  let host_rate_vega_result = await host_rate_vega_promise;
+  window.host_rate_vega_view = host_rate_vega_result.view;
+
+  let host_latency_vega_result = await host_latency_vega_promise;
+  window.host_latency_vega_view = host_latency_vega_result.view;
+
+  let connect_latency_vega_result = await connect_latency_vega_promise;
+  window.connect_latency_vega_view = connect_latency_vega_result.view;
+
   window.hist_vega_views = {};
   for (const workload_name of workloads) {
     let result = await hist_vega_promises[workload_name];
@@ -552,7 +1417,9 @@ async function setup_plots(workloads) {
 
 async function main() {
   const status_data = await get_status();
-  await setup_plots(status_data.Workloads);
+  await setup_plots(status_data.Workloads, status_data.Percentiles);
+  setup_controls(status_data);
+  update_controls(status_data);
   update_plots(status_data);
 
   setInterval(async function () {
@@ -561,6 +1428,7 @@ async function main() {
     if (status_data.Note.length > 0) {
         document.getElementById("runnote").innerHTML = "(" + status_data.Note + ")";
     }
+    update_controls(status_data);
     update_plots(status_data);
   }, 5000);
 }
@@ -2,10 +2,12 @@ package mybench
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.uber.org/atomic"
 )
 
 type RateControlConfig struct {
@@ -25,8 +27,47 @@ type RateControlConfig struct {
 	// The desired rate of the outer loop that batches events. Default: 50.
 	OuterLoopRate float64
 
-	// The type of looper used. Default to Uniform looper.
-	LooperType LooperType
+	// Configures the arrival process of the events, which determines how the
+	// events are spaced in time. Default to the Uniform looper.
+	ArrivalConfig
+
+	// If true, each worker calls the next Event as soon as the previous one
+	// finishes (after the optional ThinkTime), instead of pacing the events to
+	// achieve the EventRate. The event rate is then an output of the benchmark
+	// instead of an input. Concurrency must be specified in this mode, and the
+	// EventRate, LoadProfile, and ArrivalConfig are ignored.
+	ClosedLoop bool
+
+	// The time each worker waits between events in the closed-loop mode.
+	ThinkTime time.Duration
+
+	// If true, the think time is exponentially distributed with ThinkTime as
+	// the mean, instead of being fixed.
+	RandomThinkTime bool
+
+	// An optional schedule that varies the EventRate over time. If specified,
+	// EventRate is ignored and the Concurrency is calculated from the peak rate
+	// of the load profile.
+	LoadProfile LoadProfile
+}
+
+// Returns the desired event rate at the given time since the start of the
+// benchmark. This is EventRate unless a load profile is configured.
+func (c RateControlConfig) EventRateAt(elapsed time.Duration) float64 {
+	if c.LoadProfile.Enabled() {
+		return c.LoadProfile.Rate(elapsed)
+	}
+
+	return c.EventRate
+}
+
+// Returns the highest event rate that needs to be driven during the benchmark.
+func (c RateControlConfig) PeakEventRate() float64 {
+	if c.LoadProfile.Enabled() {
+		return c.LoadProfile.MaxRate()
+	}
+
+	return c.EventRate
 }
 
 type VisualizationConfig struct {
@@ -40,6 +81,16 @@ type VisualizationConfig struct {
 	LatencyHistSize int64
 }
 
+// Returns a config that covers the ranges of both configs, with the larger
+// number of buckets.
+func (c VisualizationConfig) union(other VisualizationConfig) VisualizationConfig {
+	return VisualizationConfig{
+		LatencyHistMin:  min(c.LatencyHistMin, other.LatencyHistMin),
+		LatencyHistMax:  max(c.LatencyHistMax, other.LatencyHistMax),
+		LatencyHistSize: max(c.LatencyHistSize, other.LatencyHistSize),
+	}
+}
+
 // Config used to create the Workload
 type WorkloadConfig struct {
 	// The name of the workload, for identification purposes only.
@@ -52,6 +103,25 @@ type WorkloadConfig struct {
 	// Configures the visualization for this workload.
 	// Some workload may know the latency bounds, and may wish to choose a better scale for the histograms
 	Visualization VisualizationConfig
+
+	// The range and precision of the histograms that track the latency of the
+	// events of this workload. Default: DefaultHistogramConfig.
+	Histogram HistogramConfig
+
+	// If set, overrides the arrival process configured for the benchmark for
+	// this workload only. This allows some workloads to be bursty while others
+	// are not.
+	Arrival *ArrivalConfig
+
+	// If set, overrides the retries configured for the benchmark for this
+	// workload only, as not all transactions may be retried by the
+	// application.
+	Retry *RetryConfig
+
+	// If not 0, overrides the event timeout configured for the benchmark for
+	// this workload only, as some transactions are expected to take longer
+	// than others. See BenchmarkConfig.EventTimeout.
+	EventTimeout time.Duration
 }
 
 func (w WorkloadConfig) Config() WorkloadConfig {
@@ -137,6 +207,20 @@ type Workload[ContextDataT any] struct {
 
 	databaseConfig    DatabaseConfig
 	rateControlConfig RateControlConfig
+
+	// The total event rate of the benchmark and the current workload scale,
+	// both of which can change while the benchmark is running.
+	benchmarkEventRate *eventRateController
+	workloadScale      *atomic.Float64
+
+	// Decides what happens when an Event returns an error. abortBenchmark is
+	// called to stop the whole benchmark with ErrorPolicyAbort.
+	errorPolicy    ErrorPolicy
+	retryConfig    RetryConfig
+	eventTimeout   time.Duration
+	abortBenchmark func(error)
+
+	availability *availabilityTracker
 }
 
 // We want the workload to be templated so the context data can be transparently
@@ -157,7 +241,7 @@ type AbstractWorkload interface {
 	// We need to set the RateControlConfig on the Workload object, because the
 	// data logger needs to know the concurrency/event rate of the workload.
 	// See comments in Benchmark.Start for more details.
-	FinishInitialization(DatabaseConfig, RateControlConfig)
+	FinishInitialization(DatabaseConfig, RateControlConfig, *eventRateController, ErrorPolicy, RetryConfig, time.Duration, func(error))
 
 	// The DataLogger need to iterate through all the OnlineHistograms for each
 	// worker so it can perform the double buffer swap. Since the DataLogger only
@@ -175,9 +259,51 @@ type AbstractWorkload interface {
 	// overhead/memory allocations.
 	ForEachOnlineHistogram(func(int, *OnlineHistogram))
 
-	// The DataLogger needs the rate control config to make allocations and record
-	// desired event rates. See comments in Benchmark.Start for more details.
+	// Similar to ForEachOnlineHistogram, but iterates through the histograms of
+	// each target of the connections of each worker, which are labeled by
+	// TargetLabels. Only called if TargetLabels is not empty.
+	ForEachTargetOnlineHistogram(func(target int, onlineHist *OnlineHistogram))
+
+	// Similar to ForEachOnlineHistogram, but iterates through the histograms of
+	// the response time of the events, which is measured from the intended
+	// start time of the events. See EventStat.ResponseTime.
+	ForEachResponseOnlineHistogram(func(int, *OnlineHistogram))
+
+	// Similar to ForEachOnlineHistogram, but iterates through the histograms of
+	// the time taken to open connections in the connection churn mode. Does
+	// nothing if the connection churn mode is disabled.
+	ForEachConnectOnlineHistogram(func(int, *OnlineHistogram))
+
+	// Similar to ForEachOnlineHistogram, but iterates through the lag of the
+	// looper of each worker behind the desired schedule. Does nothing in the
+	// closed-loop mode.
+	ForEachOnlineLooperLag(func(int, *OnlineLooperLag))
+
+	// Returns the labels of the primary and the replicas if replicas are
+	// configured, in which case the latency is also logged per target.
+	TargetLabels() []string
+
+	// The DataLogger needs the rate control config to make allocations. See
+	// comments in Benchmark.Start for more details.
 	RateControlConfig() RateControlConfig
+
+	// Returns the desired event rate of the workload at the given time, which
+	// is the total event rate of the benchmark scaled by the current workload
+	// scale. The DataLogger records this as the desired rate.
+	DesiredEventRate(time.Time) float64
+
+	// Returns and sets the workload scale while the benchmark is running. See
+	// Benchmark.SetWorkloadScale.
+	WorkloadScale() float64
+	SetWorkloadScale(float64)
+
+	// Returns the downtime windows of the workload that ended since the last
+	// call, so the DataLogger can record them. See availabilityTracker.
+	TakeDowntimeWindows(includeOngoing bool) []DowntimeWindow
+
+	// Kills the connections of all workers with KILL, which is used to stop
+	// workers that are stuck in an event when the benchmark is stopped.
+	KillConnections()
 }
 
 func NewWorkload[ContextDataT any](workloadIface WorkloadInterface[ContextDataT]) *Workload[ContextDataT] {
@@ -186,6 +312,10 @@ func NewWorkload[ContextDataT any](workloadIface WorkloadInterface[ContextDataT]
 		panic("must specify workload name")
 	}
 
+	if c.EventTimeout < 0 {
+		panic(fmt.Errorf("EventTimeout of workload %s cannot be negative", c.Name))
+	}
+
 	if c.WorkloadScale < 0 || c.WorkloadScale > 1 {
 		panic("WorkloadScale must be between 0 and 1")
 	} else if c.WorkloadScale == 0 { // if not specified, default to 1
@@ -203,24 +333,54 @@ func NewWorkload[ContextDataT any](workloadIface WorkloadInterface[ContextDataT]
 		c.Visualization.LatencyHistSize = 1000
 	}
 
+	err := c.Histogram.ValidateAndSetDefaults()
+	if err != nil {
+		panic(fmt.Errorf("invalid histogram config for workload %s: %w", c.Name, err))
+	}
+
+	if c.Arrival != nil {
+		arrivalConfig := *c.Arrival // take a copy to not modify the caller's config
+		err := arrivalConfig.ValidateAndSetDefaults()
+		if err != nil {
+			panic(fmt.Errorf("invalid arrival config for workload %s: %w", c.Name, err))
+		}
+		c.Arrival = &arrivalConfig
+	}
+
+	if c.Retry != nil {
+		retryConfig := *c.Retry // take a copy to not modify the caller's config
+		err := retryConfig.ValidateAndSetDefaults()
+		if err != nil {
+			panic(fmt.Errorf("invalid retry config for workload %s: %w", c.Name, err))
+		}
+		c.Retry = &retryConfig
+	}
+
 	return &Workload[ContextDataT]{
 		WorkloadConfig: c,
 		workloadIface:  workloadIface,
 		workersWg:      &sync.WaitGroup{},
 		logger:         logrus.WithField("workload", c.Name),
+		workloadScale:  atomic.NewFloat64(c.WorkloadScale),
+		availability:   newAvailabilityTracker(c.Name),
 	}
 }
 
-func (w *Workload[ContextDataT]) FinishInitialization(databaseConfig DatabaseConfig, rateControlConfig RateControlConfig) {
+func (w *Workload[ContextDataT]) FinishInitialization(databaseConfig DatabaseConfig, rateControlConfig RateControlConfig, benchmarkEventRate *eventRateController, errorPolicy ErrorPolicy, retryConfig RetryConfig, eventTimeout time.Duration, abortBenchmark func(error)) {
 	w.databaseConfig = databaseConfig
 	w.rateControlConfig = rateControlConfig
+	w.benchmarkEventRate = benchmarkEventRate
+	w.errorPolicy = errorPolicy
+	w.retryConfig = retryConfig
+	w.eventTimeout = eventTimeout
+	w.abortBenchmark = abortBenchmark
 }
 
 func (w *Workload[ContextDataT]) Run(ctx context.Context, workerInitializationWg *sync.WaitGroup, startTime time.Time) {
 	var err error
 	w.workers = make([]*BenchmarkWorker[ContextDataT], w.rateControlConfig.Concurrency)
 	for i := 0; i < w.rateControlConfig.Concurrency; i++ {
-		w.workers[i], err = NewBenchmarkWorker(w.workloadIface, w.databaseConfig, w.rateControlConfig)
+		w.workers[i], err = NewBenchmarkWorker(w.workloadIface, w.databaseConfig, w.rateControlConfig, w.errorPolicy, w.retryConfig, w.eventTimeout, w.availability, w.DesiredEventRate, w.Histogram)
 		if err != nil {
 			panic(err)
 		}
@@ -236,8 +396,16 @@ func (w *Workload[ContextDataT]) Run(ctx context.Context, workerInitializationWg
 		go func(worker *BenchmarkWorker[ContextDataT]) {
 			defer w.workersWg.Done()
 			err := worker.Run(ctx, workerInitializationWg, startTime)
-			if err != nil {
-				w.logger.WithError(err).Panic("failed to run worker")
+			if err == nil {
+				return
+			}
+
+			// With ErrorPolicyContinue, the worker never returns an error.
+			if w.errorPolicy == ErrorPolicyAbort {
+				w.logger.WithError(err).Error("event failed, aborting the benchmark")
+				w.abortBenchmark(fmt.Errorf("workload %s: %w", w.Name, err))
+			} else {
+				w.logger.WithError(err).Error("event failed, stopping the worker")
 			}
 		}(w.workers[i])
 	}
@@ -253,8 +421,73 @@ func (w *Workload[ContextDataT]) RateControlConfig() RateControlConfig {
 	return w.rateControlConfig
 }
 
+func (w *Workload[ContextDataT]) DesiredEventRate(now time.Time) float64 {
+	return w.benchmarkEventRate.EventRateAt(now) * w.workloadScale.Load()
+}
+
+func (w *Workload[ContextDataT]) WorkloadScale() float64 {
+	return w.workloadScale.Load()
+}
+
+func (w *Workload[ContextDataT]) SetWorkloadScale(workloadScale float64) {
+	w.workloadScale.Store(workloadScale)
+}
+
 func (w *Workload[ContextDataT]) ForEachOnlineHistogram(f func(int, *OnlineHistogram)) {
 	for i, worker := range w.workers {
 		f(i, worker.onlineHist)
 	}
 }
+
+func (w *Workload[ContextDataT]) ForEachTargetOnlineHistogram(f func(int, *OnlineHistogram)) {
+	for _, worker := range w.workers {
+		for target, onlineHist := range worker.targetHists {
+			f(target, onlineHist)
+		}
+	}
+}
+
+func (w *Workload[ContextDataT]) ForEachResponseOnlineHistogram(f func(int, *OnlineHistogram)) {
+	for i, worker := range w.workers {
+		f(i, worker.responseHist)
+	}
+}
+
+func (w *Workload[ContextDataT]) ForEachConnectOnlineHistogram(f func(int, *OnlineHistogram)) {
+	if !w.databaseConfig.Churn.Enabled() {
+		return
+	}
+
+	for i, worker := range w.workers {
+		f(i, worker.connectHist)
+	}
+}
+
+func (w *Workload[ContextDataT]) ForEachOnlineLooperLag(f func(int, *OnlineLooperLag)) {
+	for i, worker := range w.workers {
+		if worker.looperLag != nil {
+			f(i, worker.looperLag)
+		}
+	}
+}
+
+func (w *Workload[ContextDataT]) TargetLabels() []string {
+	if len(w.databaseConfig.Replicas) == 0 {
+		return nil
+	}
+
+	return w.databaseConfig.targetLabels()
+}
+
+func (w *Workload[ContextDataT]) KillConnections() {
+	for _, worker := range w.workers {
+		err := worker.killer.killConnections()
+		if err != nil {
+			w.logger.WithError(err).Warn("failed to kill the connections of a worker")
+		}
+	}
+}
+
+func (w *Workload[ContextDataT]) TakeDowntimeWindows(includeOngoing bool) []DowntimeWindow {
+	return w.availability.takeDowntimeWindows(includeOngoing)
+}